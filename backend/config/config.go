@@ -2,12 +2,38 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
 	Port         string
 	DatabasePath string
 	JWTSecret    string
+
+	// 提醒调度相关
+	ReminderScanInterval int // 扫描间隔（秒）
+	ReminderMaxRetries   int // 最大重试次数，超过后进入死信状态
+
+	// 回收站相关
+	TrashRetentionDays int // 删除后保留的天数，超过后永久删除
+	TrashPurgeInterval int // 清理间隔（秒）
+
+	// 任务排序键整理间隔（秒）
+	TaskRankRebalanceInterval int
+
+	// 修改和删除资源时是否必须携带 If-Match 头，默认不强制以兼容旧客户端
+	RequireIfMatch bool
+
+	// 导入账户备份时文件的大小上限（MB）
+	AccountImportMaxSizeMB int
+
+	// 邮件提醒 SMTP 配置
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string // 发件人，如 "On The Way <noreply@example.com>"
+	SMTPTLSMode  string // none, starttls, tls
 }
 
 func Load() *Config {
@@ -15,6 +41,25 @@ func Load() *Config {
 		Port:         getEnv("PORT", "8082"),
 		DatabasePath: getEnv("DATABASE_PATH", "./data.db"),
 		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+
+		ReminderScanInterval: getEnvInt("REMINDER_SCAN_INTERVAL", 30),
+		ReminderMaxRetries:   getEnvInt("REMINDER_MAX_RETRIES", 5),
+
+		TrashRetentionDays: getEnvInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeInterval: getEnvInt("TRASH_PURGE_INTERVAL", 3600),
+
+		TaskRankRebalanceInterval: getEnvInt("TASK_RANK_REBALANCE_INTERVAL", 600),
+
+		RequireIfMatch: getEnvBool("REQUIRE_IF_MATCH", false),
+
+		AccountImportMaxSizeMB: getEnvInt("ACCOUNT_IMPORT_MAX_SIZE_MB", 50),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+		SMTPTLSMode:  getEnv("SMTP_TLS_MODE", "starttls"),
 	}
 }
 
@@ -24,3 +69,21 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
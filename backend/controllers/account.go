@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultAccountImportMaxSize 未配置时导入文件的大小上限
+const defaultAccountImportMaxSize = 50 << 20
+
+type AccountController struct {
+	db            *gorm.DB
+	events        *services.EventHub
+	maxImportSize int64
+}
+
+func NewAccountController(db *gorm.DB, events *services.EventHub, maxImportSize int64) *AccountController {
+	if maxImportSize <= 0 {
+		maxImportSize = defaultAccountImportMaxSize
+	}
+	return &AccountController{db: db, events: events, maxImportSize: maxImportSize}
+}
+
+// ExportAccount 下载当前用户全部数据的备份文件，format=zip 时打包为 zip，默认为 JSON
+func (ctrl *AccountController) ExportAccount(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		utils.BadRequest(c, "Invalid export format")
+		return
+	}
+
+	now := time.Now()
+	backup, err := services.ExportAccount(ctrl.db, userID, now)
+	if err != nil {
+		utils.LogError("Failed to export account", zap.Uint64("user_id", userID), zap.Error(err))
+		utils.InternalError(c, "Failed to export account")
+		return
+	}
+
+	contentType := "application/json; charset=utf-8"
+	if format == "zip" {
+		contentType = "application/zip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="on-the-way-backup-%s.%s"`, now.Format("20060102"), format))
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，写入失败时只能记录日志
+	if err := services.WriteAccountBackup(c.Writer, backup, format == "zip"); err != nil {
+		utils.LogError("Failed to write account export", zap.Uint64("user_id", userID), zap.Error(err))
+	}
+}
+
+// ImportAccount 把备份文件恢复到当前账户，请求体为导出的 JSON 或 zip 文件，也可以用表单字段 file 上传。
+// 所有记录在一个事务中导入，dryRun=true 时只校验并返回导入报告，不保存任何修改；
+// skipDuplicates=true 时账户中已有的相同记录不再创建
+func (ctrl *AccountController) ImportAccount(c *gin.Context) {
+	_, data, err := ctrl.readImportFile(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.PayloadTooLarge(c, "Backup file is too large")
+			return
+		}
+		utils.BadRequest(c, "Failed to read backup file")
+		return
+	}
+
+	backup, err := services.ParseAccountBackup(data, ctrl.maxImportSize)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	ctrl.importBackup(c, backup, nil, services.AccountImportOptions{
+		SkipDuplicates: c.Query("skipDuplicates") == "true",
+	})
+}
+
+// ImportExternal 导入其他应用的导出文件，source 为 ticktick（滴答清单 CSV 备份）、todoist（项目 CSV、
+// 备份 zip 或 Sync API JSON）或 mstodo（Microsoft To Do 的 Graph API JSON）。
+// 先用 dryRun=true 预览导入报告；账户中已有的相同记录（如之前导入过的任务）合并而不重复创建，
+// 已完成的任务计入完成日期的统计
+func (ctrl *AccountController) ImportExternal(c *gin.Context) {
+	source := c.Param("source")
+	if !services.IsImportSource(source) {
+		utils.BadRequest(c, "Invalid import source")
+		return
+	}
+
+	name, data, err := ctrl.readImportFile(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.PayloadTooLarge(c, "Import file is too large")
+			return
+		}
+		utils.BadRequest(c, "Failed to read import file")
+		return
+	}
+
+	backup, warnings, err := services.ConvertExternalExport(source, services.ImportFile{Name: name, Data: data}, ctrl.maxImportSize)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	ctrl.importBackup(c, backup, warnings, services.AccountImportOptions{
+		SkipDuplicates:   true,
+		CountCompletions: true,
+	})
+}
+
+// importBackup 在事务中导入备份并返回导入报告，warnings 为转换文件时的提示，列在报告的提示之前
+func (ctrl *AccountController) importBackup(c *gin.Context, backup *services.AccountBackup, warnings []string, opts services.AccountImportOptions) {
+	userID := middleware.GetUserID(c)
+	dryRun := c.Query("dryRun") == "true"
+
+	tx := ctrl.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	report, err := services.ImportAccount(tx, userID, backup, time.Now(), opts)
+	if err != nil {
+		tx.Rollback()
+		utils.LogError("Failed to import account", zap.Uint64("user_id", userID), zap.Error(err))
+		utils.InternalError(c, "Failed to import account")
+		return
+	}
+	if len(warnings) > 0 {
+		report.Warnings = append(warnings, report.Warnings...)
+	}
+
+	if dryRun {
+		tx.Rollback()
+		report.DryRun = true
+		utils.Success(c, report)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalError(c, "Failed to commit transaction")
+		return
+	}
+
+	// 导入的数据量可能很大，通知所有设备全量刷新
+	ctrl.events.Publish(userID, services.EventResync, gin.H{"reason": "import"})
+
+	utils.Success(c, report)
+}
+
+// readImportFile 读取上传的文件，返回文件名和内容，超过大小上限时返回 *http.MaxBytesError。
+// 直接上传请求体时文件名取查询参数 filename
+func (ctrl *AccountController) readImportFile(c *gin.Context) (string, []byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ctrl.maxImportSize)
+
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		data, err := io.ReadAll(c.Request.Body)
+		return c.Query("filename"), data, err
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return "", nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	return header.Filename, data, err
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"fmt"
+	"on-the-way/backend/lunar"
+	"on-the-way/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalendarController struct{}
+
+func NewCalendarController() *CalendarController {
+	return &CalendarController{}
+}
+
+// LunarDateResponse 公历日期对应的农历信息
+type LunarDateResponse struct {
+	SolarDate   string `json:"solarDate"` // 格式：20251105
+	LunarYear   int    `json:"lunarYear"`
+	LunarMonth  int    `json:"lunarMonth"`
+	LunarDay    int    `json:"lunarDay"`
+	IsLeapMonth bool   `json:"isLeapMonth"`
+	MonthName   string `json:"monthName"`  // 如：闰六月
+	DayName     string `json:"dayName"`    // 如：初一
+	YearGanZhi  string `json:"yearGanZhi"` // 如：乙巳
+	Zodiac      string `json:"zodiac"`     // 如：蛇
+	Festival    string `json:"festival"`   // 传统节日，如：中秋节
+	LunarDate   string `json:"lunarDate"`  // 与 recurrenceLunarDate 相同的格式："MM-DD"
+	Text        string `json:"text"`       // 如：乙巳年闰六月初一
+}
+
+// GetLunarDate 公历转农历，date 格式为 20251105 或 2025-11-05，默认今天
+func (ctrl *CalendarController) GetLunarDate(c *gin.Context) {
+	dateStr := c.Query("date")
+
+	var date time.Time
+	var err error
+	switch len(dateStr) {
+	case 0:
+		date = utils.Now()
+	case 10:
+		date, err = time.Parse("2006-01-02", dateStr)
+	default:
+		date, err = utils.ParseDate(dateStr)
+	}
+	if err != nil {
+		utils.BadRequest(c, "Invalid date, expected format 20251105")
+		return
+	}
+
+	lunarDate, err := lunar.FromSolar(date)
+	if err != nil {
+		utils.BadRequest(c, "Date out of supported range (1900-2100)")
+		return
+	}
+
+	utils.Success(c, LunarDateResponse{
+		SolarDate:   utils.FormatDate(date),
+		LunarYear:   lunarDate.Year,
+		LunarMonth:  lunarDate.Month,
+		LunarDay:    lunarDate.Day,
+		IsLeapMonth: lunarDate.IsLeap,
+		MonthName:   lunarDate.MonthName(),
+		DayName:     lunarDate.DayName(),
+		YearGanZhi:  lunar.GanZhiYear(lunarDate.Year),
+		Zodiac:      lunar.Zodiac(lunarDate.Year),
+		Festival:    lunarDate.Festival(),
+		LunarDate:   formatLunarMonthDay(lunarDate),
+		Text:        lunarDate.String(),
+	})
+}
+
+// formatLunarMonthDay 格式化为 "MM-DD"
+func formatLunarMonthDay(d lunar.Date) string {
+	return fmt.Sprintf("%02d-%02d", d.Month, d.Day)
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChecklistItemRequest struct {
+	Title     string `json:"title" binding:"required"`
+	SortOrder *int   `json:"sortOrder"` // 不传时排在最后
+}
+
+// UpdateChecklistItemRequest 用于更新检查项，所有字段都是可选的
+type UpdateChecklistItemRequest struct {
+	Title       *string `json:"title"`
+	IsCompleted *bool   `json:"isCompleted"`
+	SortOrder   *int    `json:"sortOrder"`
+}
+
+// GetChecklist 获取任务的检查项
+func (ctrl *TaskController) GetChecklist(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	task, ok := ctrl.findTask(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var items []models.ChecklistItem
+	if err := ctrl.db.Where("task_id = ? AND user_id = ?", task.ID, userID).
+		Order("sort_order ASC, id ASC").
+		Find(&items).Error; err != nil {
+		utils.InternalError(c, "Failed to get checklist")
+		return
+	}
+
+	utils.Success(c, items)
+}
+
+// CreateChecklistItem 添加检查项
+func (ctrl *TaskController) CreateChecklistItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	task, ok := ctrl.findTask(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req ChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	sortOrder := 0
+	if req.SortOrder != nil {
+		sortOrder = *req.SortOrder
+	} else {
+		var maxOrder *int
+		ctrl.db.Model(&models.ChecklistItem{}).Where("task_id = ?", task.ID).Select("MAX(sort_order)").Scan(&maxOrder)
+		if maxOrder != nil {
+			sortOrder = *maxOrder + 1
+		}
+	}
+
+	item := models.ChecklistItem{
+		UserID:    userID,
+		TaskID:    task.ID,
+		Title:     req.Title,
+		SortOrder: sortOrder,
+	}
+
+	if err := ctrl.db.Create(&item).Error; err != nil {
+		utils.InternalError(c, "Failed to create checklist item")
+		return
+	}
+
+	ctrl.publishTaskChanged(userID, "checklist", gin.H{"taskId": task.ID, "item": item})
+
+	utils.Success(c, item)
+}
+
+// UpdateChecklistItem 更新检查项（修改标题、勾选或排序）
+func (ctrl *TaskController) UpdateChecklistItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	item, ok := ctrl.findChecklistItem(c, userID)
+	if !ok {
+		return
+	}
+
+	var req UpdateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if req.Title != nil {
+		item.Title = *req.Title
+	}
+	if req.IsCompleted != nil && *req.IsCompleted != item.IsCompleted {
+		item.IsCompleted = *req.IsCompleted
+		item.CompletedAt = ""
+		if item.IsCompleted {
+			item.CompletedAt = time.Now().Format("20060102 15:04")
+		}
+	}
+	if req.SortOrder != nil {
+		item.SortOrder = *req.SortOrder
+	}
+
+	if err := ctrl.db.Save(&item).Error; err != nil {
+		utils.InternalError(c, "Failed to update checklist item")
+		return
+	}
+
+	ctrl.publishTaskChanged(userID, "checklist", gin.H{"taskId": item.TaskID, "item": item})
+
+	utils.Success(c, item)
+}
+
+// DeleteChecklistItem 删除检查项
+func (ctrl *TaskController) DeleteChecklistItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	item, ok := ctrl.findChecklistItem(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.db.Delete(&item).Error; err != nil {
+		utils.InternalError(c, "Failed to delete checklist item")
+		return
+	}
+
+	ctrl.publishTaskChanged(userID, "checklist", gin.H{"taskId": item.TaskID, "deletedItemId": item.ID})
+
+	utils.Success(c, gin.H{"message": "Checklist item deleted successfully"})
+}
+
+// findChecklistItem 查找路径中 :id 任务下的 :itemId 检查项
+func (ctrl *TaskController) findChecklistItem(c *gin.Context, userID uint64) (models.ChecklistItem, bool) {
+	var item models.ChecklistItem
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return item, false
+	}
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid checklist item ID")
+		return item, false
+	}
+
+	if err := ctrl.db.Where("id = ? AND task_id = ? AND user_id = ?", itemID, taskID, userID).
+		First(&item).Error; err != nil {
+		utils.NotFound(c, "Checklist item not found")
+		return item, false
+	}
+	return item, true
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/models"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type DependencyRequest struct {
+	BlockedByID uint64 `json:"blockedById" binding:"required"` // 前置任务ID
+}
+
+// GetDependencies 获取任务的前置任务（blockedBy）和被它阻塞的任务（blocking）
+func (ctrl *TaskController) GetDependencies(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	task, ok := ctrl.findTask(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var blockedBy []models.Task
+	if err := ctrl.db.Joins("JOIN task_dependencies ON task_dependencies.blocked_by_id = tasks.id").
+		Where("task_dependencies.task_id = ? AND tasks.user_id = ?", task.ID, userID).
+		Order("task_dependencies.id ASC").
+		Find(&blockedBy).Error; err != nil {
+		utils.InternalError(c, "Failed to get dependencies")
+		return
+	}
+
+	var blocking []models.Task
+	if err := ctrl.db.Joins("JOIN task_dependencies ON task_dependencies.task_id = tasks.id").
+		Where("task_dependencies.blocked_by_id = ? AND tasks.user_id = ?", task.ID, userID).
+		Order("task_dependencies.id ASC").
+		Find(&blocking).Error; err != nil {
+		utils.InternalError(c, "Failed to get dependencies")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"taskId":    task.ID,
+		"blockedBy": buildTaskResponses(ctrl.db, blockedBy),
+		"blocking":  buildTaskResponses(ctrl.db, blocking),
+	})
+}
+
+// AddDependency 添加前置任务，不允许形成循环依赖
+func (ctrl *TaskController) AddDependency(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	task, ok := ctrl.findTask(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req DependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if req.BlockedByID == task.ID {
+		utils.BadRequest(c, "A task cannot be blocked by itself")
+		return
+	}
+
+	var blocker models.Task
+	if err := ctrl.db.Where("id = ? AND user_id = ?", req.BlockedByID, userID).First(&blocker).Error; err != nil {
+		utils.NotFound(c, "Blocking task not found")
+		return
+	}
+
+	// 已存在时直接返回
+	var dependency models.TaskDependency
+	if err := ctrl.db.Where("task_id = ? AND blocked_by_id = ?", task.ID, blocker.ID).First(&dependency).Error; err == nil {
+		utils.Success(c, dependency)
+		return
+	}
+
+	cycle, err := services.WouldCreateDependencyCycle(ctrl.db, task.ID, blocker.ID)
+	if err != nil {
+		utils.InternalError(c, "Failed to check dependencies")
+		return
+	}
+	if cycle {
+		utils.Conflict(c, "Dependency would create a cycle")
+		return
+	}
+
+	dependency = models.TaskDependency{
+		UserID:      userID,
+		TaskID:      task.ID,
+		BlockedByID: blocker.ID,
+	}
+	if err := ctrl.db.Create(&dependency).Error; err != nil {
+		utils.Logger.Error("Failed to create dependency", zap.Error(err))
+		utils.InternalError(c, "Failed to create dependency")
+		return
+	}
+
+	ctrl.publishTaskChanged(userID, "dependency_added", gin.H{"taskId": task.ID, "blockedById": blocker.ID})
+
+	utils.Success(c, dependency)
+}
+
+// RemoveDependency 移除前置任务
+func (ctrl *TaskController) RemoveDependency(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	task, ok := ctrl.findTask(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	blockedByID, err := strconv.ParseUint(c.Param("blockedById"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid blocking task ID")
+		return
+	}
+
+	result := ctrl.db.Where("task_id = ? AND blocked_by_id = ? AND user_id = ?", task.ID, blockedByID, userID).
+		Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		utils.InternalError(c, "Failed to remove dependency")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFound(c, "Dependency not found")
+		return
+	}
+
+	ctrl.publishTaskChanged(userID, "dependency_removed", gin.H{"taskId": task.ID, "blockedById": blockedByID})
+
+	utils.Success(c, gin.H{"message": "Dependency removed successfully"})
+}
+
+// strictDependencies 用户是否开启了严格依赖模式
+func (ctrl *TaskController) strictDependencies(userID uint64) bool {
+	var settings models.UserSettings
+	if err := ctrl.db.Select("strict_dependencies").Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return false
+	}
+	return settings.StrictDependencies
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// checkIfMatch 校验 If-Match 请求头，与资源当前版本不一致时返回 412 和服务器上的当前数据，客户端据此合并后重试
+func checkIfMatch(c *gin.Context, version uint64, current interface{}) bool {
+	if utils.IfMatch(c, version) {
+		return true
+	}
+	utils.SetETag(c, version)
+	utils.PreconditionFailed(c, "Resource has been modified", current)
+	return false
+}
+
+// saveVersioned 保存 value，只在数据库中的版本号仍为 version（读取时的版本）时生效。
+// 读取之后其他请求先修改了记录时返回 409 和服务器上的当前数据（按 preloads 加载关联）；返回 false 时已写入响应
+func saveVersioned[T any](c *gin.Context, db *gorm.DB, value *T, id, version uint64, message string, preloads ...string) bool {
+	err := services.SaveVersioned(db, value, version)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, services.ErrVersionConflict) {
+		utils.InternalError(c, message)
+		return false
+	}
+
+	var current T
+	query := db
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	if err := query.First(&current, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFound(c, "Resource not found")
+		} else {
+			utils.InternalError(c, message)
+		}
+		return false
+	}
+	utils.ErrorWithData(c, http.StatusConflict, "Resource was modified by another request", current)
+	return false
+}
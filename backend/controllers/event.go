@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval 心跳间隔，防止代理因空闲断开连接
+const heartbeatInterval = 25 * time.Second
+
+type EventController struct {
+	hub *services.EventHub
+}
+
+func NewEventController(hub *services.EventHub) *EventController {
+	return &EventController{hub: hub}
+}
+
+// Stream 以 Server-Sent Events 推送用户的实时事件
+// 断线重连时浏览器会自动携带 Last-Event-ID 请求头，也可以通过 lastEventId 参数指定
+func (ctrl *EventController) Stream(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	lastEventIDStr := c.GetHeader("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = c.Query("lastEventId")
+	}
+	lastEventID, _ := strconv.ParseUint(lastEventIDStr, 10, 64)
+
+	sub, replay, complete := ctrl.hub.Subscribe(userID, lastEventID)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	// 错过的事件已超出重放窗口，通知客户端重新拉取数据
+	if !complete {
+		writeSSEEvent(w, services.Event{Type: services.EventResync, CreatedAt: time.Now()})
+	}
+	for _, event := range replay {
+		writeSSEEvent(w, event)
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// 订阅被关闭（服务退出或客户端消费过慢），客户端会自动重连
+				return
+			}
+			writeSSEEvent(w, event)
+			w.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			w.Flush()
+		}
+	}
+}
+
+// writeSSEEvent 按 SSE 格式写入一条事件
+func writeSSEEvent(w io.Writer, event services.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if event.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
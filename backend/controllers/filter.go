@@ -2,9 +2,12 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"on-the-way/backend/middleware"
 	"on-the-way/backend/models"
+	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -59,6 +62,12 @@ func (ctrl *FilterController) CreateFilter(c *gin.Context) {
 		return
 	}
 
+	// 旧版扁平配置转换为规则树，并校验规则
+	if err := services.NormalizeFilterConfig(req.FilterConfig); err != nil {
+		utils.BadRequest(c, "Invalid filter configuration: "+err.Error())
+		return
+	}
+
 	// 序列化 FilterConfig 为 JSON 字符串
 	configJSON, err := json.Marshal(req.FilterConfig)
 	if err != nil {
@@ -101,6 +110,9 @@ func (ctrl *FilterController) UpdateFilter(c *gin.Context) {
 		utils.NotFound(c, "Filter not found")
 		return
 	}
+	if !checkIfMatch(c, filter.Version, filter) {
+		return
+	}
 
 	var req FilterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -108,6 +120,12 @@ func (ctrl *FilterController) UpdateFilter(c *gin.Context) {
 		return
 	}
 
+	// 旧版扁平配置转换为规则树，并校验规则
+	if err := services.NormalizeFilterConfig(req.FilterConfig); err != nil {
+		utils.BadRequest(c, "Invalid filter configuration: "+err.Error())
+		return
+	}
+
 	// 序列化 FilterConfig 为 JSON 字符串
 	configJSON, err := json.Marshal(req.FilterConfig)
 	if err != nil {
@@ -119,11 +137,11 @@ func (ctrl *FilterController) UpdateFilter(c *gin.Context) {
 	filter.Icon = req.Icon
 	filter.FilterConfig = string(configJSON)
 
-	if err := ctrl.db.Save(&filter).Error; err != nil {
-		utils.InternalError(c, "Failed to update filter")
+	if !saveVersioned(c, ctrl.db, &filter, filter.ID, filter.Version, "Failed to update filter") {
 		return
 	}
 
+	utils.SetETag(c, filter.Version)
 	utils.Success(c, filter)
 }
 
@@ -132,6 +150,16 @@ func (ctrl *FilterController) DeleteFilter(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	filterID := c.Param("id")
 
+	var filter models.Filter
+	if err := ctrl.db.Where("id = ? AND user_id = ?", filterID, userID).
+		First(&filter).Error; err != nil {
+		utils.NotFound(c, "Filter not found")
+		return
+	}
+	if !checkIfMatch(c, filter.Version, filter) {
+		return
+	}
+
 	result := ctrl.db.Where("id = ? AND user_id = ?", filterID, userID).
 		Delete(&models.Filter{})
 
@@ -159,6 +187,9 @@ func (ctrl *FilterController) TogglePin(c *gin.Context) {
 		utils.NotFound(c, "Filter not found")
 		return
 	}
+	if !checkIfMatch(c, filter.Version, filter) {
+		return
+	}
 
 	var req FilterTogglePinRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -168,11 +199,11 @@ func (ctrl *FilterController) TogglePin(c *gin.Context) {
 
 	filter.IsPinned = req.IsPinned
 
-	if err := ctrl.db.Save(&filter).Error; err != nil {
-		utils.InternalError(c, "Failed to toggle pin")
+	if !saveVersioned(c, ctrl.db, &filter, filter.ID, filter.Version, "Failed to toggle pin") {
 		return
 	}
 
+	utils.SetETag(c, filter.Version)
 	utils.Success(c, filter)
 }
 
@@ -205,3 +236,197 @@ func (ctrl *FilterController) ReorderFilters(c *gin.Context) {
 
 	utils.Success(c, gin.H{"message": "Filters reordered successfully"})
 }
+
+// 过滤器任务分页
+const (
+	defaultFilterPageSize = 50
+	maxFilterPageSize     = 200
+)
+
+// FilterPreviewRequest 预览未保存的过滤器配置
+type FilterPreviewRequest struct {
+	FilterConfig    *models.FilterConfig `json:"filterConfig" binding:"required"`
+	Status          string               `json:"status"`    // todo（默认，规则含状态条件时以规则为准）、completed、abandoned、all
+	SortBy          string               `json:"sortBy"`    // time（默认）、title、tag、priority
+	SortOrder       string               `json:"sortOrder"` // asc（默认）、desc
+	Page            int                  `json:"page"`
+	PageSize        int                  `json:"pageSize"`
+	IncludeSubtasks bool                 `json:"includeSubtasks"`
+}
+
+// FilterTasksResponse 过滤器匹配的任务（分页）
+type FilterTasksResponse struct {
+	Tasks    []TaskResponse `json:"tasks"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"pageSize"`
+}
+
+// filterTaskOptions 过滤器查询选项
+type filterTaskOptions struct {
+	Status          string
+	SortBy          string
+	SortOrder       string
+	HideCompleted   bool
+	Page            int
+	PageSize        int
+	IncludeSubtasks bool
+}
+
+// GetFilterTasks 获取已保存过滤器匹配的任务，排序使用该过滤器的视图配置
+// 查询参数：status、sortBy、sortOrder（覆盖视图配置）、page、pageSize、includeSubtasks
+func (ctrl *FilterController) GetFilterTasks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	filterID := c.Param("id")
+
+	var filter models.Filter
+	if err := ctrl.db.Where("id = ? AND user_id = ?", filterID, userID).
+		First(&filter).Error; err != nil {
+		utils.NotFound(c, "Filter not found")
+		return
+	}
+
+	var config models.FilterConfig
+	if filter.FilterConfig != "" {
+		if err := json.Unmarshal([]byte(filter.FilterConfig), &config); err != nil {
+			utils.BadRequest(c, "Invalid filter configuration")
+			return
+		}
+	}
+
+	options := filterTaskOptions{
+		Status:          c.Query("status"),
+		SortBy:          "time",
+		SortOrder:       "asc",
+		IncludeSubtasks: c.Query("includeSubtasks") == "true",
+	}
+
+	var viewConfig models.ViewConfig
+	if err := ctrl.db.Where("user_id = ? AND entity_type = ? AND entity_id = ?", userID, "filter", filter.ID).
+		First(&viewConfig).Error; err == nil {
+		options.SortBy = viewConfig.SortBy
+		options.SortOrder = viewConfig.SortOrder
+		options.HideCompleted = viewConfig.HideCompleted
+	}
+	if sortBy := c.Query("sortBy"); sortBy != "" {
+		options.SortBy = sortBy
+	}
+	if sortOrder := c.Query("sortOrder"); sortOrder != "" {
+		options.SortOrder = sortOrder
+	}
+
+	var err error
+	if options.Page, err = parsePositiveQuery(c, "page"); err != nil {
+		utils.BadRequest(c, "Invalid page")
+		return
+	}
+	if options.PageSize, err = parsePositiveQuery(c, "pageSize"); err != nil {
+		utils.BadRequest(c, "Invalid pageSize")
+		return
+	}
+
+	ctrl.respondFilterTasks(c, userID, &config, options)
+}
+
+// PreviewFilter 按未保存的过滤器配置查询任务，用于编辑过滤器时预览结果
+func (ctrl *FilterController) PreviewFilter(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req FilterPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if req.Page < 0 || req.PageSize < 0 {
+		utils.BadRequest(c, "Invalid pagination")
+		return
+	}
+
+	ctrl.respondFilterTasks(c, userID, req.FilterConfig, filterTaskOptions{
+		Status:          req.Status,
+		SortBy:          req.SortBy,
+		SortOrder:       req.SortOrder,
+		Page:            req.Page,
+		PageSize:        req.PageSize,
+		IncludeSubtasks: req.IncludeSubtasks,
+	})
+}
+
+// respondFilterTasks 执行过滤器查询并返回分页结果
+func (ctrl *FilterController) respondFilterTasks(c *gin.Context, userID uint64, config *models.FilterConfig, options filterTaskOptions) {
+	if options.Page == 0 {
+		options.Page = 1
+	}
+	if options.PageSize == 0 {
+		options.PageSize = defaultFilterPageSize
+	}
+	if options.PageSize > maxFilterPageSize {
+		options.PageSize = maxFilterPageSize
+	}
+
+	query, err := services.ApplyFilterConfig(ctrl.db.Model(&models.Task{}), userID, config, utils.Today())
+	if err != nil {
+		utils.BadRequest(c, "Invalid filter configuration: "+err.Error())
+		return
+	}
+
+	switch options.Status {
+	case "":
+		// 规则中已有状态条件时以规则为准，否则默认只查待办
+		if !services.FilterConfigUsesField(config, "status") {
+			query = query.Where("tasks.status = ?", "todo")
+		}
+	case "todo":
+		query = query.Where("tasks.status = ?", "todo")
+	case "completed", "abandoned":
+		query = query.Where("tasks.status = ?", options.Status)
+	case "all":
+		if options.HideCompleted {
+			query = query.Where("tasks.status <> ?", "completed")
+		}
+	default:
+		utils.BadRequest(c, "Invalid status")
+		return
+	}
+
+	if !options.IncludeSubtasks {
+		query = query.Where("tasks.parent_id IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.InternalError(c, "Failed to count tasks")
+		return
+	}
+
+	var tasks []models.Task
+	if err := services.ApplyTaskSort(query, options.SortBy, options.SortOrder).
+		Offset((options.Page - 1) * options.PageSize).
+		Limit(options.PageSize).
+		Preload("Tags").
+		Preload("List").
+		Find(&tasks).Error; err != nil {
+		utils.InternalError(c, "Failed to get tasks")
+		return
+	}
+
+	utils.Success(c, FilterTasksResponse{
+		Tasks:    buildTaskResponses(ctrl.db, tasks),
+		Total:    total,
+		Page:     options.Page,
+		PageSize: options.PageSize,
+	})
+}
+
+// parsePositiveQuery 解析正整数查询参数，未传时返回 0
+func parsePositiveQuery(c *gin.Context, key string) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s", key)
+	}
+	return n, nil
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"on-the-way/backend/middleware"
 	"on-the-way/backend/models"
+	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
 	"strconv"
 
@@ -167,6 +168,7 @@ func (ctrl *FolderController) GetFolder(c *gin.Context) {
 		TodoCount: folderTodoCount,
 	}
 
+	utils.SetETag(c, folder.Version)
 	utils.Success(c, folderResp)
 }
 
@@ -186,6 +188,9 @@ func (ctrl *FolderController) UpdateFolder(c *gin.Context) {
 		utils.NotFound(c, "Folder not found")
 		return
 	}
+	if !checkIfMatch(c, folder.Version, folder) {
+		return
+	}
 
 	var req FolderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -199,15 +204,15 @@ func (ctrl *FolderController) UpdateFolder(c *gin.Context) {
 	folder.SortOrder = req.SortOrder
 	folder.IsExpanded = req.IsExpanded
 
-	if err := ctrl.db.Save(&folder).Error; err != nil {
-		utils.InternalError(c, "Failed to update folder")
+	if !saveVersioned(c, ctrl.db, &folder, folder.ID, folder.Version, "Failed to update folder") {
 		return
 	}
 
+	utils.SetETag(c, folder.Version)
 	utils.Success(c, folder)
 }
 
-// DeleteFolder 删除文件夹
+// DeleteFolder 删除文件夹，mode 和 targetListId 与删除清单相同，指定时文件夹下的清单一并删除
 func (ctrl *FolderController) DeleteFolder(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	folderIDStr := c.Param("id")
@@ -224,6 +229,23 @@ func (ctrl *FolderController) DeleteFolder(c *gin.Context) {
 		utils.NotFound(c, "Folder not found")
 		return
 	}
+	if !checkIfMatch(c, folder.Version, folder) {
+		return
+	}
+
+	// 未指定 mode 时清单移到顶层；指定时按相同方式删除文件夹下的清单（系统清单仍移到顶层）
+	listIDs := []uint64{}
+	opts := services.ListDeleteOptions{}
+	if c.Query("mode") != "" {
+		ctrl.db.Model(&models.List{}).
+			Where("folder_id = ? AND user_id = ? AND is_system = ?", folderID, userID, false).
+			Pluck("id", &listIDs)
+
+		var ok bool
+		if opts, ok = listDeleteOptions(c, ctrl.db, userID, listIDs); !ok {
+			return
+		}
+	}
 
 	// 开始事务
 	tx := ctrl.db.Begin()
@@ -233,7 +255,15 @@ func (ctrl *FolderController) DeleteFolder(c *gin.Context) {
 		}
 	}()
 
-	// 将该文件夹下的所有清单移到顶层（folder_id 设为 NULL）
+	// 删除文件夹下的清单
+	deletedTaskIDs, err := services.DeleteLists(tx, userID, listIDs, opts)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalError(c, "Failed to delete lists")
+		return
+	}
+
+	// 将该文件夹下剩余的清单移到顶层（folder_id 设为 NULL）
 	if err := tx.Model(&models.List{}).
 		Where("folder_id = ? AND user_id = ?", folderID, userID).
 		Update("folder_id", nil).Error; err != nil {
@@ -250,7 +280,11 @@ func (ctrl *FolderController) DeleteFolder(c *gin.Context) {
 	}
 
 	tx.Commit()
-	utils.Success(c, gin.H{"message": "Folder deleted successfully"})
+	utils.Success(c, gin.H{
+		"message":        "Folder deleted successfully",
+		"deletedListIds": listIDs,
+		"deletedTaskIds": deletedTaskIDs,
+	})
 }
 
 // ToggleExpand 切换文件夹展开/折叠状态
@@ -269,13 +303,16 @@ func (ctrl *FolderController) ToggleExpand(c *gin.Context) {
 		utils.NotFound(c, "Folder not found")
 		return
 	}
+	if !checkIfMatch(c, folder.Version, folder) {
+		return
+	}
 
 	folder.IsExpanded = !folder.IsExpanded
 
-	if err := ctrl.db.Save(&folder).Error; err != nil {
-		utils.InternalError(c, "Failed to toggle expand")
+	if !saveVersioned(c, ctrl.db, &folder, folder.ID, folder.Version, "Failed to toggle expand") {
 		return
 	}
 
+	utils.SetETag(c, folder.Version)
 	utils.Success(c, folder)
 }
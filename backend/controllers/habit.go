@@ -228,6 +228,9 @@ func (ctrl *HabitController) UpdateHabit(c *gin.Context) {
 		utils.NotFound(c, "Habit not found")
 		return
 	}
+	if !checkIfMatch(c, habit.Version, habit) {
+		return
+	}
 
 	var req HabitRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -248,8 +251,7 @@ func (ctrl *HabitController) UpdateHabit(c *gin.Context) {
 	habit.ReminderTimes = req.ReminderTimes
 	habit.AutoJournal = req.AutoJournal
 
-	if err := ctrl.db.Save(&habit).Error; err != nil {
-		utils.InternalError(c, "Failed to update habit")
+	if !saveVersioned(c, ctrl.db, &habit, habit.ID, habit.Version, "Failed to update habit") {
 		return
 	}
 
@@ -261,6 +263,7 @@ func (ctrl *HabitController) UpdateHabit(c *gin.Context) {
 		ctrl.db.Where("entity_type = ? AND entity_id = ?", "habit", habit.ID).Delete(&models.Reminder{})
 	}
 
+	utils.SetETag(c, habit.Version)
 	utils.Success(c, habit)
 }
 
@@ -268,6 +271,15 @@ func (ctrl *HabitController) DeleteHabit(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	habitID := c.Param("id")
 
+	var habit models.Habit
+	if err := ctrl.db.Where("id = ? AND user_id = ?", habitID, userID).First(&habit).Error; err != nil {
+		utils.NotFound(c, "Habit not found")
+		return
+	}
+	if !checkIfMatch(c, habit.Version, habit) {
+		return
+	}
+
 	// 删除相关提醒
 	ctrl.db.Where("entity_type = ? AND entity_id = ?", "habit", habitID).Delete(&models.Reminder{})
 
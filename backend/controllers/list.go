@@ -3,6 +3,7 @@ package controllers
 import (
 	"on-the-way/backend/middleware"
 	"on-the-way/backend/models"
+	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
 	"strconv"
 
@@ -144,6 +145,9 @@ func (ctrl *ListController) UpdateList(c *gin.Context) {
 		utils.NotFound(c, "List not found")
 		return
 	}
+	if !checkIfMatch(c, list.Version, list) {
+		return
+	}
 
 	var req ListRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -170,8 +174,7 @@ func (ctrl *ListController) UpdateList(c *gin.Context) {
 	list.Icon = req.Icon
 	list.SortOrder = req.SortOrder
 
-	if err := ctrl.db.Save(&list).Error; err != nil {
-		utils.InternalError(c, "Failed to update list")
+	if !saveVersioned(c, ctrl.db, &list, list.ID, list.Version, "Failed to update list") {
 		return
 	}
 
@@ -212,9 +215,11 @@ func (ctrl *ListController) UpdateList(c *gin.Context) {
 		}
 	}
 
+	utils.SetETag(c, list.Version)
 	utils.Success(c, list)
 }
 
+// DeleteList 删除清单，mode 和 targetListId 指定其中任务的处理方式，见 listDeleteOptions
 func (ctrl *ListController) DeleteList(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	listIDStr := c.Param("id")
@@ -231,6 +236,9 @@ func (ctrl *ListController) DeleteList(c *gin.Context) {
 		utils.NotFound(c, "List not found")
 		return
 	}
+	if !checkIfMatch(c, list.Version, list) {
+		return
+	}
 
 	// 禁止删除系统清单
 	if list.IsSystem {
@@ -238,13 +246,64 @@ func (ctrl *ListController) DeleteList(c *gin.Context) {
 		return
 	}
 
-	// 删除清单
-	if err := ctrl.db.Delete(&list).Error; err != nil {
+	opts, ok := listDeleteOptions(c, ctrl.db, userID, []uint64{list.ID})
+	if !ok {
+		return
+	}
+
+	// 删除清单并按删除方式处理其中的任务
+	var deletedTaskIDs []uint64
+	err = ctrl.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		deletedTaskIDs, err = services.DeleteLists(tx, userID, []uint64{list.ID}, opts)
+		return err
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to delete list", zap.Uint64("listId", list.ID), zap.Error(err))
 		utils.InternalError(c, "Failed to delete list")
 		return
 	}
 
-	utils.Success(c, gin.H{"message": "List deleted successfully"})
+	utils.Success(c, gin.H{"message": "List deleted successfully", "deletedTaskIds": deletedTaskIDs})
+}
+
+// listDeleteOptions 解析删除清单时任务的处理方式：mode 为 inbox（默认）、move 或 delete，
+// move 时 targetListId 指定目标清单，目标不能是本次要删除的清单 excluded
+func listDeleteOptions(c *gin.Context, db *gorm.DB, userID uint64, excluded []uint64) (services.ListDeleteOptions, bool) {
+	opts := services.ListDeleteOptions{Mode: c.DefaultQuery("mode", services.ListDeleteModeInbox)}
+	if !services.IsListDeleteMode(opts.Mode) {
+		utils.BadRequest(c, "Invalid delete mode")
+		return opts, false
+	}
+
+	switch opts.Mode {
+	case services.ListDeleteModeInbox:
+		var inbox models.List
+		if err := db.Where("user_id = ? AND is_default = ?", userID, true).First(&inbox).Error; err != nil {
+			utils.InternalError(c, "Default list not found")
+			return opts, false
+		}
+		opts.TargetListID = inbox.ID
+	case services.ListDeleteModeMove:
+		targetID, err := strconv.ParseUint(c.Query("targetListId"), 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "Invalid target list ID")
+			return opts, false
+		}
+		for _, id := range excluded {
+			if id == targetID {
+				utils.BadRequest(c, "Target list is being deleted")
+				return opts, false
+			}
+		}
+		var target models.List
+		if err := db.Where("id = ? AND user_id = ?", targetID, userID).First(&target).Error; err != nil {
+			utils.NotFound(c, "Target list not found")
+			return opts, false
+		}
+		opts.TargetListID = target.ID
+	}
+	return opts, true
 }
 
 // MoveList 移动清单到文件夹或顶层
@@ -272,6 +331,9 @@ func (ctrl *ListController) MoveList(c *gin.Context) {
 		utils.NotFound(c, "List not found")
 		return
 	}
+	if !checkIfMatch(c, list.Version, list) {
+		return
+	}
 
 	// 验证文件夹
 	if req.FolderID != nil {
@@ -285,10 +347,10 @@ func (ctrl *ListController) MoveList(c *gin.Context) {
 	list.FolderID = req.FolderID
 	list.SortOrder = req.SortOrder
 
-	if err := ctrl.db.Save(&list).Error; err != nil {
-		utils.InternalError(c, "Failed to move list")
+	if !saveVersioned(c, ctrl.db, &list, list.ID, list.Version, "Failed to move list") {
 		return
 	}
 
+	utils.SetETag(c, list.Version)
 	utils.Success(c, list)
 }
@@ -3,6 +3,7 @@ package controllers
 import (
 	"on-the-way/backend/middleware"
 	"on-the-way/backend/models"
+	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
 	"strconv"
 
@@ -11,11 +12,12 @@ import (
 )
 
 type PomodoroController struct {
-	db *gorm.DB
+	db     *gorm.DB
+	events *services.EventHub
 }
 
-func NewPomodoroController(db *gorm.DB) *PomodoroController {
-	return &PomodoroController{db: db}
+func NewPomodoroController(db *gorm.DB, events *services.EventHub) *PomodoroController {
+	return &PomodoroController{db: db, events: events}
 }
 
 type PomodoroStartRequest struct {
@@ -42,6 +44,9 @@ func (ctrl *PomodoroController) Start(c *gin.Context) {
 		return
 	}
 
+	// 同步番茄钟状态到其他设备
+	ctrl.events.Publish(userID, services.EventPomodoroState, gin.H{"state": "running", "pomodoro": pomodoro})
+
 	utils.Success(c, pomodoro)
 }
 
@@ -89,6 +94,8 @@ func (ctrl *PomodoroController) End(c *gin.Context) {
 		ctrl.db.Save(&stats)
 	}
 
+	ctrl.events.Publish(userID, services.EventPomodoroState, gin.H{"state": "ended", "pomodoro": pomodoro})
+
 	utils.Success(c, pomodoro)
 }
 
@@ -13,12 +13,14 @@ import (
 type ReminderController struct {
 	db      *gorm.DB
 	service *services.ReminderService
+	events  *services.EventHub
 }
 
-func NewReminderController(db *gorm.DB) *ReminderController {
+func NewReminderController(db *gorm.DB, events *services.EventHub) *ReminderController {
 	return &ReminderController{
 		db:      db,
 		service: services.NewReminderService(db),
+		events:  events,
 	}
 }
 
@@ -37,6 +39,7 @@ func (ctrl *ReminderController) GetActiveReminders(c *gin.Context) {
 
 // MarkReminderSent 标记提醒已发送
 func (ctrl *ReminderController) MarkReminderSent(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	reminderIDStr := c.Param("id")
 
 	reminderID, err := strconv.ParseUint(reminderIDStr, 10, 64)
@@ -50,11 +53,15 @@ func (ctrl *ReminderController) MarkReminderSent(c *gin.Context) {
 		return
 	}
 
+	// 通知其他设备关闭弹窗
+	ctrl.events.Publish(userID, services.EventReminderDismissed, gin.H{"reminderId": reminderID, "action": "sent"})
+
 	utils.Success(c, nil)
 }
 
 // SnoozeReminder 延迟提醒
 func (ctrl *ReminderController) SnoozeReminder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	reminderIDStr := c.Param("id")
 	minutesStr := c.Query("minutes")
 
@@ -74,6 +81,8 @@ func (ctrl *ReminderController) SnoozeReminder(c *gin.Context) {
 		return
 	}
 
+	ctrl.events.Publish(userID, services.EventReminderDismissed, gin.H{"reminderId": reminderID, "action": "snoozed"})
+
 	utils.Success(c, nil)
 }
 
@@ -93,6 +102,8 @@ func (ctrl *ReminderController) DeleteReminder(c *gin.Context) {
 		return
 	}
 
+	ctrl.events.Publish(userID, services.EventReminderDismissed, gin.H{"reminderId": reminderID, "action": "deleted"})
+
 	utils.Success(c, nil)
 }
 
@@ -3,12 +3,22 @@ package controllers
 import (
 	"on-the-way/backend/middleware"
 	"on-the-way/backend/models"
+	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// 搜索结果分页
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+	searchSnippetLength   = 80 // 描述片段的最大字符数
+)
+
 type SearchController struct {
 	db *gorm.DB
 }
@@ -17,37 +27,238 @@ func NewSearchController(db *gorm.DB) *SearchController {
 	return &SearchController{db: db}
 }
 
+// SearchHighlights 高亮后的文本，关键词用 <mark> 标出，其余内容已做 HTML 转义
+type SearchHighlights struct {
+	Title   string `json:"title"`
+	Snippet string `json:"snippet,omitempty"` // 描述中匹配位置附近的片段
+}
+
+// SearchTaskResult 任务搜索结果
+type SearchTaskResult struct {
+	models.Task
+	Score      float64          `json:"score"` // 相关度（BM25），越大越相关，没有关键词时为 0
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// SearchHabitResult 习惯搜索结果
+type SearchHabitResult struct {
+	models.Habit
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// SearchCountdownResult 倒数日搜索结果
+type SearchCountdownResult struct {
+	models.Countdown
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// searchHit 全文索引命中的记录
+type searchHit struct {
+	ID    uint64
+	Score float64
+}
+
+// Search 搜索任务、清单、标签、习惯和倒数日
+// q 支持查询语法，如 tag:work list:"Side project" due<2025-12-01 priority>=2 is:recurring -status:completed "exact phrase"，
+// 语法见 services.ParseSearchQuery；关键词通过全文索引匹配，也可以输入拼音或首字母（如 jdgh 匹配"季度规划"），
+// 较长的关键词容许少量拼写错误。任务按相关度排序并分页（page、pageSize）；
+// 清单、标签、习惯和倒数日只按其中的自由文本匹配名称
 func (ctrl *SearchController) Search(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	query := c.Query("q")
 
-	if query == "" {
+	if strings.TrimSpace(query) == "" {
 		utils.BadRequest(c, "Search query is required")
 		return
 	}
 
+	page, err := parsePositiveQuery(c, "page")
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	pageSize, err := parsePositiveQuery(c, "pageSize")
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultSearchPageSize
+	}
+	if pageSize > maxSearchPageSize {
+		pageSize = maxSearchPageSize
+	}
+
+	parsed, err := services.ParseSearchQuery(query)
+	if err != nil {
+		utils.BadRequest(c, "Invalid search query: "+err.Error())
+		return
+	}
+
+	if err := services.SyncSearchIndex(ctrl.db); err != nil {
+		utils.Logger.Error("Failed to sync search index", zap.Error(err))
+		utils.InternalError(c, "Failed to search")
+		return
+	}
+
 	// 搜索任务
-	var tasks []models.Task
-	ctrl.db.Where("user_id = ? AND (title LIKE ? OR description LIKE ?)", userID, "%"+query+"%", "%"+query+"%").
-		Limit(20).
-		Find(&tasks)
-
-	// 搜索清单
-	var lists []models.List
-	ctrl.db.Where("user_id = ? AND name LIKE ?", userID, "%"+query+"%").
-		Limit(10).
-		Find(&lists)
-
-	// 搜索标签
-	var tags []models.Tag
-	ctrl.db.Where("user_id = ? AND name LIKE ?", userID, "%"+query+"%").
-		Limit(10).
-		Find(&tags)
+	taskQuery, err := services.ApplySearchRule(ctrl.db.Model(&models.Task{}).Where("tasks.user_id = ?", userID), userID, parsed.Rules, utils.Today())
+	if err != nil {
+		utils.BadRequest(c, "Invalid search query: "+err.Error())
+		return
+	}
+
+	var total int64
+	if err := taskQuery.Count(&total).Error; err != nil {
+		utils.InternalError(c, "Failed to search tasks")
+		return
+	}
+
+	match := services.BuildSearchMatchAny(parsed.Terms)
+	if match != "" {
+		taskQuery = taskQuery.
+			Joins("LEFT JOIN (SELECT entity_id, "+services.SearchRankExpr+" AS rank FROM search_index WHERE search_index MATCH ? AND entity_type = ?) AS fts ON fts.entity_id = tasks.id", match, services.SearchEntityTask).
+			Select("tasks.id AS id, COALESCE(-fts.rank, 0) AS score").
+			Order("fts.rank IS NULL").
+			Order("fts.rank ASC")
+	} else {
+		taskQuery = taskQuery.Select("tasks.id AS id, 0 AS score")
+	}
+
+	var hits []searchHit
+	if err := services.ApplyTaskSort(taskQuery, "time", "asc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&hits).Error; err != nil {
+		utils.Logger.Error("Failed to search tasks", zap.Error(err))
+		utils.InternalError(c, "Failed to search tasks")
+		return
+	}
+
+	tasks, err := ctrl.loadTaskResults(hits, parsed.Terms)
+	if err != nil {
+		utils.InternalError(c, "Failed to search tasks")
+		return
+	}
+
+	lists := []models.List{}
+	tags := []models.Tag{}
+	habits := []SearchHabitResult{}
+	countdowns := []SearchCountdownResult{}
+	if len(parsed.Terms) > 0 {
+		text := strings.Join(parsed.Terms, " ")
+		pattern := "%" + text + "%"
+
+		// 搜索清单（名称或拼音）
+		listIDs := ctrl.matchPinyin(userID, services.PinyinEntityList, text)
+		ctrl.db.Where("user_id = ? AND (name LIKE ? OR id IN ?)", userID, pattern, listIDs).
+			Limit(10).
+			Find(&lists)
+
+		// 搜索标签（名称或拼音）
+		tagIDs := ctrl.matchPinyin(userID, services.PinyinEntityTag, text)
+		ctrl.db.Where("user_id = ? AND (name LIKE ? OR id IN ?)", userID, pattern, tagIDs).
+			Limit(10).
+			Find(&tags)
+
+		// 搜索习惯和倒数日
+		if match != "" {
+			for _, hit := range ctrl.searchIndex(userID, services.SearchEntityHabit, match, 10) {
+				var habit models.Habit
+				if err := ctrl.db.Where("id = ?", hit.ID).First(&habit).Error; err == nil {
+					habits = append(habits, SearchHabitResult{
+						Habit:      habit,
+						Score:      hit.Score,
+						Highlights: SearchHighlights{Title: services.HighlightSearchText(habit.Name, parsed.Terms, 0)},
+					})
+				}
+			}
+			for _, hit := range ctrl.searchIndex(userID, services.SearchEntityCountdown, match, 10) {
+				var countdown models.Countdown
+				if err := ctrl.db.Where("id = ?", hit.ID).First(&countdown).Error; err == nil {
+					countdowns = append(countdowns, SearchCountdownResult{
+						Countdown:  countdown,
+						Score:      hit.Score,
+						Highlights: SearchHighlights{Title: services.HighlightSearchText(countdown.Title, parsed.Terms, 0)},
+					})
+				}
+			}
+		}
+	}
 
 	utils.Success(c, gin.H{
-		"tasks": tasks,
-		"lists": lists,
-		"tags":  tags,
+		"tasks":      tasks,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"lists":      lists,
+		"tags":       tags,
+		"habits":     habits,
+		"countdowns": countdowns,
 	})
 }
 
+// loadTaskResults 按命中顺序加载任务并生成高亮
+func (ctrl *SearchController) loadTaskResults(hits []searchHit, terms []string) ([]SearchTaskResult, error) {
+	results := make([]SearchTaskResult, 0, len(hits))
+	if len(hits) == 0 {
+		return results, nil
+	}
+
+	ids := make([]uint64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	var tasks []models.Task
+	if err := ctrl.db.Preload("Tags").Where("id IN ?", ids).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]models.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	for _, hit := range hits {
+		task, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		highlights := SearchHighlights{Title: services.HighlightSearchText(task.Title, terms, 0)}
+		if len(terms) > 0 && task.Description != "" {
+			snippet := services.HighlightSearchText(task.Description, terms, searchSnippetLength)
+			if strings.Contains(snippet, "<mark>") {
+				highlights.Snippet = snippet
+			}
+		}
+		results = append(results, SearchTaskResult{Task: task, Score: hit.Score, Highlights: highlights})
+	}
+	return results, nil
+}
+
+// searchIndex 在全文索引中搜索某类记录，按相关度排序
+func (ctrl *SearchController) searchIndex(userID uint64, entityType, match string, limit int) []searchHit {
+	var hits []searchHit
+	if err := ctrl.db.Raw("SELECT entity_id AS id, -"+services.SearchRankExpr+" AS score FROM search_index "+
+		"WHERE search_index MATCH ? AND entity_type = ? AND user_id = ? ORDER BY "+services.SearchRankExpr+" LIMIT ?",
+		match, entityType, userID, limit).Scan(&hits).Error; err != nil {
+		utils.Logger.Error("Failed to search index", zap.String("entityType", entityType), zap.Error(err))
+	}
+	return hits
+}
+
+// matchPinyin 按拼音匹配清单或标签，没有匹配时返回 [0] 以便直接用于 IN 条件
+func (ctrl *SearchController) matchPinyin(userID uint64, entityType, text string) []uint64 {
+	ids, err := services.MatchPinyinIndex(ctrl.db, userID, entityType, text)
+	if err != nil {
+		utils.Logger.Error("Failed to match pinyin index", zap.String("entityType", entityType), zap.Error(err))
+	}
+	if len(ids) == 0 {
+		return []uint64{0}
+	}
+	return ids
+}
@@ -0,0 +1,410 @@
+package controllers
+
+import (
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/models"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxSubtaskDepth 子任务最多嵌套的层数
+const maxSubtaskDepth = 5
+
+// TaskProgress 子任务和检查项的完成进度（已放弃的子任务不计入）
+type TaskProgress struct {
+	SubtaskTotal       int `json:"subtaskTotal"`
+	SubtaskCompleted   int `json:"subtaskCompleted"`
+	ChecklistTotal     int `json:"checklistTotal"`
+	ChecklistCompleted int `json:"checklistCompleted"`
+}
+
+// TaskResponse 带进度和阻塞状态的任务
+type TaskResponse struct {
+	models.Task
+	Progress  TaskProgress `json:"progress"`
+	Blocked   bool         `json:"blocked"`             // 是否有未完成的前置任务
+	BlockedBy []uint64     `json:"blockedBy,omitempty"` // 未完成的前置任务ID
+	Warnings  []string     `json:"warnings,omitempty"`
+}
+
+type SubtaskRequest struct {
+	Title        string   `json:"title" binding:"required"`
+	Description  string   `json:"description"`
+	Priority     int      `json:"priority"`
+	DueDate      string   `json:"dueDate"`      // 格式：20251105
+	DueTime      string   `json:"dueTime"`      // 格式：18:20
+	ReminderTime string   `json:"reminderTime"` // 格式：20251105 18:20
+	SortOrder    *int     `json:"sortOrder"`    // 在同级子任务中的位置（从 0 开始），不传时排在最后
+	TagIDs       []uint64 `json:"tagIds"`
+}
+
+// UpdateSubtaskRequest 用于更新子任务，所有字段都是可选的；完成子任务使用 PUT /tasks/:id/complete
+type UpdateSubtaskRequest struct {
+	Title        *string `json:"title"`
+	Description  *string `json:"description"`
+	Priority     *int    `json:"priority"`
+	DueDate      *string `json:"dueDate"`
+	DueTime      *string `json:"dueTime"`
+	ReminderTime *string `json:"reminderTime"`
+	SortOrder    *int    `json:"sortOrder"` // 移到同级子任务中的该位置
+}
+
+// GetSubtasks 获取任务的直接子任务
+func (ctrl *TaskController) GetSubtasks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	parent, ok := ctrl.findTask(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var subtasks []models.Task
+	if err := ctrl.db.Where("parent_id = ? AND user_id = ?", parent.ID, userID).
+		Order("rank ASC, id ASC").
+		Preload("Tags").
+		Find(&subtasks).Error; err != nil {
+		utils.InternalError(c, "Failed to get subtasks")
+		return
+	}
+
+	utils.Success(c, buildTaskResponses(ctrl.db, subtasks))
+}
+
+// CreateSubtask 创建子任务，子任务与父任务属于同一个清单
+func (ctrl *TaskController) CreateSubtask(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	parent, ok := ctrl.findTask(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req SubtaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if ctrl.taskDepth(parent) >= maxSubtaskDepth {
+		utils.BadRequest(c, "Subtasks cannot be nested more than 5 levels deep")
+		return
+	}
+
+	rank, err := ctrl.subtaskRank(parent, req.SortOrder, 0)
+	if err != nil {
+		utils.InternalError(c, "Failed to create subtask")
+		return
+	}
+
+	subtask := models.Task{
+		UserID:       userID,
+		ListID:       parent.ListID,
+		ParentID:     &parent.ID,
+		Title:        req.Title,
+		Description:  req.Description,
+		Priority:     req.Priority,
+		Status:       "todo",
+		Rank:         rank,
+		DueDate:      req.DueDate,
+		DueTime:      req.DueTime,
+		ReminderTime: req.ReminderTime,
+	}
+
+	if err := ctrl.db.Create(&subtask).Error; err != nil {
+		utils.InternalError(c, "Failed to create subtask")
+		return
+	}
+
+	// 关联标签
+	if len(req.TagIDs) > 0 {
+		var tags []models.Tag
+		ctrl.db.Where("id IN ? AND user_id = ?", req.TagIDs, userID).Find(&tags)
+		if err := ctrl.db.Model(&subtask).Association("Tags").Replace(tags); err != nil {
+			utils.Logger.Error("Failed to associate tags", zap.Error(err))
+		}
+	}
+
+	// 创建提醒
+	if err := ctrl.reminderService.CreateReminderForTask(&subtask); err != nil {
+		utils.Logger.Error("Failed to create task reminder", zap.Error(err))
+	}
+
+	ctrl.db.Preload("Tags").Preload("List").First(&subtask, subtask.ID)
+
+	ctrl.publishTaskChanged(userID, "created", gin.H{"task": subtask})
+
+	utils.Success(c, subtask)
+}
+
+// UpdateSubtask 更新子任务
+func (ctrl *TaskController) UpdateSubtask(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	subtask, ok := ctrl.findSubtask(c, userID)
+	if !ok || !ctrl.checkTaskIfMatch(c, subtask) {
+		return
+	}
+
+	var req UpdateSubtaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	// 只更新传入的字段
+	if req.Title != nil {
+		subtask.Title = *req.Title
+	}
+	if req.Description != nil {
+		subtask.Description = *req.Description
+	}
+	if req.Priority != nil {
+		subtask.Priority = *req.Priority
+	}
+	if req.DueDate != nil {
+		subtask.DueDate = *req.DueDate
+	}
+	if req.DueTime != nil {
+		subtask.DueTime = *req.DueTime
+	}
+	if req.ReminderTime != nil {
+		subtask.ReminderTime = *req.ReminderTime
+	}
+	if req.SortOrder != nil {
+		var parent models.Task
+		if err := ctrl.db.First(&parent, *subtask.ParentID).Error; err != nil {
+			utils.InternalError(c, "Failed to update subtask")
+			return
+		}
+		rank, err := ctrl.subtaskRank(parent, req.SortOrder, subtask.ID)
+		if err != nil {
+			utils.InternalError(c, "Failed to update subtask")
+			return
+		}
+		subtask.Rank = rank
+	}
+
+	if !saveVersioned(c, ctrl.db, &subtask, subtask.ID, subtask.Version, "Failed to update subtask", "Tags", "List") {
+		return
+	}
+
+	// 同步提醒
+	if err := ctrl.reminderService.CreateReminderForTask(&subtask); err != nil {
+		utils.Logger.Error("Failed to sync task reminder", zap.Error(err))
+	}
+
+	ctrl.db.Preload("Tags").Preload("List").First(&subtask, subtask.ID)
+
+	ctrl.publishTaskChanged(userID, "updated", gin.H{"task": subtask})
+
+	utils.SetETag(c, subtask.Version)
+	utils.Success(c, ctrl.taskResponse(subtask))
+}
+
+// DeleteSubtask 删除子任务及其下级子任务
+func (ctrl *TaskController) DeleteSubtask(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	subtask, ok := ctrl.findSubtask(c, userID)
+	if !ok || !ctrl.checkTaskIfMatch(c, subtask) {
+		return
+	}
+
+	if err := ctrl.deleteTaskTree(userID, subtask.ID); err != nil {
+		utils.InternalError(c, "Failed to delete subtask")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Subtask deleted successfully"})
+}
+
+// findTask 按路径参数查找当前用户的任务，找不到时直接写入错误响应
+func (ctrl *TaskController) findTask(c *gin.Context, userID uint64, taskIDStr string) (models.Task, bool) {
+	var task models.Task
+
+	taskID, err := strconv.ParseUint(taskIDStr, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return task, false
+	}
+
+	if err := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return task, false
+	}
+	return task, true
+}
+
+// findSubtask 查找路径中 :id 任务下的 :subtaskId 子任务
+func (ctrl *TaskController) findSubtask(c *gin.Context, userID uint64) (models.Task, bool) {
+	var subtask models.Task
+
+	parentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return subtask, false
+	}
+	subtaskID, err := strconv.ParseUint(c.Param("subtaskId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid subtask ID")
+		return subtask, false
+	}
+
+	if err := ctrl.db.Where("id = ? AND parent_id = ? AND user_id = ?", subtaskID, parentID, userID).
+		First(&subtask).Error; err != nil {
+		utils.NotFound(c, "Subtask not found")
+		return subtask, false
+	}
+	return subtask, true
+}
+
+// taskDepth 任务所在的层级，顶层任务为 0
+func (ctrl *TaskController) taskDepth(task models.Task) int {
+	depth := 0
+	for task.ParentID != nil && depth <= maxSubtaskDepth {
+		var parent models.Task
+		if err := ctrl.db.Select("id", "parent_id").First(&parent, *task.ParentID).Error; err != nil {
+			break
+		}
+		task = parent
+		depth++
+	}
+	return depth
+}
+
+// descendantIDs 返回任务的所有下级子任务ID（按层级顺序）
+func descendantIDs(db *gorm.DB, userID uint64, taskID uint64) []uint64 {
+	var result []uint64
+	current := []uint64{taskID}
+	for depth := 0; depth < maxSubtaskDepth && len(current) > 0; depth++ {
+		var children []uint64
+		db.Model(&models.Task{}).Where("parent_id IN ? AND user_id = ?", current, userID).Pluck("id", &children)
+		result = append(result, children...)
+		current = children
+	}
+	return result
+}
+
+// deleteTaskTree 删除任务及其所有子任务、检查项和提醒
+func (ctrl *TaskController) deleteTaskTree(userID uint64, taskID uint64) error {
+	ids := append([]uint64{taskID}, descendantIDs(ctrl.db, userID, taskID)...)
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ? AND user_id = ?", ids, userID).Delete(&models.Task{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("task_id IN ? AND user_id = ?", ids, userID).Delete(&models.ChecklistItem{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		ctrl.reminderService.DeleteRemindersForEntity("task", id)
+		ctrl.publishTaskChanged(userID, "deleted", gin.H{"taskId": id})
+	}
+	return nil
+}
+
+// taskResponse 为单个任务附加进度和阻塞状态
+func (ctrl *TaskController) taskResponse(task models.Task) TaskResponse {
+	return buildTaskResponses(ctrl.db, []models.Task{task})[0]
+}
+
+// buildTaskResponses 批量统计子任务和检查项进度，并计算阻塞状态
+func buildTaskResponses(db *gorm.DB, tasks []models.Task) []TaskResponse {
+	response := make([]TaskResponse, len(tasks))
+	if len(tasks) == 0 {
+		return response
+	}
+
+	ids := make([]uint64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+
+	type countRow struct {
+		OwnerID   uint64
+		Total     int
+		Completed int
+	}
+
+	var subtaskRows []countRow
+	db.Model(&models.Task{}).
+		Select("parent_id AS owner_id, COUNT(*) AS total, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS completed", "completed").
+		Where("parent_id IN ? AND status <> ?", ids, "abandoned").
+		Group("parent_id").
+		Scan(&subtaskRows)
+
+	var checklistRows []countRow
+	db.Model(&models.ChecklistItem{}).
+		Select("task_id AS owner_id, COUNT(*) AS total, SUM(CASE WHEN is_completed THEN 1 ELSE 0 END) AS completed").
+		Where("task_id IN ?", ids).
+		Group("task_id").
+		Scan(&checklistRows)
+
+	progress := make(map[uint64]*TaskProgress, len(tasks))
+	for _, id := range ids {
+		progress[id] = &TaskProgress{}
+	}
+	for _, row := range subtaskRows {
+		progress[row.OwnerID].SubtaskTotal = row.Total
+		progress[row.OwnerID].SubtaskCompleted = row.Completed
+	}
+	for _, row := range checklistRows {
+		progress[row.OwnerID].ChecklistTotal = row.Total
+		progress[row.OwnerID].ChecklistCompleted = row.Completed
+	}
+
+	blockers := services.IncompleteBlockers(db, ids)
+
+	for i, task := range tasks {
+		response[i] = TaskResponse{
+			Task:      task,
+			Progress:  *progress[task.ID],
+			Blocked:   len(blockers[task.ID]) > 0,
+			BlockedBy: blockers[task.ID],
+		}
+	}
+	return response
+}
+
+// subtaskRank 返回子任务排在同级子任务中第 position 个位置的排序键，position 为空或超出范围时排在最后，
+// 没有同级子任务时紧跟在父任务之后；excludeID 为正在移动的子任务
+func (ctrl *TaskController) subtaskRank(parent models.Task, position *int, excludeID uint64) (string, error) {
+	var siblings []string
+	if err := ctrl.db.Model(&models.Task{}).
+		Where("parent_id = ? AND id <> ?", parent.ID, excludeID).
+		Order("rank ASC, id ASC").
+		Pluck("rank", &siblings).Error; err != nil {
+		return "", err
+	}
+
+	exclude := []uint64{excludeID}
+	if position == nil || *position >= len(siblings) {
+		after := parent.Rank
+		if len(siblings) > 0 {
+			after = siblings[len(siblings)-1]
+		}
+		before, err := services.AdjacentTaskRank(ctrl.db, parent.UserID, after, true, exclude)
+		if err != nil {
+			return "", err
+		}
+		return services.RankBetween(after, before)
+	}
+
+	before := siblings[0]
+	if *position > 0 {
+		before = siblings[*position]
+	}
+	after, err := services.AdjacentTaskRank(ctrl.db, parent.UserID, before, false, exclude)
+	if err != nil {
+		return "", err
+	}
+	return services.RankBetween(after, before)
+}
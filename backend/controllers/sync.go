@@ -0,0 +1,630 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/models"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 客户端变更的操作类型
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+// 客户端变更的处理结果
+const (
+	SyncStatusApplied  = "applied"  // 已写入
+	SyncStatusConflict = "conflict" // 服务器上的记录已被修改，未写入，record 为服务器上的当前记录
+	SyncStatusError    = "error"    // 无法写入，原因见 error
+)
+
+// 冲突时的处理方式
+const (
+	SyncResolutionServer = "server" // 保留服务器上的记录，放弃本次修改（默认）
+	SyncResolutionClient = "client" // 以客户端为准覆盖服务器上的记录
+)
+
+// maxSyncMutations 单次提交的最大变更数
+const maxSyncMutations = 500
+
+// defaultSyncTokenTTL 同步游标的默认有效期，与回收站的默认保留时间相同
+const defaultSyncTokenTTL = 30 * 24 * time.Hour
+
+type SyncController struct {
+	db       *gorm.DB
+	tasks    *TaskController // 复用任务的提醒、重复任务和事件通知
+	tokenTTL time.Duration   // 游标有效期，期间删除的记录尚未被永久删除，更早的游标需要全量同步
+}
+
+func NewSyncController(db *gorm.DB, events *services.EventHub, tokenTTL time.Duration) *SyncController {
+	if tokenTTL <= 0 {
+		tokenTTL = defaultSyncTokenTTL
+	}
+	return &SyncController{
+		db:       db,
+		tasks:    NewTaskController(db, events),
+		tokenTTL: tokenTTL,
+	}
+}
+
+// SyncPushRequest 客户端提交的离线变更，按顺序执行
+type SyncPushRequest struct {
+	Mutations []SyncMutation `json:"mutations" binding:"required"`
+}
+
+// SyncMutation 一条客户端变更。data 的字段名与 GET /sync 返回的记录相同，只写入传入的字段；
+// 引用其他记录的字段（如任务的 listId、parentId、tagIds）可以传服务器 ID，也可以传该记录新建时的客户端 ID
+type SyncMutation struct {
+	Entity      string   `json:"entity" binding:"required"` // task, list, folder, tag, habit, habitRecord, countdown, filter
+	Op          string   `json:"op" binding:"required"`     // create, update, delete
+	ID          uint64   `json:"id"`                        // 服务器 ID，update 和 delete 时可以用 clientId 代替
+	ClientID    string   `json:"clientId"`                  // 客户端生成的 ID，create 时必填，重复提交不会重复创建
+	BaseVersion *uint64  `json:"baseVersion"`               // 客户端修改所基于的版本号，与服务器不一致时为冲突；不传时不检测
+	Resolution  string   `json:"resolution"`                // 冲突时的处理：server（默认）、client
+	Data        syncData `json:"data"`
+}
+
+// SyncMutationResult 一条客户端变更的处理结果
+type SyncMutationResult struct {
+	Entity   string      `json:"entity"`
+	Op       string      `json:"op"`
+	ID       uint64      `json:"id,omitempty"`
+	ClientID string      `json:"clientId,omitempty"`
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+	Record   interface{} `json:"record,omitempty"` // 写入后的记录，冲突时为服务器上的当前记录，删除时为空
+}
+
+// syncData 客户端提交的记录字段
+type syncData map[string]json.RawMessage
+
+// syncItemError 单条变更无法写入，只记入该变更的结果，不影响其他变更
+type syncItemError struct {
+	msg string
+}
+
+func (e *syncItemError) Error() string {
+	return e.msg
+}
+
+// syncConflictError 服务器上的记录与客户端不一致，record 为服务器上的当前记录
+type syncConflictError struct {
+	msg    string
+	record interface{}
+}
+
+func (e *syncConflictError) Error() string {
+	return e.msg
+}
+
+// syncTaskChange 提交后需要通知的任务变更
+type syncTaskChange struct {
+	id     uint64
+	action string // created, updated, deleted
+}
+
+// syncEffects 变更提交后要执行的操作：同步提醒、更新统计并通知其他设备
+type syncEffects struct {
+	tasks     []syncTaskChange
+	completed []*models.Task
+	nextTasks []*models.Task
+	habits    []uint64
+}
+
+// syncSession 一次提交的处理状态
+type syncSession struct {
+	ctrl    *SyncController
+	tx      *gorm.DB
+	userID  uint64
+	now     time.Time
+	effects syncEffects // 当前变更的操作，变更失败时丢弃
+}
+
+// GetChanges 返回 since 游标之后新建、修改和删除的记录，以及下次同步使用的游标。
+// 不传 since 或游标早于回收站保留时间（期间删除的记录可能已被永久删除）时返回全部记录，
+// full 为 true，客户端应以此替换本地数据
+func (ctrl *SyncController) GetChanges(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	now := time.Now()
+
+	var since *time.Time
+	if token := c.Query("since"); token != "" {
+		t, err := services.DecodeSyncToken(token)
+		if err != nil {
+			utils.BadRequest(c, "Invalid sync token")
+			return
+		}
+		if now.Sub(t) < ctrl.tokenTTL {
+			since = &t
+		}
+	}
+
+	changes, err := services.LoadSyncChanges(ctrl.db, userID, since)
+	if err != nil {
+		utils.Logger.Error("Failed to load sync changes", zap.Error(err))
+		utils.InternalError(c, "Failed to load changes")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"token":   services.EncodeSyncToken(now),
+		"full":    since == nil,
+		"changes": changes,
+	})
+}
+
+// PushChanges 在一个事务中按顺序写入客户端的离线变更，返回每条变更的结果。
+// 单条变更无效或与服务器冲突时只标记该变更，数据库错误时整体回滚。
+// 新建的记录按客户端 ID 去重；修改和删除时 baseVersion 与服务器不一致为冲突，
+// 按 resolution 保留服务器的记录或以客户端为准覆盖；删除已不存在的记录视为成功
+func (ctrl *SyncController) PushChanges(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req SyncPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if len(req.Mutations) > maxSyncMutations {
+		utils.BadRequest(c, "Too many mutations, at most "+strconv.Itoa(maxSyncMutations))
+		return
+	}
+
+	session := &syncSession{ctrl: ctrl, userID: userID, now: time.Now()}
+	results := make([]SyncMutationResult, len(req.Mutations))
+	var effects syncEffects
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		for i, mutation := range req.Mutations {
+			result, err := session.apply(tx, mutation)
+			if err != nil {
+				return err
+			}
+			results[i] = result
+			if result.Status == SyncStatusApplied {
+				effects.tasks = append(effects.tasks, session.effects.tasks...)
+				effects.completed = append(effects.completed, session.effects.completed...)
+				effects.nextTasks = append(effects.nextTasks, session.effects.nextTasks...)
+				effects.habits = append(effects.habits, session.effects.habits...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to apply sync mutations", zap.Error(err))
+		utils.InternalError(c, "Failed to apply changes")
+		return
+	}
+
+	ctrl.applyEffects(userID, session.now, effects)
+
+	applied := 0
+	for _, result := range results {
+		if result.Status == SyncStatusApplied {
+			applied++
+		}
+	}
+	utils.Success(c, gin.H{
+		"results": results,
+		"applied": applied,
+		"failed":  len(results) - applied,
+	})
+}
+
+// applyEffects 提交后同步提醒、更新统计并通知其他设备
+func (ctrl *SyncController) applyEffects(userID uint64, now time.Time, effects syncEffects) {
+	for _, change := range effects.tasks {
+		if change.action == "deleted" {
+			ctrl.tasks.reminderService.DeleteRemindersForEntity("task", change.id)
+			ctrl.tasks.publishTaskChanged(userID, "deleted", gin.H{"taskId": change.id})
+			continue
+		}
+		var task models.Task
+		if err := ctrl.db.Preload("Tags").First(&task, change.id).Error; err != nil {
+			continue
+		}
+		ctrl.tasks.reminderService.CreateReminderForTask(&task)
+		ctrl.tasks.publishTaskChanged(userID, change.action, gin.H{"task": task})
+	}
+	for _, task := range effects.completed {
+		updateDailyStatistics(ctrl.db, userID, now, task)
+	}
+	for _, nextTask := range effects.nextTasks {
+		ctrl.tasks.reminderService.CreateReminderForTask(nextTask)
+		ctrl.tasks.publishTaskChanged(userID, "created", gin.H{"task": nextTask})
+	}
+	for _, habitID := range uniqueIDs(effects.habits) {
+		var habit models.Habit
+		if err := ctrl.db.First(&habit, habitID).Error; err == nil {
+			ctrl.tasks.reminderService.CreateReminderForHabit(&habit)
+		}
+	}
+}
+
+// apply 在保存点中执行一条变更，变更无效或冲突时回滚到保存点，只返回数据库错误
+func (s *syncSession) apply(tx *gorm.DB, m SyncMutation) (SyncMutationResult, error) {
+	result := SyncMutationResult{Entity: m.Entity, Op: m.Op, ID: m.ID, ClientID: m.ClientID}
+	s.effects = syncEffects{}
+
+	entity, ok := syncEntities[m.Entity]
+	if !ok {
+		result.Status, result.Error = SyncStatusError, "Invalid entity"
+		return result, nil
+	}
+	if m.Resolution != "" && m.Resolution != SyncResolutionServer && m.Resolution != SyncResolutionClient {
+		result.Status, result.Error = SyncStatusError, "Invalid resolution"
+		return result, nil
+	}
+
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		s.tx = tx
+		switch m.Op {
+		case SyncOpCreate:
+			return s.create(entity, m, &result)
+		case SyncOpUpdate:
+			return s.update(entity, m, &result)
+		case SyncOpDelete:
+			return s.delete(entity, m, &result)
+		}
+		return &syncItemError{"Invalid op"}
+	})
+
+	var itemErr *syncItemError
+	var conflictErr *syncConflictError
+	switch {
+	case errors.As(err, &itemErr):
+		result.Status, result.Error, result.Record = SyncStatusError, itemErr.msg, nil
+	case errors.As(err, &conflictErr):
+		result.Status, result.Error, result.Record = SyncStatusConflict, conflictErr.msg, conflictErr.record
+	case err != nil:
+		return result, err
+	default:
+		result.Status = SyncStatusApplied
+	}
+	return result, nil
+}
+
+// create 新建记录，同一客户端 ID 已创建过时直接返回该记录
+func (s *syncSession) create(entity *syncEntity, m SyncMutation, result *SyncMutationResult) error {
+	if m.ClientID == "" || len(m.ClientID) > 64 {
+		return &syncItemError{"clientId is required and at most 64 characters"}
+	}
+	id, err := services.FindSyncMapping(s.tx, s.userID, m.Entity, m.ClientID)
+	if err == nil {
+		result.ID = id
+		record, err := s.load(entity, id)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		result.Record = record
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	record := entity.newRecord()
+	data, err := s.writableData(entity, record, m.Data)
+	if err != nil {
+		return err
+	}
+	if err := decodeSyncData(data, record); err != nil {
+		return err
+	}
+	if field := reflect.ValueOf(record).Elem().FieldByName("UserID"); field.IsValid() {
+		field.SetUint(s.userID)
+	}
+	if entity.prepare != nil {
+		if err := entity.prepare(s, record, nil, data); err != nil {
+			return err
+		}
+	}
+
+	if err := s.tx.Create(record).Error; err != nil {
+		return err
+	}
+	if entity.afterSave != nil {
+		if err := entity.afterSave(s, record, nil, data); err != nil {
+			return err
+		}
+	}
+
+	result.ID = syncRecordID(record)
+	if err := services.SaveSyncMapping(s.tx, s.userID, m.Entity, m.ClientID, result.ID); err != nil {
+		return err
+	}
+	result.Record, err = s.load(entity, result.ID)
+	return err
+}
+
+// update 修改 data 中传入的字段
+func (s *syncSession) update(entity *syncEntity, m SyncMutation, result *SyncMutationResult) error {
+	if entity.createOnly {
+		return &syncItemError{"Records of this entity cannot be updated"}
+	}
+	current, err := s.target(entity, m, result)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &syncItemError{"Record not found"}
+		}
+		return err
+	}
+	if err := s.checkVersion(entity, m, current); err != nil {
+		return err
+	}
+
+	record := entity.newRecord()
+	reflect.ValueOf(record).Elem().Set(reflect.ValueOf(current).Elem())
+	data, err := s.writableData(entity, record, m.Data)
+	if err != nil {
+		return err
+	}
+	if err := decodeSyncData(data, record); err != nil {
+		return err
+	}
+	if entity.prepare != nil {
+		if err := entity.prepare(s, record, current, data); err != nil {
+			return err
+		}
+	}
+
+	// 只写入有变化的列；只修改了关联（如 tagIds）时也更新修改时间和版本号，以便同步给其他设备
+	columns, err := changedColumns(s.tx, record, current)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		columns = []string{"updated_at"}
+	}
+	query := s.tx.Model(record)
+	if version, ok := syncRecordVersion(current); ok {
+		query = query.Where("version = ?", version)
+	}
+	update := query.Select(columns).Updates(record)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return s.conflict(entity, result.ID)
+	}
+	if entity.afterSave != nil {
+		if err := entity.afterSave(s, record, current, data); err != nil {
+			return err
+		}
+	}
+
+	result.Record, err = s.load(entity, result.ID)
+	return err
+}
+
+// delete 删除记录，记录已不存在时视为成功
+func (s *syncSession) delete(entity *syncEntity, m SyncMutation, result *SyncMutationResult) error {
+	current, err := s.target(entity, m, result)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.checkVersion(entity, m, current); err != nil {
+		return err
+	}
+
+	if entity.remove != nil {
+		return entity.remove(s, current)
+	}
+	return s.tx.Delete(current).Error
+}
+
+// target 按服务器 ID 或客户端 ID 读取要修改的记录，并把服务器 ID 写入结果
+func (s *syncSession) target(entity *syncEntity, m SyncMutation, result *SyncMutationResult) (interface{}, error) {
+	id := m.ID
+	if id == 0 {
+		if m.ClientID == "" {
+			return nil, &syncItemError{"id or clientId is required"}
+		}
+		var err error
+		if id, err = services.FindSyncMapping(s.tx, s.userID, m.Entity, m.ClientID); err != nil {
+			return nil, err
+		}
+	}
+	result.ID = id
+
+	record := entity.newRecord()
+	if err := entity.scope(s.tx, s.userID).First(record, id).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// checkVersion 客户端修改所基于的版本与服务器不一致时，除非以客户端为准，否则返回冲突
+func (s *syncSession) checkVersion(entity *syncEntity, m SyncMutation, current interface{}) error {
+	version, ok := syncRecordVersion(current)
+	if !ok || m.BaseVersion == nil || *m.BaseVersion == version || m.Resolution == SyncResolutionClient {
+		return nil
+	}
+	return s.conflict(entity, syncRecordID(current))
+}
+
+// conflict 返回包含服务器当前记录的冲突错误
+func (s *syncSession) conflict(entity *syncEntity, id uint64) error {
+	record, err := s.load(entity, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &syncItemError{"Record not found"}
+		}
+		return err
+	}
+	return &syncConflictError{msg: "Record was modified on the server", record: record}
+}
+
+// load 读取记录及其关联
+func (s *syncSession) load(entity *syncEntity, id uint64) (interface{}, error) {
+	record := entity.newRecord()
+	query := entity.scope(s.tx, s.userID)
+	for _, preload := range entity.preloads {
+		query = query.Preload(preload)
+	}
+	if err := query.First(record, id).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// writableData 去掉客户端不能写入的字段并解析引用。
+// 未知字段（如关联对象）直接忽略，以便客户端提交完整的记录
+func (s *syncSession) writableData(entity *syncEntity, record interface{}, data syncData) (syncData, error) {
+	stmt := &gorm.Statement{DB: s.tx}
+	if err := stmt.Parse(record); err != nil {
+		return nil, err
+	}
+
+	writable := syncData{}
+	for _, field := range stmt.Schema.Fields {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		raw, ok := data[name]
+		if !ok || field.DBName == "" || syncReadOnlyFields[name] || entity.isReadOnly(name) {
+			continue
+		}
+		writable[name] = raw
+	}
+	for name := range entity.refs {
+		if raw, ok := data[name]; ok {
+			writable[name] = raw
+		}
+	}
+
+	if err := s.resolveRefs(entity, writable); err != nil {
+		return nil, err
+	}
+	return writable, nil
+}
+
+// changedColumns 返回 record 与修改前的记录 previous 相比有变化的列
+func changedColumns(tx *gorm.DB, record, previous interface{}) ([]string, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(record); err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	value, previousValue := reflect.ValueOf(record).Elem(), reflect.ValueOf(previous).Elem()
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		current, _ := field.ValueOf(tx.Statement.Context, value)
+		old, _ := field.ValueOf(tx.Statement.Context, previousValue)
+		if !reflect.DeepEqual(current, old) {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns, nil
+}
+
+// resolveRefs 把引用字段中的客户端 ID 换成服务器 ID，并校验引用的记录属于当前用户；0 视为不引用
+func (s *syncSession) resolveRefs(entity *syncEntity, data syncData) error {
+	for name, target := range entity.refs {
+		raw, ok := data[name]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err == nil {
+			ids := make([]uint64, 0, len(items))
+			for _, item := range items {
+				id, err := s.resolveRef(target, name, item)
+				if err != nil {
+					return err
+				}
+				if id != 0 {
+					ids = append(ids, id)
+				}
+			}
+			data[name], _ = json.Marshal(uniqueIDs(ids))
+			continue
+		}
+
+		id, err := s.resolveRef(target, name, raw)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			data[name] = json.RawMessage("null")
+		} else {
+			data[name], _ = json.Marshal(id)
+		}
+	}
+	return nil
+}
+
+// resolveRef 解析单个引用，字符串为客户端 ID，数字为服务器 ID
+func (s *syncSession) resolveRef(target, name string, raw json.RawMessage) (uint64, error) {
+	var id uint64
+	var clientID string
+	if err := json.Unmarshal(raw, &clientID); err == nil {
+		mapped, err := services.FindSyncMapping(s.tx, s.userID, target, clientID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, &syncItemError{"Unknown clientId in " + name + ": " + clientID}
+		}
+		if err != nil {
+			return 0, err
+		}
+		id = mapped
+	} else if err := json.Unmarshal(raw, &id); err != nil {
+		return 0, &syncItemError{"Invalid " + name}
+	}
+	if id == 0 {
+		return 0, nil
+	}
+
+	entity := syncEntities[target]
+	var count int64
+	if err := entity.scope(s.tx, s.userID).Model(entity.newRecord()).Where("id = ?", id).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, &syncItemError{"Record referenced by " + name + " not found: " + strconv.FormatUint(id, 10)}
+	}
+	return id, nil
+}
+
+// decodeSyncData 把字段写入记录
+func decodeSyncData(data syncData, record interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, record); err != nil {
+		return &syncItemError{"Invalid data: " + err.Error()}
+	}
+	return nil
+}
+
+// syncRecordID 读取记录的 ID
+func syncRecordID(record interface{}) uint64 {
+	return reflect.ValueOf(record).Elem().FieldByName("ID").Uint()
+}
+
+// syncRecordVersion 读取记录的版本号，没有版本号的实体返回 false
+func syncRecordVersion(record interface{}) (uint64, bool) {
+	field := reflect.ValueOf(record).Elem().FieldByName("Version")
+	if !field.IsValid() {
+		return 0, false
+	}
+	return field.Uint(), true
+}
@@ -0,0 +1,448 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"on-the-way/backend/models"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// syncEntity 一种可同步实体的写入规则
+type syncEntity struct {
+	newRecord  func() interface{}                        // 返回模型的新指针
+	scope      func(tx *gorm.DB, userID uint64) *gorm.DB // 限定为当前用户的记录
+	preloads   []string                                  // 返回记录时加载的关联
+	refs       map[string]string                         // 引用其他记录的字段（JSON 字段名）及被引用的实体，可以传客户端 ID
+	readOnly   []string                                  // 除 syncReadOnlyFields 外客户端不能写入的字段
+	createOnly bool                                      // 只能新建和删除
+	// prepare 在写入前校验并补全记录，previous 为修改前的记录，新建时为 nil
+	prepare func(s *syncSession, record, previous interface{}, data syncData) error
+	// afterSave 在写入后处理关联数据
+	afterSave func(s *syncSession, record, previous interface{}, data syncData) error
+	// remove 删除记录及其关联数据，为空时只删除记录
+	remove func(s *syncSession, record interface{}) error
+}
+
+// syncReadOnlyFields 所有实体中由服务器维护的字段
+var syncReadOnlyFields = map[string]bool{
+	"id":        true,
+	"userId":    true,
+	"version":   true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// isReadOnly 客户端是否不能写入该字段
+func (e *syncEntity) isReadOnly(name string) bool {
+	for _, field := range e.readOnly {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// syncByUser 按 user_id 限定记录
+func syncByUser(tx *gorm.DB, userID uint64) *gorm.DB {
+	return tx.Where("user_id = ?", userID)
+}
+
+// syncEntities 可同步的实体，键与 GET /sync 的实体类型相同
+var syncEntities = map[string]*syncEntity{
+	services.SyncEntityTask: {
+		newRecord: func() interface{} { return &models.Task{} },
+		scope:     syncByUser,
+		preloads:  []string{"Tags"},
+		refs: map[string]string{
+			"listId":   services.SyncEntityList,
+			"parentId": services.SyncEntityTask,
+			"tagIds":   services.SyncEntityTag,
+		},
+		// 排序通过 PUT /tasks/:id/move 调整，完成时间由状态决定，重复系列由服务器维护
+		readOnly:  []string{"rank", "sortOrder", "completedAt", "seriesId", "parentTaskId"},
+		prepare:   prepareSyncTask,
+		afterSave: afterSaveSyncTask,
+		remove:    removeSyncTask,
+	},
+	services.SyncEntityList: {
+		newRecord: func() interface{} { return &models.List{} },
+		scope:     syncByUser,
+		refs:      map[string]string{"folderId": services.SyncEntityFolder},
+		readOnly:  []string{"isDefault", "isSystem"},
+		prepare:   prepareSyncList,
+		remove:    removeSyncList,
+	},
+	services.SyncEntityFolder: {
+		newRecord: func() interface{} { return &models.Folder{} },
+		scope:     syncByUser,
+		prepare:   prepareSyncFolder,
+		remove:    removeSyncFolder,
+	},
+	services.SyncEntityTag: {
+		newRecord: func() interface{} { return &models.Tag{} },
+		scope:     syncByUser,
+		refs:      map[string]string{"parentId": services.SyncEntityTag},
+		prepare:   prepareSyncTag,
+		remove:    removeSyncTag,
+	},
+	services.SyncEntityHabit: {
+		newRecord: func() interface{} { return &models.Habit{} },
+		scope:     syncByUser,
+		prepare:   prepareSyncHabit,
+		afterSave: afterSaveSyncHabit,
+		remove:    removeSyncHabit,
+	},
+	services.SyncEntityHabitRecord: {
+		newRecord: func() interface{} { return &models.HabitRecord{} },
+		// 打卡记录没有 user_id，按习惯归属
+		scope: func(tx *gorm.DB, userID uint64) *gorm.DB {
+			habits := tx.Session(&gorm.Session{NewDB: true}).Model(&models.Habit{}).Select("id").Where("user_id = ?", userID)
+			return tx.Where("habit_id IN (?)", habits)
+		},
+		refs:       map[string]string{"habitId": services.SyncEntityHabit},
+		createOnly: true,
+		prepare:    prepareSyncHabitRecord,
+	},
+	services.SyncEntityCountdown: {
+		newRecord: func() interface{} { return &models.Countdown{} },
+		scope:     syncByUser,
+		prepare:   prepareSyncCountdown,
+	},
+	services.SyncEntityFilter: {
+		newRecord: func() interface{} { return &models.Filter{} },
+		scope:     syncByUser,
+		prepare:   prepareSyncFilter,
+	},
+}
+
+// requireSyncName 校验名称或标题不为空
+func requireSyncName(value *string, field string) error {
+	*value = strings.TrimSpace(*value)
+	if *value == "" {
+		return &syncItemError{field + " is required"}
+	}
+	return nil
+}
+
+// prepareSyncTask 校验任务字段，与创建、修改任务的接口规则相同；状态变为完成或放弃时记录完成时间
+func prepareSyncTask(s *syncSession, record, previous interface{}, data syncData) error {
+	task := record.(*models.Task)
+	if err := requireSyncName(&task.Title, "title"); err != nil {
+		return err
+	}
+	if task.ListID == 0 {
+		var inbox models.List
+		if err := s.tx.Where("user_id = ? AND is_default = ?", s.userID, true).First(&inbox).Error; err != nil {
+			return &syncItemError{"Default inbox not found. Please specify a list."}
+		}
+		task.ListID = inbox.ID
+	}
+	if task.Priority < 0 || task.Priority > 3 {
+		return &syncItemError{"priority must be between 0 and 3"}
+	}
+	if task.Status == "" {
+		task.Status = "todo"
+	}
+	if task.Status != "todo" && task.Status != "completed" && task.Status != "abandoned" {
+		return &syncItemError{"Invalid status, expected todo, completed or abandoned"}
+	}
+
+	// 子任务不能挂到自身或下级任务之下，层级不超过上限
+	if task.ParentID != nil {
+		if task.ID != 0 {
+			if *task.ParentID == task.ID {
+				return &syncItemError{"A task cannot be its own parent"}
+			}
+			for _, id := range descendantIDs(s.tx, s.userID, task.ID) {
+				if id == *task.ParentID {
+					return &syncItemError{"Cannot move a task under its own subtask"}
+				}
+			}
+		}
+		var parent models.Task
+		if err := s.tx.Select("id", "parent_id").First(&parent, *task.ParentID).Error; err != nil {
+			return err
+		}
+		if s.ctrl.tasks.taskDepth(parent) >= maxSubtaskDepth {
+			return &syncItemError{"Subtasks cannot be nested more than 5 levels deep"}
+		}
+	}
+
+	if _, ok := data["rrule"]; ok {
+		rrule, err := normalizeRRule(task.RRule)
+		if err != nil {
+			return &syncItemError{"Invalid rrule: " + err.Error()}
+		}
+		task.RRule = rrule
+		// 设置了 RRULE 即为重复任务，以截止日期作为规则起始日期
+		if task.RRule != "" {
+			task.IsRecurring = true
+			task.RecurrenceStart = recurrenceStartDate(task)
+		}
+	}
+	var previousTask *models.Task
+	if previous != nil {
+		previousTask = previous.(*models.Task)
+	}
+	resetLegacyRecurrenceStart(task, previousTask)
+	if !services.ValidRecurrenceAnchor(task.RecurrenceAnchor) {
+		return &syncItemError{"Invalid recurrenceAnchor, expected due or completion"}
+	}
+	if !services.ValidRecurrenceCatchUp(task.RecurrenceCatchUp) {
+		return &syncItemError{"Invalid recurrenceCatchUp, expected skip or each"}
+	}
+
+	previousStatus := "todo"
+	if previousTask != nil {
+		previousStatus = previousTask.Status
+	}
+	if task.Status != previousStatus {
+		if task.Status == "todo" {
+			task.CompletedAt = ""
+		} else {
+			task.CompletedAt = s.now.Format("20060102 15:04")
+		}
+	}
+
+	// 新任务排在最前
+	if previous == nil {
+		rank, err := services.TaskRankFirst(s.tx, s.userID)
+		if err != nil {
+			return err
+		}
+		task.Rank = rank
+	}
+	return nil
+}
+
+// afterSaveSyncTask 替换任务标签，完成重复任务时生成下一个实例
+func afterSaveSyncTask(s *syncSession, record, previous interface{}, data syncData) error {
+	task := record.(*models.Task)
+	if raw, ok := data["tagIds"]; ok {
+		var tagIDs []uint64
+		if err := json.Unmarshal(raw, &tagIDs); err != nil {
+			return &syncItemError{"Invalid tagIds"}
+		}
+		tags := []models.Tag{}
+		if len(tagIDs) > 0 {
+			if err := s.tx.Where("id IN ?", tagIDs).Find(&tags).Error; err != nil {
+				return err
+			}
+		}
+		if err := s.tx.Model(task).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+	}
+
+	action := "updated"
+	if previous == nil {
+		action = "created"
+	}
+	s.effects.tasks = append(s.effects.tasks, syncTaskChange{id: task.ID, action: action})
+
+	if task.Status == "completed" && (previous == nil || previous.(*models.Task).Status != "completed") {
+		s.effects.completed = append(s.effects.completed, task)
+		s.effects.nextTasks = append(s.effects.nextTasks, s.ctrl.tasks.generateNextInstances(s.tx, task)...)
+	}
+	return nil
+}
+
+// removeSyncTask 连同子任务和检查项一起删除任务
+func removeSyncTask(s *syncSession, record interface{}) error {
+	task := record.(*models.Task)
+	ids := append([]uint64{task.ID}, descendantIDs(s.tx, s.userID, task.ID)...)
+	if err := s.tx.Where("id IN ? AND user_id = ?", ids, s.userID).Delete(&models.Task{}).Error; err != nil {
+		return err
+	}
+	if err := s.tx.Where("task_id IN ? AND user_id = ?", ids, s.userID).Delete(&models.ChecklistItem{}).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.effects.tasks = append(s.effects.tasks, syncTaskChange{id: id, action: "deleted"})
+	}
+	return nil
+}
+
+// prepareSyncList 校验清单字段，新清单默认为自定义清单
+func prepareSyncList(s *syncSession, record, previous interface{}, data syncData) error {
+	list := record.(*models.List)
+	if err := requireSyncName(&list.Name, "name"); err != nil {
+		return err
+	}
+	if list.Type == "" {
+		list.Type = "custom"
+	}
+	return nil
+}
+
+// removeSyncList 删除清单，其中的任务移到收集箱；系统清单不能删除
+func removeSyncList(s *syncSession, record interface{}) error {
+	list := record.(*models.List)
+	if list.IsSystem {
+		return &syncItemError{"Cannot delete system list"}
+	}
+	var inbox models.List
+	if err := s.tx.Where("user_id = ? AND is_default = ?", s.userID, true).First(&inbox).Error; err != nil {
+		return err
+	}
+	_, err := services.DeleteLists(s.tx, s.userID, []uint64{list.ID}, services.ListDeleteOptions{
+		Mode:         services.ListDeleteModeInbox,
+		TargetListID: inbox.ID,
+	})
+	return err
+}
+
+// prepareSyncFolder 校验文件夹字段
+func prepareSyncFolder(s *syncSession, record, previous interface{}, data syncData) error {
+	return requireSyncName(&record.(*models.Folder).Name, "name")
+}
+
+// removeSyncFolder 删除文件夹，其中的清单移到顶层
+func removeSyncFolder(s *syncSession, record interface{}) error {
+	folder := record.(*models.Folder)
+	if err := s.tx.Model(&models.List{}).
+		Where("folder_id = ? AND user_id = ?", folder.ID, s.userID).
+		Update("folder_id", nil).Error; err != nil {
+		return err
+	}
+	return s.tx.Delete(folder).Error
+}
+
+// prepareSyncTag 校验标签字段
+func prepareSyncTag(s *syncSession, record, previous interface{}, data syncData) error {
+	tag := record.(*models.Tag)
+	if tag.ParentID != nil {
+		if *tag.ParentID == tag.ID {
+			return &syncItemError{"A tag cannot be its own parent"}
+		}
+		// 新的父标签及其祖先中不能包含该标签，否则会形成循环
+		circular, err := syncTagHasAncestor(s, *tag.ParentID, tag.ID)
+		if err != nil {
+			return err
+		}
+		if circular {
+			return &syncItemError{"Cannot create circular reference"}
+		}
+	}
+	return requireSyncName(&tag.Name, "name")
+}
+
+// syncTagHasAncestor 沿父标签向上查找，判断 tagID 及其祖先中是否包含 ancestorID
+func syncTagHasAncestor(s *syncSession, tagID, ancestorID uint64) (bool, error) {
+	visited := map[uint64]bool{}
+	for id := tagID; id != 0 && !visited[id]; {
+		if id == ancestorID {
+			return true, nil
+		}
+		visited[id] = true
+
+		var tags []models.Tag
+		if err := s.tx.Select("id", "parent_id").
+			Where("id = ? AND user_id = ?", id, s.userID).
+			Limit(1).Find(&tags).Error; err != nil {
+			return false, err
+		}
+		if len(tags) == 0 || tags[0].ParentID == nil {
+			break
+		}
+		id = *tags[0].ParentID
+	}
+	return false, nil
+}
+
+// removeSyncTag 删除标签及其与任务的关联，有子标签时不能删除
+func removeSyncTag(s *syncSession, record interface{}) error {
+	tag := record.(*models.Tag)
+	var childCount int64
+	if err := s.tx.Model(&models.Tag{}).Where("parent_id = ?", tag.ID).Count(&childCount).Error; err != nil {
+		return err
+	}
+	if childCount > 0 {
+		return &syncItemError{"Cannot delete tag with children. Please delete or move child tags first."}
+	}
+	if err := s.tx.Where("tag_id = ?", tag.ID).Delete(&models.TaskTag{}).Error; err != nil {
+		return err
+	}
+	return s.tx.Delete(tag).Error
+}
+
+// prepareSyncHabit 校验习惯字段
+func prepareSyncHabit(s *syncSession, record, previous interface{}, data syncData) error {
+	return requireSyncName(&record.(*models.Habit).Name, "name")
+}
+
+// afterSaveSyncHabit 提交后重新生成习惯的提醒
+func afterSaveSyncHabit(s *syncSession, record, previous interface{}, data syncData) error {
+	s.effects.habits = append(s.effects.habits, record.(*models.Habit).ID)
+	return nil
+}
+
+// removeSyncHabit 删除习惯及其提醒
+func removeSyncHabit(s *syncSession, record interface{}) error {
+	habit := record.(*models.Habit)
+	if err := s.tx.Where("entity_type = ? AND entity_id = ?", "habit", habit.ID).Delete(&models.Reminder{}).Error; err != nil {
+		return err
+	}
+	return s.tx.Delete(habit).Error
+}
+
+// prepareSyncHabitRecord 校验打卡记录，同一天已打卡时返回冲突和已有的记录
+func prepareSyncHabitRecord(s *syncSession, record, previous interface{}, data syncData) error {
+	habitRecord := record.(*models.HabitRecord)
+	if habitRecord.HabitID == 0 {
+		return &syncItemError{"habitId is required"}
+	}
+	if habitRecord.CheckDate.IsZero() {
+		return &syncItemError{"checkDate is required"}
+	}
+	habitRecord.CheckDate = utils.BeginningOfDay(habitRecord.CheckDate)
+
+	var existing models.HabitRecord
+	err := s.tx.Where("habit_id = ? AND check_date >= ? AND check_date < ?",
+		habitRecord.HabitID, habitRecord.CheckDate, habitRecord.CheckDate.AddDate(0, 0, 1)).First(&existing).Error
+	if err == nil {
+		return &syncConflictError{msg: "Already checked in on this date", record: &existing}
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// prepareSyncCountdown 校验倒数日字段
+func prepareSyncCountdown(s *syncSession, record, previous interface{}, data syncData) error {
+	countdown := record.(*models.Countdown)
+	if countdown.TargetDate.IsZero() {
+		return &syncItemError{"targetDate is required"}
+	}
+	return requireSyncName(&countdown.Title, "title")
+}
+
+// prepareSyncFilter 校验过滤器字段，过滤器配置与创建过滤器的接口一样转换为规则树并校验
+func prepareSyncFilter(s *syncSession, record, previous interface{}, data syncData) error {
+	filter := record.(*models.Filter)
+	if err := requireSyncName(&filter.Name, "name"); err != nil {
+		return err
+	}
+	if _, ok := data["filterConfig"]; !ok && previous != nil {
+		return nil
+	}
+
+	var config models.FilterConfig
+	if err := json.Unmarshal([]byte(filter.FilterConfig), &config); err != nil {
+		return &syncItemError{"Invalid filter configuration"}
+	}
+	if err := services.NormalizeFilterConfig(&config); err != nil {
+		return &syncItemError{"Invalid filter configuration: " + err.Error()}
+	}
+	configJSON, err := json.Marshal(&config)
+	if err != nil {
+		return &syncItemError{"Invalid filter configuration"}
+	}
+	filter.FilterConfig = string(configJSON)
+	return nil
+}
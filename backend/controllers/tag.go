@@ -106,6 +106,9 @@ func (ctrl *TagController) UpdateTag(c *gin.Context) {
 		utils.NotFound(c, "Tag not found")
 		return
 	}
+	if !checkIfMatch(c, tag.Version, tag) {
+		return
+	}
 
 	var req TagRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -146,11 +149,11 @@ func (ctrl *TagController) UpdateTag(c *gin.Context) {
 		}
 	}
 
-	if err := ctrl.db.Save(&tag).Error; err != nil {
-		utils.InternalError(c, "Failed to update tag")
+	if !saveVersioned(c, ctrl.db, &tag, tag.ID, tag.Version, "Failed to update tag") {
 		return
 	}
 
+	utils.SetETag(c, tag.Version)
 	utils.Success(c, tag)
 }
 
@@ -159,6 +162,16 @@ func (ctrl *TagController) DeleteTag(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	tagID := c.Param("id")
 
+	var tag models.Tag
+	if err := ctrl.db.Where("id = ? AND user_id = ?", tagID, userID).
+		First(&tag).Error; err != nil {
+		utils.NotFound(c, "Tag not found")
+		return
+	}
+	if !checkIfMatch(c, tag.Version, tag) {
+		return
+	}
+
 	// 检查是否有子标签
 	var childCount int64
 	ctrl.db.Model(&models.Tag{}).Where("parent_id = ?", tagID).Count(&childCount)
@@ -198,6 +211,9 @@ func (ctrl *TagController) MoveTag(c *gin.Context) {
 		utils.NotFound(c, "Tag not found")
 		return
 	}
+	if !checkIfMatch(c, tag.Version, tag) {
+		return
+	}
 
 	var req MoveTagRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -229,11 +245,11 @@ func (ctrl *TagController) MoveTag(c *gin.Context) {
 	tag.ParentID = req.ParentID
 	tag.SortOrder = req.SortOrder
 
-	if err := ctrl.db.Save(&tag).Error; err != nil {
-		utils.InternalError(c, "Failed to move tag")
+	if !saveVersioned(c, ctrl.db, &tag, tag.ID, tag.Version, "Failed to move tag") {
 		return
 	}
 
+	utils.SetETag(c, tag.Version)
 	utils.Success(c, tag)
 }
 
@@ -248,6 +264,9 @@ func (ctrl *TagController) TogglePin(c *gin.Context) {
 		utils.NotFound(c, "Tag not found")
 		return
 	}
+	if !checkIfMatch(c, tag.Version, tag) {
+		return
+	}
 
 	var req TogglePinRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -257,11 +276,11 @@ func (ctrl *TagController) TogglePin(c *gin.Context) {
 
 	tag.IsPinned = req.IsPinned
 
-	if err := ctrl.db.Save(&tag).Error; err != nil {
-		utils.InternalError(c, "Failed to toggle pin")
+	if !saveVersioned(c, ctrl.db, &tag, tag.ID, tag.Version, "Failed to toggle pin") {
 		return
 	}
 
+	utils.SetETag(c, tag.Version)
 	utils.Success(c, tag)
 }
 
@@ -289,7 +308,7 @@ func (ctrl *TagController) GetTasksByTag(c *gin.Context) {
 		Where("task_tags.tag_id IN ? AND tasks.user_id = ? AND tasks.status = ?", tagIDs, userID, "todo").
 		Preload("Tags").
 		Preload("List").
-		Order("tasks.sort_order ASC, tasks.created_at DESC").
+		Order("tasks.rank ASC, tasks.id ASC").
 		Group("tasks.id").
 		Find(&tasks).Error; err != nil {
 		utils.InternalError(c, "Failed to get tasks")
@@ -6,6 +6,7 @@ import (
 	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,11 +15,34 @@ import (
 )
 
 type TaskController struct {
-	db *gorm.DB
+	db                *gorm.DB
+	reminderService   *services.ReminderService
+	recurrenceService *services.RecurrenceService
+	events            *services.EventHub
 }
 
-func NewTaskController(db *gorm.DB) *TaskController {
-	return &TaskController{db: db}
+func NewTaskController(db *gorm.DB, events *services.EventHub) *TaskController {
+	return &TaskController{
+		db:                db,
+		reminderService:   services.NewReminderService(db),
+		recurrenceService: services.NewRecurrenceService(services.NewDBHolidayCalendar(db)),
+		events:            events,
+	}
+}
+
+// publishTaskChanged 通知用户的所有设备任务发生变更
+func (ctrl *TaskController) publishTaskChanged(userID uint64, action string, data gin.H) {
+	data["action"] = action
+	ctrl.events.Publish(userID, services.EventTaskChanged, data)
+}
+
+// checkTaskIfMatch 校验任务的 If-Match 请求头，不一致时返回 412 和包含标签的任务当前数据
+func (ctrl *TaskController) checkTaskIfMatch(c *gin.Context, task models.Task) bool {
+	if utils.IfMatch(c, task.Version) {
+		return true
+	}
+	ctrl.db.Preload("Tags").Preload("List").First(&task, task.ID)
+	return checkIfMatch(c, task.Version, ctrl.taskResponse(task))
 }
 
 type TaskRequest struct {
@@ -36,6 +60,9 @@ type TaskRequest struct {
 	RecurrenceMonthDay  int      `json:"recurrenceMonthDay"`
 	RecurrenceLunarDate string   `json:"recurrenceLunarDate"`
 	RecurrenceEndDate   string   `json:"recurrenceEndDate"`   // 格式：20251231
+	RRule               string   `json:"rrule"`               // RFC 5545 重复规则，如 "FREQ=WEEKLY;INTERVAL=3;BYDAY=MO,TH"
+	RecurrenceAnchor    string   `json:"recurrenceAnchor"`    // due（默认）或 completion
+	RecurrenceCatchUp   string   `json:"recurrenceCatchUp"`   // 空、skip 或 each
 	TagIDs              []uint64 `json:"tagIds"`
 }
 
@@ -55,7 +82,11 @@ type UpdateTaskRequest struct {
 	RecurrenceMonthDay  *int     `json:"recurrenceMonthDay"`
 	RecurrenceLunarDate *string  `json:"recurrenceLunarDate"`
 	RecurrenceEndDate   *string  `json:"recurrenceEndDate"`   // 格式：20251231
+	RRule               *string  `json:"rrule"`               // 传空字符串表示清除
+	RecurrenceAnchor    *string  `json:"recurrenceAnchor"`
+	RecurrenceCatchUp   *string  `json:"recurrenceCatchUp"`
 	TagIDs              *[]uint64 `json:"tagIds"`
+	Scope               *string  `json:"scope"`               // 重复系列的修改范围：this（仅当前实例）、future（当前及之后，默认）、all（全部实例）
 }
 
 func (ctrl *TaskController) GetTasks(c *gin.Context) {
@@ -84,6 +115,11 @@ func (ctrl *TaskController) GetTasks(c *gin.Context) {
 		}
 	}
 
+	// 子任务默认显示在父任务下，不出现在列表中
+	if c.Query("includeSubtasks") != "true" {
+		query = query.Where("parent_id IS NULL")
+	}
+
 	if status != "" {
 		query = query.Where("status = ?", status)
 	} else {
@@ -106,7 +142,7 @@ func (ctrl *TaskController) GetTasks(c *gin.Context) {
 		query = query.Where("due_date != '' AND due_date <= ?", weekLaterStr)
 	}
 
-	query = query.Order("sort_order ASC, created_at DESC").
+	query = query.Order("rank ASC, id ASC").
 		Preload("Tags").
 		Preload("List")
 
@@ -119,7 +155,7 @@ func (ctrl *TaskController) GetTasks(c *gin.Context) {
 		return
 	}
 
-	utils.Success(c, tasks)
+	utils.Success(c, buildTaskResponses(ctrl.db, tasks))
 }
 
 func (ctrl *TaskController) CreateTask(c *gin.Context) {
@@ -152,6 +188,20 @@ func (ctrl *TaskController) CreateTask(c *gin.Context) {
 		return
 	}
 
+	rrule, err := normalizeRRule(req.RRule)
+	if err != nil {
+		utils.BadRequest(c, "Invalid rrule: "+err.Error())
+		return
+	}
+	if !services.ValidRecurrenceAnchor(req.RecurrenceAnchor) {
+		utils.BadRequest(c, "Invalid recurrenceAnchor, expected due or completion")
+		return
+	}
+	if !services.ValidRecurrenceCatchUp(req.RecurrenceCatchUp) {
+		utils.BadRequest(c, "Invalid recurrenceCatchUp, expected skip or each")
+		return
+	}
+
 	task := models.Task{
 		UserID:              userID,
 		ListID:              listID,
@@ -169,13 +219,31 @@ func (ctrl *TaskController) CreateTask(c *gin.Context) {
 		RecurrenceMonthDay:  req.RecurrenceMonthDay,
 		RecurrenceLunarDate: req.RecurrenceLunarDate,
 		RecurrenceEndDate:   req.RecurrenceEndDate,
+		RRule:               rrule,
+		RecurrenceAnchor:    req.RecurrenceAnchor,
+		RecurrenceCatchUp:   req.RecurrenceCatchUp,
 	}
 
+	// 设置了 RRULE 即为重复任务，以截止日期作为规则起始日期
+	if task.RRule != "" {
+		task.IsRecurring = true
+		task.RecurrenceStart = recurrenceStartDate(&task)
+	}
+	resetLegacyRecurrenceStart(&task, nil)
+
 	// 如果是重复任务但没有设置间隔，默认为1
 	if task.IsRecurring && task.RecurrenceInterval == 0 {
 		task.RecurrenceInterval = 1
 	}
 
+	// 新任务排在最前面
+	rank, err := services.TaskRankFirst(ctrl.db, userID)
+	if err != nil {
+		utils.InternalError(c, "Failed to create task")
+		return
+	}
+	task.Rank = rank
+
 	if err := ctrl.db.Create(&task).Error; err != nil {
 		utils.InternalError(c, "Failed to create task")
 		return
@@ -190,9 +258,21 @@ func (ctrl *TaskController) CreateTask(c *gin.Context) {
 		}
 	}
 
+	// 重复任务建立系列，之后的实例都属于同一个系列
+	if err := services.EnsureTaskSeries(ctrl.db, &task); err != nil {
+		utils.Logger.Error("Failed to create task series", zap.Error(err))
+	}
+
+	// 创建提醒
+	if err := ctrl.reminderService.CreateReminderForTask(&task); err != nil {
+		utils.Logger.Error("Failed to create task reminder", zap.Error(err))
+	}
+
 	// 重新加载任务以包含关联数据
 	ctrl.db.Preload("Tags").Preload("List").First(&task, task.ID)
 
+	ctrl.publishTaskChanged(userID, "created", gin.H{"task": task})
+
 	utils.Success(c, task)
 }
 
@@ -215,7 +295,8 @@ func (ctrl *TaskController) GetTask(c *gin.Context) {
 		return
 	}
 
-	utils.Success(c, task)
+	utils.SetETag(c, task.Version)
+	utils.Success(c, ctrl.taskResponse(task))
 }
 
 func (ctrl *TaskController) UpdateTask(c *gin.Context) {
@@ -233,6 +314,10 @@ func (ctrl *TaskController) UpdateTask(c *gin.Context) {
 		utils.NotFound(c, "Task not found")
 		return
 	}
+	if !ctrl.checkTaskIfMatch(c, task) {
+		return
+	}
+	previous := task
 
 	var req UpdateTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -240,6 +325,16 @@ func (ctrl *TaskController) UpdateTask(c *gin.Context) {
 		return
 	}
 
+	// 重复系列的修改范围，默认修改当前及之后的实例
+	scope := services.SeriesScopeFuture
+	if req.Scope != nil && *req.Scope != "" {
+		scope = *req.Scope
+		if scope != services.SeriesScopeThis && scope != services.SeriesScopeFuture && scope != services.SeriesScopeAll {
+			utils.BadRequest(c, "Invalid scope, expected this, future or all")
+			return
+		}
+	}
+
 	// 处理清单ID
 	if req.ListID != nil {
 		// 验证清单存在且属于当前用户
@@ -262,6 +357,11 @@ func (ctrl *TaskController) UpdateTask(c *gin.Context) {
 		task.Priority = *req.Priority
 	}
 	if req.DueDate != nil {
+		// 移动重复任务的实例时记住原日期，之后不会在原日期再次生成
+		if task.IsRecurring && task.DueDate != "" && *req.DueDate != task.DueDate {
+			services.AddExDate(&task, task.DueDate)
+			services.RemoveExDate(&task, *req.DueDate)
+		}
 		task.DueDate = *req.DueDate
 	}
 	if req.DueTime != nil {
@@ -291,14 +391,44 @@ func (ctrl *TaskController) UpdateTask(c *gin.Context) {
 	if req.RecurrenceEndDate != nil {
 		task.RecurrenceEndDate = *req.RecurrenceEndDate
 	}
+	if req.RecurrenceAnchor != nil {
+		if !services.ValidRecurrenceAnchor(*req.RecurrenceAnchor) {
+			utils.BadRequest(c, "Invalid recurrenceAnchor, expected due or completion")
+			return
+		}
+		task.RecurrenceAnchor = *req.RecurrenceAnchor
+	}
+	if req.RecurrenceCatchUp != nil {
+		if !services.ValidRecurrenceCatchUp(*req.RecurrenceCatchUp) {
+			utils.BadRequest(c, "Invalid recurrenceCatchUp, expected skip or each")
+			return
+		}
+		task.RecurrenceCatchUp = *req.RecurrenceCatchUp
+	}
+	if req.RRule != nil {
+		rrule, err := normalizeRRule(*req.RRule)
+		if err != nil {
+			utils.BadRequest(c, "Invalid rrule: "+err.Error())
+			return
+		}
+		// 规则变化后从当前截止日期重新开始计算
+		if rrule != task.RRule {
+			task.RRule = rrule
+			task.RecurrenceStart = ""
+			if rrule != "" {
+				task.IsRecurring = true
+				task.RecurrenceStart = recurrenceStartDate(&task)
+			}
+		}
+	}
+	resetLegacyRecurrenceStart(&task, &previous)
 
 	// 如果是重复任务但没有设置间隔，默认为1
 	if task.IsRecurring && task.RecurrenceInterval == 0 {
 		task.RecurrenceInterval = 1
 	}
 
-	if err := ctrl.db.Save(&task).Error; err != nil {
-		utils.InternalError(c, "Failed to update task")
+	if !saveVersioned(c, ctrl.db, &task, task.ID, task.Version, "Failed to update task", "Tags", "List") {
 		return
 	}
 
@@ -313,10 +443,40 @@ func (ctrl *TaskController) UpdateTask(c *gin.Context) {
 		}
 	}
 
+	// 同步到系列模板和范围内的其他实例
+	var affected []models.Task
+	if task.SeriesID != nil && scope != services.SeriesScopeThis {
+		affected, err = ctrl.applySeriesUpdate(&task, &req, scope)
+		if err != nil {
+			utils.Logger.Error("Failed to update task series", zap.Error(err))
+		}
+	}
+
+	// 新设置为重复任务时建立系列
+	if err := services.EnsureTaskSeries(ctrl.db, &task); err != nil {
+		utils.Logger.Error("Failed to create task series", zap.Error(err))
+	}
+
+	// 同步提醒
+	if err := ctrl.reminderService.CreateReminderForTask(&task); err != nil {
+		utils.Logger.Error("Failed to sync task reminder", zap.Error(err))
+	}
+	for i := range affected {
+		if affected[i].Status == "todo" {
+			ctrl.reminderService.CreateReminderForTask(&affected[i])
+		}
+	}
+
 	// 重新加载任务以包含关联数据
 	ctrl.db.Preload("Tags").Preload("List").First(&task, task.ID)
 
-	utils.Success(c, task)
+	ctrl.publishTaskChanged(userID, "updated", gin.H{"task": task})
+	for _, instance := range affected {
+		ctrl.publishTaskChanged(userID, "updated", gin.H{"task": instance})
+	}
+
+	utils.SetETag(c, task.Version)
+	utils.Success(c, ctrl.taskResponse(task))
 }
 
 func (ctrl *TaskController) DeleteTask(c *gin.Context) {
@@ -329,14 +489,18 @@ func (ctrl *TaskController) DeleteTask(c *gin.Context) {
 		return
 	}
 
-	result := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).Delete(&models.Task{})
-	if result.Error != nil {
-		utils.InternalError(c, "Failed to delete task")
+	var task models.Task
+	if err := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+	if !ctrl.checkTaskIfMatch(c, task) {
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		utils.NotFound(c, "Task not found")
+	// 连同子任务、检查项和提醒一起删除
+	if err := ctrl.deleteTaskTree(userID, task.ID); err != nil {
+		utils.InternalError(c, "Failed to delete task")
 		return
 	}
 
@@ -358,6 +522,9 @@ func (ctrl *TaskController) CompleteTask(c *gin.Context) {
 		utils.NotFound(c, "Task not found")
 		return
 	}
+	if !ctrl.checkTaskIfMatch(c, task) {
+		return
+	}
 
 	// 切换任务状态
 	now := time.Now()
@@ -366,15 +533,34 @@ func (ctrl *TaskController) CompleteTask(c *gin.Context) {
 		task.Status = "todo"
 		task.CompletedAt = ""
 		
-		if err := ctrl.db.Save(&task).Error; err != nil {
-			utils.InternalError(c, "Failed to uncomplete task")
+		if !saveVersioned(c, ctrl.db, &task, task.ID, task.Version, "Failed to uncomplete task", "Tags", "List") {
 			return
 		}
+
+		// 恢复提醒
+		ctrl.reminderService.CreateReminderForTask(&task)
+
+		ctrl.publishTaskChanged(userID, "uncompleted", gin.H{"task": task})
 		
+		utils.SetETag(c, task.Version)
 		utils.Success(c, task)
 		return
 	}
 
+	// 前置任务未完成：严格模式下拒绝完成，否则完成并给出警告
+	var warnings []string
+	if blockers := services.IncompleteBlockers(ctrl.db, []uint64{task.ID})[task.ID]; len(blockers) > 0 {
+		ids := make([]string, len(blockers))
+		for i, id := range blockers {
+			ids[i] = strconv.FormatUint(id, 10)
+		}
+		if ctrl.strictDependencies(userID) {
+			utils.Conflict(c, "Task is blocked by incomplete tasks: "+strings.Join(ids, ","))
+			return
+		}
+		warnings = append(warnings, "Completed while blocked by incomplete tasks: "+strings.Join(ids, ","))
+	}
+
 	// 标记为完成 - 从待办拖到已完成
 	task.Status = "completed"
 	task.CompletedAt = now.Format("20060102 15:04") // 格式：20251105 18:20
@@ -388,38 +574,132 @@ func (ctrl *TaskController) CompleteTask(c *gin.Context) {
 	}()
 
 	// 保存完成状态
-	if err := tx.Save(&task).Error; err != nil {
+	if !saveVersioned(c, tx, &task, task.ID, task.Version, "Failed to complete task", "Tags", "List") {
 		tx.Rollback()
-		utils.InternalError(c, "Failed to complete task")
 		return
 	}
 
-	// 如果是重复任务，生成下一个任务实例
-	if task.IsRecurring {
-		recurrenceService := services.NewRecurrenceService()
-		nextTask, err := recurrenceService.GenerateNextRecurringTask(&task)
-		if err != nil {
-			// 记录错误但不中断完成操作
-			// 可以添加日志记录
-		} else if nextTask != nil {
-			// 创建下一个任务（ID由数据库自动生成）
-			if err := tx.Create(nextTask).Error; err != nil {
-				// 记录错误但不中断完成操作
-				// 可以添加日志记录
+	// completeSubtasks=true 时同时完成所有未完成的子任务和检查项
+	var completedSubtasks []models.Task
+	if c.Query("completeSubtasks") == "true" {
+		ids := descendantIDs(tx, userID, task.ID)
+		if len(ids) > 0 {
+			if err := tx.Where("id IN ? AND status = ?", ids, "todo").Find(&completedSubtasks).Error; err != nil {
+				tx.Rollback()
+				utils.InternalError(c, "Failed to complete subtasks")
+				return
+			}
+			for i := range completedSubtasks {
+				completedSubtasks[i].Status = "completed"
+				completedSubtasks[i].CompletedAt = task.CompletedAt
+				if err := tx.Save(&completedSubtasks[i]).Error; err != nil {
+					tx.Rollback()
+					utils.InternalError(c, "Failed to complete subtasks")
+					return
+				}
 			}
 		}
+
+		if err := tx.Model(&models.ChecklistItem{}).
+			Where("task_id IN ? AND user_id = ? AND is_completed = ?", append(ids, task.ID), userID, false).
+			Updates(map[string]interface{}{"is_completed": true, "completed_at": task.CompletedAt}).Error; err != nil {
+			tx.Rollback()
+			utils.InternalError(c, "Failed to complete checklist")
+			return
+		}
 	}
 
+	// 如果是重复任务，生成下一个任务实例（逾期时可能按补偿策略生成多个）
+	nextTasks := ctrl.generateNextInstances(tx, &task)
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		utils.InternalError(c, "Failed to commit transaction")
 		return
 	}
 
-	// 更新统计数据
+	// 已完成任务不再提醒，为下一个重复实例创建提醒
+	ctrl.reminderService.CreateReminderForTask(&task)
+	for _, nextTask := range nextTasks {
+		ctrl.reminderService.CreateReminderForTask(nextTask)
+	}
+
+	// 更新统计数据，连带完成的子任务各计一次
 	updateDailyStatistics(ctrl.db, userID, now, &task)
+	for i := range completedSubtasks {
+		ctrl.reminderService.CreateReminderForTask(&completedSubtasks[i])
+		updateDailyStatistics(ctrl.db, userID, now, &completedSubtasks[i])
+	}
 
-	utils.Success(c, task)
+	ctrl.publishTaskChanged(userID, "completed", gin.H{"task": task})
+	for _, subtask := range completedSubtasks {
+		ctrl.publishTaskChanged(userID, "completed", gin.H{"task": subtask})
+	}
+	for _, nextTask := range nextTasks {
+		ctrl.publishTaskChanged(userID, "created", gin.H{"task": nextTask})
+	}
+
+	response := ctrl.taskResponse(task)
+	response.Warnings = warnings
+	utils.SetETag(c, task.Version)
+	utils.Success(c, response)
+}
+
+// generateNextInstances 在事务中为刚完成的重复任务生成下一个实例（逾期时可能按补偿策略生成多个），
+// 生成失败只记录日志，不影响完成操作
+func (ctrl *TaskController) generateNextInstances(tx *gorm.DB, task *models.Task) []*models.Task {
+	var nextTasks []*models.Task
+	if !task.IsRecurring {
+		return nextTasks
+	}
+
+	// 下一个实例按系列模板生成
+	if err := services.EnsureTaskSeries(tx, task); err != nil {
+		utils.Logger.Error("Failed to create task series", zap.Error(err))
+	}
+	var series *models.TaskSeries
+	if task.SeriesID != nil {
+		var loaded models.TaskSeries
+		if err := tx.First(&loaded, *task.SeriesID).Error; err == nil {
+			series = &loaded
+		}
+	}
+
+	// 系列中已有的日期不再重复生成
+	existingDates := make(map[string]bool)
+	if task.SeriesID != nil {
+		var dueDates []string
+		tx.Model(&models.Task{}).Where("series_id = ?", *task.SeriesID).Pluck("due_date", &dueDates)
+		for _, dueDate := range dueDates {
+			existingDates[dueDate] = true
+		}
+	}
+
+	generated, err := ctrl.recurrenceService.GenerateNextRecurringTasks(task, series, existingDates)
+	if err != nil {
+		// 记录错误但不中断完成操作
+		utils.Logger.Error("Failed to generate next recurring task", zap.Error(err))
+	}
+	// 下一个实例排在原任务之后
+	ranks, err := services.TaskRankAfter(tx, task.UserID, task.Rank, len(generated))
+	if err != nil {
+		utils.Logger.Error("Failed to generate task rank", zap.Error(err))
+	}
+	for i, nextTask := range generated {
+		if i < len(ranks) {
+			nextTask.Rank = ranks[i]
+		}
+		// 创建下一个任务（ID由数据库自动生成）
+		if err := tx.Create(nextTask).Error; err != nil {
+			// 记录错误但不中断完成操作
+			utils.Logger.Error("Failed to create next recurring task", zap.Error(err))
+			continue
+		}
+		ctrl.associateSeriesTags(tx, nextTask, series)
+		nextTasks = append(nextTasks, nextTask)
+	}
+
+	return nextTasks
 }
 
 // AbandonTask 放弃任务
@@ -438,6 +718,9 @@ func (ctrl *TaskController) AbandonTask(c *gin.Context) {
 		utils.NotFound(c, "Task not found")
 		return
 	}
+	if !ctrl.checkTaskIfMatch(c, task) {
+		return
+	}
 
 	// 切换任务状态
 	now := time.Now()
@@ -451,11 +734,16 @@ func (ctrl *TaskController) AbandonTask(c *gin.Context) {
 		task.CompletedAt = now.Format("20060102 15:04") // 格式：20251105 18:20
 	}
 
-	if err := ctrl.db.Save(&task).Error; err != nil {
-		utils.InternalError(c, "Failed to abandon task")
+	if !saveVersioned(c, ctrl.db, &task, task.ID, task.Version, "Failed to abandon task", "Tags", "List") {
 		return
 	}
 
+	// 放弃后删除提醒，恢复后重新创建
+	ctrl.reminderService.CreateReminderForTask(&task)
+
+	ctrl.publishTaskChanged(userID, "updated", gin.H{"task": task})
+
+	utils.SetETag(c, task.Version)
 	utils.Success(c, task)
 }
 
@@ -474,6 +762,9 @@ func (ctrl *TaskController) UpdatePriority(c *gin.Context) {
 		utils.NotFound(c, "Task not found")
 		return
 	}
+	if !ctrl.checkTaskIfMatch(c, task) {
+		return
+	}
 
 	var req struct {
 		Priority int `json:"priority"`
@@ -485,14 +776,368 @@ func (ctrl *TaskController) UpdatePriority(c *gin.Context) {
 
 	task.Priority = req.Priority
 
-	if err := ctrl.db.Save(&task).Error; err != nil {
-		utils.InternalError(c, "Failed to update priority")
+	if !saveVersioned(c, ctrl.db, &task, task.ID, task.Version, "Failed to update priority", "Tags", "List") {
+		return
+	}
+
+	ctrl.publishTaskChanged(userID, "updated", gin.H{"task": task})
+
+	utils.SetETag(c, task.Version)
+	utils.Success(c, task)
+}
+
+// GetOccurrences 预览重复任务接下来的截止日期
+// 参数 from 格式为 20251105，默认今天；count 默认 10，最多 100
+func (ctrl *TaskController) GetOccurrences(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	taskIDStr := c.Param("id")
+
+	taskID, err := strconv.ParseUint(taskIDStr, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return
+	}
+
+	var task models.Task
+	if err := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	// 默认从今天开始，与截止日期一样按日期字符串解析
+	from, _ := utils.ParseDate(utils.FormatDate(utils.Now()))
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err = utils.ParseDate(fromStr)
+		if err != nil {
+			utils.BadRequest(c, "Invalid from date")
+			return
+		}
+	}
+
+	count := 10
+	if countStr := c.Query("count"); countStr != "" {
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count <= 0 {
+			utils.BadRequest(c, "Invalid count")
+			return
+		}
+		if count > 100 {
+			count = 100
+		}
+	}
+
+	occurrences, err := ctrl.recurrenceService.Occurrences(&task, from, count)
+	if err != nil {
+		utils.BadRequest(c, "Invalid recurrence rule: "+err.Error())
+		return
+	}
+
+	dates := make([]string, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		dates = append(dates, utils.FormatDate(occurrence))
+	}
+
+	exDates := services.ParseExDates(task.RecurrenceExDates)
+	if exDates == nil {
+		exDates = []string{}
+	}
+
+	utils.Success(c, gin.H{
+		"taskId":      task.ID,
+		"from":        utils.FormatDate(from),
+		"occurrences": dates,
+		"exDates":     exDates,
+	})
+}
+
+// SkipOccurrence 跳过重复任务的当前实例：截止日期推进到下一次，不记录完成和统计
+func (ctrl *TaskController) SkipOccurrence(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	taskIDStr := c.Param("id")
+
+	taskID, err := strconv.ParseUint(taskIDStr, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
 		return
 	}
 
+	var task models.Task
+	if err := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+	if !ctrl.checkTaskIfMatch(c, task) {
+		return
+	}
+
+	if !task.IsRecurring || task.Status != "todo" || task.DueDate == "" {
+		utils.BadRequest(c, "Only pending recurring tasks with a due date can be skipped")
+		return
+	}
+
+	dueDate, err := utils.ParseDate(task.DueDate)
+	if err != nil {
+		utils.BadRequest(c, "Invalid due date")
+		return
+	}
+
+	// 当前实例记为例外日期，再计算下一次
+	skippedDate := task.DueDate
+	services.AddExDate(&task, skippedDate)
+
+	nextDueDate, err := ctrl.recurrenceService.CalculateNextDueDate(&task, dueDate)
+	if err != nil {
+		utils.BadRequest(c, "Invalid recurrence rule: "+err.Error())
+		return
+	}
+	if nextDueDate == nil {
+		utils.BadRequest(c, "No further occurrences to skip to")
+		return
+	}
+
+	task.ReminderTime = services.ShiftReminderTime(&task, *nextDueDate)
+	task.DueDate = utils.FormatDate(*nextDueDate)
+
+	if !saveVersioned(c, ctrl.db, &task, task.ID, task.Version, "Failed to skip occurrence", "Tags", "List") {
+		return
+	}
+
+	// 提醒跟随新的截止日期
+	ctrl.reminderService.CreateReminderForTask(&task)
+
+	ctrl.db.Preload("Tags").Preload("List").First(&task, task.ID)
+
+	ctrl.publishTaskChanged(userID, "skipped", gin.H{"task": task, "skippedDate": skippedDate})
+
+	utils.SetETag(c, task.Version)
 	utils.Success(c, task)
 }
 
+// GetSeries 获取重复任务所属系列的模板和全部实例（包括已完成、已放弃的历史实例）
+func (ctrl *TaskController) GetSeries(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	taskIDStr := c.Param("id")
+
+	taskID, err := strconv.ParseUint(taskIDStr, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return
+	}
+
+	var task models.Task
+	if err := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	if task.SeriesID == nil {
+		utils.NotFound(c, "Task does not belong to a recurring series")
+		return
+	}
+
+	var series models.TaskSeries
+	if err := ctrl.db.Where("id = ? AND user_id = ?", *task.SeriesID, userID).First(&series).Error; err != nil {
+		utils.NotFound(c, "Series not found")
+		return
+	}
+
+	var instances []models.Task
+	if err := ctrl.db.Where("series_id = ? AND user_id = ?", series.ID, userID).
+		Order("due_date ASC, id ASC").
+		Preload("Tags").
+		Find(&instances).Error; err != nil {
+		utils.InternalError(c, "Failed to get series instances")
+		return
+	}
+
+	response := TaskSeriesResponse{
+		Series:    series,
+		Instances: instances,
+		Total:     len(instances),
+	}
+	for _, instance := range instances {
+		switch instance.Status {
+		case "completed":
+			response.Completed++
+		case "abandoned":
+			response.Abandoned++
+		default:
+			response.Pending++
+		}
+	}
+
+	utils.Success(c, response)
+}
+
+// TaskSeriesResponse 重复任务系列及其所有实例
+type TaskSeriesResponse struct {
+	Series    models.TaskSeries `json:"series"`
+	Instances []models.Task     `json:"instances"`
+	Total     int               `json:"total"`
+	Completed int               `json:"completed"`
+	Abandoned int               `json:"abandoned"`
+	Pending   int               `json:"pending"`
+}
+
+// applySeriesUpdate 将本次修改同步到系列模板及范围内的其他实例，返回受影响的实例
+// future 只影响截止日期不早于当前实例的未完成实例；all 影响全部实例
+func (ctrl *TaskController) applySeriesUpdate(task *models.Task, req *UpdateTaskRequest, scope string) ([]models.Task, error) {
+	updates := seriesFieldUpdates(req, task)
+	if len(updates) == 0 && req.TagIDs == nil {
+		return nil, nil
+	}
+
+	query := ctrl.db.Where("series_id = ? AND user_id = ? AND id <> ?", *task.SeriesID, task.UserID, task.ID)
+	if scope == services.SeriesScopeFuture {
+		query = query.Where("status = ?", "todo")
+		if task.DueDate != "" {
+			query = query.Where("(due_date = '' OR due_date >= ?)", task.DueDate)
+		}
+	}
+
+	var instances []models.Task
+	if err := query.Find(&instances).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, len(instances))
+	for i, instance := range instances {
+		ids[i] = instance.ID
+	}
+
+	var tags []models.Tag
+	if req.TagIDs != nil && len(*req.TagIDs) > 0 {
+		ctrl.db.Where("id IN ? AND user_id = ?", *req.TagIDs, task.UserID).Find(&tags)
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		seriesUpdates := make(map[string]interface{}, len(updates)+1)
+		for column, value := range updates {
+			seriesUpdates[column] = value
+		}
+		if req.TagIDs != nil {
+			tagIDs := make([]uint64, len(tags))
+			for i, tag := range tags {
+				tagIDs[i] = tag.ID
+			}
+			seriesUpdates["tag_ids"] = services.FormatTagIDs(tagIDs)
+		}
+		if err := tx.Model(&models.TaskSeries{}).Where("id = ?", *task.SeriesID).Updates(seriesUpdates).Error; err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Task{}).Where("id IN ?", ids).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.TagIDs != nil {
+			for i := range instances {
+				if err := tx.Model(&instances[i]).Association("Tags").Replace(tags); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	ctrl.db.Where("id IN ?", ids).Preload("Tags").Preload("List").Find(&instances)
+	return instances, nil
+}
+
+// seriesFieldUpdates 本次请求修改的系列模板字段，列名与 tasks 表一致
+// 重复规则作为整体同步，截止日期、提醒时间和例外日期属于实例本身
+func seriesFieldUpdates(req *UpdateTaskRequest, task *models.Task) map[string]interface{} {
+	updates := make(map[string]interface{})
+	if req.ListID != nil {
+		updates["list_id"] = task.ListID
+	}
+	if req.Title != nil {
+		updates["title"] = task.Title
+	}
+	if req.Description != nil {
+		updates["description"] = task.Description
+	}
+	if req.Priority != nil {
+		updates["priority"] = task.Priority
+	}
+	if req.DueTime != nil {
+		updates["due_time"] = task.DueTime
+	}
+
+	if req.IsRecurring != nil || req.RecurrenceType != nil || req.RecurrenceInterval != nil ||
+		req.RecurrenceWeekdays != nil || req.RecurrenceMonthDay != nil || req.RecurrenceLunarDate != nil ||
+		req.RecurrenceEndDate != nil || req.RRule != nil || req.RecurrenceAnchor != nil || req.RecurrenceCatchUp != nil {
+		updates["is_recurring"] = task.IsRecurring
+		updates["recurrence_type"] = task.RecurrenceType
+		updates["recurrence_interval"] = task.RecurrenceInterval
+		updates["recurrence_weekdays"] = task.RecurrenceWeekdays
+		updates["recurrence_month_day"] = task.RecurrenceMonthDay
+		updates["recurrence_lunar_date"] = task.RecurrenceLunarDate
+		updates["recurrence_end_date"] = task.RecurrenceEndDate
+		updates["r_rule"] = task.RRule
+		updates["recurrence_start"] = task.RecurrenceStart
+		updates["recurrence_anchor"] = task.RecurrenceAnchor
+		updates["recurrence_catch_up"] = task.RecurrenceCatchUp
+	}
+
+	return updates
+}
+
+// associateSeriesTags 为新生成的实例关联系列模板的标签
+func (ctrl *TaskController) associateSeriesTags(tx *gorm.DB, task *models.Task, series *models.TaskSeries) {
+	if series == nil {
+		return
+	}
+	tagIDs := services.ParseTagIDs(series.TagIDs)
+	if len(tagIDs) == 0 {
+		return
+	}
+
+	var tags []models.Tag
+	tx.Where("id IN ? AND user_id = ?", tagIDs, task.UserID).Find(&tags)
+	if err := tx.Model(task).Association("Tags").Replace(tags); err != nil {
+		utils.Logger.Error("Failed to associate series tags", zap.Error(err))
+	}
+}
+
+// normalizeRRule 校验并规范化 RRULE，空字符串表示不使用
+func normalizeRRule(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	rule, err := utils.ParseRRule(value)
+	if err != nil {
+		return "", err
+	}
+	return rule.String(), nil
+}
+
+// recurrenceStartDate 重复规则的起始日期，没有截止日期时使用今天
+func recurrenceStartDate(task *models.Task) string {
+	if task.DueDate != "" {
+		return task.DueDate
+	}
+	return utils.FormatDate(utils.Now())
+}
+
+// resetLegacyRecurrenceStart 旧版重复规则（没有 RRULE）同样以截止日期作为起始日期，按月、按年重复时据此确定日期；
+// 新建（previous 为 nil）或重复类型、日期变化后重新起算
+func resetLegacyRecurrenceStart(task, previous *models.Task) {
+	if !task.IsRecurring || task.RRule != "" {
+		return
+	}
+	if previous == nil || !previous.IsRecurring || previous.RRule != "" ||
+		previous.RecurrenceType != task.RecurrenceType || previous.RecurrenceMonthDay != task.RecurrenceMonthDay {
+		task.RecurrenceStart = recurrenceStartDate(task)
+	}
+}
+
 // 辅助函数：更新每日统计
 func updateDailyStatistics(db *gorm.DB, userID uint64, date time.Time, task *models.Task) {
 	// 将日期转换为字符串格式：20251105
@@ -574,31 +1219,3 @@ func updateDailyStatistics(db *gorm.DB, userID uint64, date time.Time, task *mod
 			zap.Int("noDate", stats.NoDateCompletedTasks))
 	}
 }
-
-// ReorderTasks 批量更新任务排序
-func (ctrl *TaskController) ReorderTasks(c *gin.Context) {
-	userID := middleware.GetUserID(c)
-
-	type ReorderRequest struct {
-		TaskIDs []uint64 `json:"taskIds" binding:"required"`
-	}
-
-	var req ReorderRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.BadRequest(c, err.Error())
-		return
-	}
-
-	// 更新每个任务的排序顺序
-	for index, taskID := range req.TaskIDs {
-		var task models.Task
-		if err := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
-			continue // 跳过不存在或不属于当前用户的任务
-		}
-
-		task.SortOrder = index
-		ctrl.db.Save(&task)
-	}
-
-	utils.Success(c, gin.H{"message": "Tasks reordered successfully"})
-}
@@ -0,0 +1,361 @@
+package controllers
+
+import (
+	"errors"
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/models"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 批量操作类型
+const (
+	BatchOpComplete    = "complete"
+	BatchOpAbandon     = "abandon"
+	BatchOpMove        = "move"
+	BatchOpSetDueDate  = "setDueDate"
+	BatchOpSetTags     = "setTags"
+	BatchOpAddTags     = "addTags"
+	BatchOpRemoveTags  = "removeTags"
+	BatchOpSetPriority = "setPriority"
+	BatchOpDelete      = "delete"
+)
+
+// maxBatchTaskCount 单次批量操作的最大任务数
+const maxBatchTaskCount = 500
+
+// BatchTaskRequest 批量操作请求，按 operation 读取对应的参数
+type BatchTaskRequest struct {
+	IDs       []uint64 `json:"ids" binding:"required"`
+	Operation string   `json:"operation" binding:"required"`
+	ListID    *uint64  `json:"listId"`   // move
+	DueDate   *string  `json:"dueDate"`  // setDueDate，格式：20251105，空字符串表示清除
+	TagIDs    []uint64 `json:"tagIds"`   // setTags、addTags、removeTags
+	Priority  *int     `json:"priority"` // setPriority，0-3
+}
+
+// BatchTaskResult 单个任务的操作结果
+type BatchTaskResult struct {
+	ID       uint64       `json:"id"`
+	Success  bool         `json:"success"`
+	Error    string       `json:"error,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+	Task     *models.Task `json:"task,omitempty"` // 操作后的任务，删除时为空
+}
+
+// batchItemError 单个任务无法执行操作，只记入该任务的结果，不影响其他任务
+type batchItemError struct {
+	msg string
+}
+
+func (e *batchItemError) Error() string {
+	return e.msg
+}
+
+// BatchTasks 在一个事务中对多个任务执行同一操作，返回每个任务的结果。
+// 单个任务不存在或被前置任务阻塞（严格模式）时只标记该任务失败，数据库错误时整体回滚。
+// 已处于目标状态的任务视为成功；重复任务只修改所选实例，完成时生成下一个实例并更新统计
+func (ctrl *TaskController) BatchTasks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req BatchTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	ids := uniqueIDs(req.IDs)
+	if len(ids) == 0 {
+		utils.BadRequest(c, "ids is required")
+		return
+	}
+	if len(ids) > maxBatchTaskCount {
+		utils.BadRequest(c, "Too many tasks, at most "+strconv.Itoa(maxBatchTaskCount))
+		return
+	}
+
+	// 校验操作参数
+	var tags []models.Tag
+	switch req.Operation {
+	case BatchOpComplete, BatchOpAbandon, BatchOpDelete:
+	case BatchOpMove:
+		if req.ListID == nil {
+			utils.BadRequest(c, "listId is required")
+			return
+		}
+		var list models.List
+		if err := ctrl.db.Where("id = ? AND user_id = ?", *req.ListID, userID).First(&list).Error; err != nil {
+			utils.BadRequest(c, "List not found")
+			return
+		}
+	case BatchOpSetDueDate:
+		if req.DueDate == nil {
+			utils.BadRequest(c, "dueDate is required")
+			return
+		}
+		if *req.DueDate != "" {
+			if _, err := utils.ParseDate(*req.DueDate); err != nil {
+				utils.BadRequest(c, "Invalid dueDate, expected YYYYMMDD")
+				return
+			}
+		}
+	case BatchOpSetTags, BatchOpAddTags, BatchOpRemoveTags:
+		if req.TagIDs == nil || (req.Operation != BatchOpSetTags && len(req.TagIDs) == 0) {
+			utils.BadRequest(c, "tagIds is required")
+			return
+		}
+		if len(req.TagIDs) > 0 {
+			ctrl.db.Where("id IN ? AND user_id = ?", req.TagIDs, userID).Find(&tags)
+			if len(tags) != len(uniqueIDs(req.TagIDs)) {
+				utils.BadRequest(c, "Tag not found")
+				return
+			}
+		}
+	case BatchOpSetPriority:
+		if req.Priority == nil || *req.Priority < 0 || *req.Priority > 3 {
+			utils.BadRequest(c, "priority must be between 0 and 3")
+			return
+		}
+	default:
+		utils.BadRequest(c, "Invalid operation")
+		return
+	}
+
+	var loaded []models.Task
+	if err := ctrl.db.Where("id IN ? AND user_id = ?", ids, userID).Find(&loaded).Error; err != nil {
+		utils.InternalError(c, "Failed to get tasks")
+		return
+	}
+	tasks := make(map[uint64]*models.Task, len(loaded))
+	for i := range loaded {
+		tasks[loaded[i].ID] = &loaded[i]
+	}
+
+	// 完成时检查前置任务：先处理前置任务，本次事务中已完成的前置任务不算阻塞
+	order := make([]int, len(ids))
+	for i := range ids {
+		order[i] = i
+	}
+	var blockers map[uint64][]uint64
+	strict := false
+	completedIDs := make(map[uint64]bool)
+	if req.Operation == BatchOpComplete {
+		blockers = services.IncompleteBlockers(ctrl.db, ids)
+		strict = ctrl.strictDependencies(userID)
+		order = blockersFirstOrder(ids, blockers)
+	}
+
+	now := time.Now()
+	results := make([]BatchTaskResult, len(ids))
+	var completed []*models.Task
+	var nextTasks []*models.Task
+	var deletedIDs []uint64
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		for _, i := range order {
+			id := ids[i]
+			results[i] = BatchTaskResult{ID: id}
+			task, ok := tasks[id]
+			if !ok {
+				results[i].Error = "Task not found"
+				continue
+			}
+
+			var err error
+			switch req.Operation {
+			case BatchOpComplete:
+				if task.Status == "completed" {
+					break
+				}
+				var pending []string
+				for _, blockerID := range blockers[id] {
+					if !completedIDs[blockerID] {
+						pending = append(pending, strconv.FormatUint(blockerID, 10))
+					}
+				}
+				if len(pending) > 0 {
+					if strict {
+						err = &batchItemError{"Task is blocked by incomplete tasks: " + strings.Join(pending, ",")}
+						break
+					}
+					results[i].Warnings = append(results[i].Warnings, "Completed while blocked by incomplete tasks: "+strings.Join(pending, ","))
+				}
+				task.Status = "completed"
+				task.CompletedAt = now.Format("20060102 15:04")
+				if err = tx.Save(task).Error; err == nil {
+					completedIDs[id] = true
+					completed = append(completed, task)
+					nextTasks = append(nextTasks, ctrl.generateNextInstances(tx, task)...)
+				}
+			case BatchOpAbandon:
+				if task.Status == "abandoned" {
+					break
+				}
+				task.Status = "abandoned"
+				task.CompletedAt = now.Format("20060102 15:04")
+				err = tx.Save(task).Error
+			case BatchOpMove:
+				task.ListID = *req.ListID
+				err = tx.Save(task).Error
+			case BatchOpSetDueDate:
+				// 与单个修改一样，移动重复任务的实例时记住原日期
+				if task.IsRecurring && task.DueDate != "" && *req.DueDate != task.DueDate {
+					services.AddExDate(task, task.DueDate)
+					services.RemoveExDate(task, *req.DueDate)
+				}
+				task.DueDate = *req.DueDate
+				err = tx.Save(task).Error
+			case BatchOpSetTags:
+				err = tx.Model(task).Association("Tags").Replace(tags)
+			case BatchOpAddTags:
+				err = tx.Model(task).Association("Tags").Append(tags)
+			case BatchOpRemoveTags:
+				err = tx.Model(task).Association("Tags").Delete(tags)
+			case BatchOpSetPriority:
+				task.Priority = *req.Priority
+				err = tx.Save(task).Error
+			case BatchOpDelete:
+				// 连同子任务和检查项一起删除
+				treeIDs := append([]uint64{id}, descendantIDs(tx, userID, id)...)
+				if err = tx.Where("id IN ? AND user_id = ?", treeIDs, userID).Delete(&models.Task{}).Error; err == nil {
+					err = tx.Where("task_id IN ? AND user_id = ?", treeIDs, userID).Delete(&models.ChecklistItem{}).Error
+				}
+				deletedIDs = append(deletedIDs, treeIDs...)
+			}
+
+			var itemErr *batchItemError
+			if errors.As(err, &itemErr) {
+				results[i].Error = itemErr.msg
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			results[i].Success = true
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to apply batch operation", zap.String("operation", req.Operation), zap.Error(err))
+		utils.InternalError(c, "Failed to apply batch operation")
+		return
+	}
+
+	// 提交后同步提醒、更新统计并通知其他设备
+	if req.Operation == BatchOpDelete {
+		for _, id := range uniqueIDs(deletedIDs) {
+			ctrl.reminderService.DeleteRemindersForEntity("task", id)
+			ctrl.publishTaskChanged(userID, "deleted", gin.H{"taskId": id})
+		}
+	} else {
+		var updatedIDs []uint64
+		for _, result := range results {
+			if result.Success {
+				updatedIDs = append(updatedIDs, result.ID)
+			}
+		}
+		var updated []models.Task
+		if len(updatedIDs) > 0 {
+			ctrl.db.Preload("Tags").Where("id IN ?", updatedIDs).Find(&updated)
+		}
+		byID := make(map[uint64]*models.Task, len(updated))
+		for i := range updated {
+			byID[updated[i].ID] = &updated[i]
+		}
+		for i := range results {
+			if task, ok := byID[results[i].ID]; ok {
+				results[i].Task = task
+				ctrl.reminderService.CreateReminderForTask(task)
+				ctrl.publishTaskChanged(userID, "updated", gin.H{"task": task})
+			}
+		}
+	}
+	for _, task := range completed {
+		updateDailyStatistics(ctrl.db, userID, now, task)
+	}
+	for _, nextTask := range nextTasks {
+		ctrl.reminderService.CreateReminderForTask(nextTask)
+		ctrl.publishTaskChanged(userID, "created", gin.H{"task": nextTask})
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+
+	if nextTasks == nil {
+		nextTasks = []*models.Task{}
+	}
+	utils.Success(c, gin.H{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"created":   nextTasks, // 完成重复任务时生成的下一个实例
+	})
+}
+
+// blockersFirstOrder 返回 ids 的处理顺序（下标），同一批中的前置任务排在被阻塞的任务之前，
+// 其余保持原顺序；存在循环依赖时剩下的任务按原顺序排在最后
+func blockersFirstOrder(ids []uint64, blockers map[uint64][]uint64) []int {
+	index := make(map[uint64]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+	waiting := make([]int, len(ids))     // 尚未处理的同批前置任务数
+	dependents := make(map[uint64][]int) // 前置任务 -> 被它阻塞的任务下标
+	for i, id := range ids {
+		for _, blockerID := range blockers[id] {
+			if _, ok := index[blockerID]; ok {
+				waiting[i]++
+				dependents[blockerID] = append(dependents[blockerID], i)
+			}
+		}
+	}
+
+	order := make([]int, 0, len(ids))
+	added := make([]bool, len(ids))
+	var visit func(i int)
+	visit = func(i int) {
+		added[i] = true
+		order = append(order, i)
+		for _, j := range dependents[ids[i]] {
+			waiting[j]--
+			if waiting[j] == 0 && !added[j] {
+				visit(j)
+			}
+		}
+	}
+	for i := range ids {
+		if waiting[i] == 0 && !added[i] {
+			visit(i)
+		}
+	}
+	for i := range ids {
+		if !added[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// uniqueIDs 去掉重复的 ID，保持原顺序
+func uniqueIDs(ids []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(ids))
+	result := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
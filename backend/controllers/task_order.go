@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/models"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// MoveTaskRequest 移动任务请求，afterId 和 beforeId 为移动后紧挨着的前后任务，都不传时移到最后。
+// 在按清单或优先级分组的视图中拖到其他分组时，同时传入 listId 或 priority
+type MoveTaskRequest struct {
+	AfterID  *uint64 `json:"afterId"`
+	BeforeID *uint64 `json:"beforeId"`
+	ListID   *uint64 `json:"listId"`
+	Priority *int    `json:"priority"`
+}
+
+// errTaskNotFound 事务中要处理的任务不存在
+var errTaskNotFound = errors.New("task not found")
+
+// ReorderTasks 按给定顺序重新排列一组任务。
+// 这些任务原有的排序位置按新顺序重新分配，其他任务不受影响；位置有重复时在前后任务之间重新生成排序键
+func (ctrl *TaskController) ReorderTasks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	// taskIds 为这些任务的新顺序
+	type ReorderRequest struct {
+		TaskIDs []uint64 `json:"taskIds" binding:"required"`
+	}
+
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	ids := uniqueIDs(req.TaskIDs)
+	if len(ids) == 0 {
+		utils.BadRequest(c, "taskIds is required")
+		return
+	}
+	if len(ids) > maxBatchTaskCount {
+		utils.BadRequest(c, "Too many tasks, at most "+strconv.Itoa(maxBatchTaskCount))
+		return
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		var ranks []string
+		if err := tx.Model(&models.Task{}).Where("id IN ? AND user_id = ?", ids, userID).Pluck("rank", &ranks).Error; err != nil {
+			return err
+		}
+		if len(ranks) != len(ids) {
+			return errTaskNotFound
+		}
+
+		sort.Strings(ranks)
+		distinct := ranks[0] != ""
+		for i := 1; i < len(ranks) && distinct; i++ {
+			distinct = ranks[i] != ranks[i-1]
+		}
+		if !distinct {
+			lo := ""
+			if ranks[0] != "" {
+				var err error
+				if lo, err = services.AdjacentTaskRank(tx, userID, ranks[0], false, ids); err != nil {
+					return err
+				}
+			}
+			hi, err := services.AdjacentTaskRank(tx, userID, ranks[len(ranks)-1], true, ids)
+			if err != nil {
+				return err
+			}
+			if ranks, err = services.RanksBetween(lo, hi, len(ids)); err != nil {
+				return err
+			}
+		}
+		return services.UpdateTaskRanks(tx, ids, ranks)
+	})
+	if errors.Is(err, errTaskNotFound) {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to reorder tasks", zap.Error(err))
+		utils.InternalError(c, "Failed to reorder tasks")
+		return
+	}
+
+	ctrl.publishTaskChanged(userID, "reordered", gin.H{"taskIds": ids})
+
+	utils.Success(c, gin.H{"message": "Tasks reordered successfully"})
+}
+
+// MoveTask 把任务移到两个任务之间，只修改被移动的任务。
+// 前后任务的排序键相同时先整理该用户的排序键再重试，前后任务顺序颠倒时返回冲突
+func (ctrl *TaskController) MoveTask(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	taskID := c.Param("id")
+
+	var req MoveTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	var task models.Task
+	if err := ctrl.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+	if !ctrl.checkTaskIfMatch(c, task) {
+		return
+	}
+	if (req.AfterID != nil && *req.AfterID == task.ID) || (req.BeforeID != nil && *req.BeforeID == task.ID) {
+		utils.BadRequest(c, "Cannot move a task relative to itself")
+		return
+	}
+	if req.ListID != nil {
+		var list models.List
+		if err := ctrl.db.Where("id = ? AND user_id = ?", *req.ListID, userID).First(&list).Error; err != nil {
+			utils.BadRequest(c, "List not found")
+			return
+		}
+	}
+	if req.Priority != nil && (*req.Priority < 0 || *req.Priority > 3) {
+		utils.BadRequest(c, "priority must be between 0 and 3")
+		return
+	}
+
+	rebalanced := false
+	version := task.Version
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		rank, err := ctrl.moveRank(tx, userID, task.ID, req)
+		if errors.Is(err, services.ErrRankOrder) {
+			// 前后任务排序键相同，整理后重试
+			if err := services.RebalanceTaskRanks(tx, userID); err != nil {
+				return err
+			}
+			rebalanced = true
+			// 整理时所有任务的版本号都加了一
+			version++
+			rank, err = ctrl.moveRank(tx, userID, task.ID, req)
+		}
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"rank": rank}
+		if req.ListID != nil {
+			updates["list_id"] = *req.ListID
+		}
+		if req.Priority != nil {
+			updates["priority"] = *req.Priority
+		}
+		result := tx.Model(&task).Where("version = ?", version).Updates(updates)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return services.ErrVersionConflict
+		}
+		return result.Error
+	})
+	switch {
+	case errors.Is(err, errTaskNotFound):
+		utils.NotFound(c, "Neighbor task not found")
+		return
+	case errors.Is(err, services.ErrRankOrder):
+		utils.Conflict(c, "afterId must be ordered before beforeId")
+		return
+	case errors.Is(err, services.ErrVersionConflict):
+		ctrl.db.Preload("Tags").Preload("List").First(&task, task.ID)
+		utils.ErrorWithData(c, http.StatusConflict, "Resource was modified by another request", task)
+		return
+	case err != nil:
+		utils.Logger.Error("Failed to move task", zap.Error(err))
+		utils.InternalError(c, "Failed to move task")
+		return
+	}
+
+	ctrl.db.Preload("Tags").Preload("List").First(&task, task.ID)
+	if rebalanced {
+		ctrl.publishTaskChanged(userID, "reordered", gin.H{"rebalanced": true})
+	}
+	ctrl.publishTaskChanged(userID, "updated", gin.H{"task": task})
+
+	utils.SetETag(c, task.Version)
+	utils.Success(c, task)
+}
+
+// moveRank 读取前后任务并计算被移动任务的新排序键
+func (ctrl *TaskController) moveRank(tx *gorm.DB, userID, taskID uint64, req MoveTaskRequest) (string, error) {
+	after, err := loadNeighborTask(tx, userID, req.AfterID)
+	if err != nil {
+		return "", err
+	}
+	before, err := loadNeighborTask(tx, userID, req.BeforeID)
+	if err != nil {
+		return "", err
+	}
+	return services.TaskRankBetweenNeighbors(tx, userID, after, before, taskID)
+}
+
+// loadNeighborTask 读取移动位置参照的任务，id 为空时返回 nil
+func loadNeighborTask(tx *gorm.DB, userID uint64, id *uint64) (*models.Task, error) {
+	if id == nil {
+		return nil, nil
+	}
+	var task models.Task
+	if err := tx.Where("id = ? AND user_id = ?", *id, userID).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errTaskNotFound
+		}
+		return nil, err
+	}
+	return &task, nil
+}
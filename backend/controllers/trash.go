@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"errors"
+	"on-the-way/backend/middleware"
+	"on-the-way/backend/models"
+	"on-the-way/backend/services"
+	"on-the-way/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// deletedRootCondition 已删除任务中不是随父任务一起删除的任务
+const deletedRootCondition = "NOT EXISTS (SELECT 1 FROM tasks parent WHERE parent.id = tasks.parent_id AND parent.deleted_at = tasks.deleted_at)"
+
+type TrashController struct {
+	db              *gorm.DB
+	reminderService *services.ReminderService
+	events          *services.EventHub
+}
+
+func NewTrashController(db *gorm.DB, events *services.EventHub) *TrashController {
+	return &TrashController{
+		db:              db,
+		reminderService: services.NewReminderService(db),
+		events:          events,
+	}
+}
+
+// TrashTask 回收站中的任务
+type TrashTask struct {
+	models.Task
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// TrashList 回收站中的清单
+type TrashList struct {
+	models.List
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// TrashHabit 回收站中的习惯
+type TrashHabit struct {
+	models.Habit
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// TrashCountdown 回收站中的倒数日
+type TrashCountdown struct {
+	models.Countdown
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// GetTrash 获取回收站中的任务、清单、习惯和倒数日，按删除时间倒序，可用 type 只查询一种
+// 随父任务一起删除的子任务不单独列出，恢复父任务时一并恢复
+func (ctrl *TrashController) GetTrash(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	entityType := c.Query("type")
+	if entityType != "" && !services.IsTrashEntityType(entityType) {
+		utils.BadRequest(c, "Invalid trash type")
+		return
+	}
+	wants := func(t string) bool { return entityType == "" || entityType == t }
+
+	deleted := func(table string) *gorm.DB {
+		return ctrl.db.Unscoped().
+			Where(table+".user_id = ? AND "+table+".deleted_at IS NOT NULL", userID).
+			Order(table + ".deleted_at DESC")
+	}
+
+	tasks := []TrashTask{}
+	if wants(services.TrashEntityTask) {
+		var rows []models.Task
+		if err := deleted("tasks").
+			Where(deletedRootCondition).
+			Preload("Tags").
+			Find(&rows).Error; err != nil {
+			utils.InternalError(c, "Failed to fetch trash")
+			return
+		}
+		for _, task := range rows {
+			tasks = append(tasks, TrashTask{Task: task, DeletedAt: task.DeletedAt.Time})
+		}
+	}
+
+	lists := []TrashList{}
+	if wants(services.TrashEntityList) {
+		var rows []models.List
+		if err := deleted("lists").Find(&rows).Error; err != nil {
+			utils.InternalError(c, "Failed to fetch trash")
+			return
+		}
+		for _, list := range rows {
+			lists = append(lists, TrashList{List: list, DeletedAt: list.DeletedAt.Time})
+		}
+	}
+
+	habits := []TrashHabit{}
+	if wants(services.TrashEntityHabit) {
+		var rows []models.Habit
+		if err := deleted("habits").Find(&rows).Error; err != nil {
+			utils.InternalError(c, "Failed to fetch trash")
+			return
+		}
+		for _, habit := range rows {
+			habits = append(habits, TrashHabit{Habit: habit, DeletedAt: habit.DeletedAt.Time})
+		}
+	}
+
+	countdowns := []TrashCountdown{}
+	if wants(services.TrashEntityCountdown) {
+		var rows []models.Countdown
+		if err := deleted("countdowns").Find(&rows).Error; err != nil {
+			utils.InternalError(c, "Failed to fetch trash")
+			return
+		}
+		for _, countdown := range rows {
+			countdowns = append(countdowns, TrashCountdown{Countdown: countdown, DeletedAt: countdown.DeletedAt.Time})
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"tasks":      tasks,
+		"lists":      lists,
+		"habits":     habits,
+		"countdowns": countdowns,
+	})
+}
+
+// RestoreItem 从回收站恢复一条记录
+func (ctrl *TrashController) RestoreItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	entityType, id, ok := parseTrashParams(c)
+	if !ok {
+		return
+	}
+
+	switch entityType {
+	case services.TrashEntityTask:
+		ctrl.restoreTask(c, userID, id)
+	case services.TrashEntityList:
+		ctrl.restoreList(c, userID, id)
+	case services.TrashEntityHabit:
+		ctrl.restoreHabit(c, userID, id)
+	case services.TrashEntityCountdown:
+		ctrl.restoreCountdown(c, userID, id)
+	}
+}
+
+// restoreTask 恢复任务及与它一起删除的子任务和检查项，标签关联在删除时保留，恢复后自动生效。
+// 所属清单已删除时移到收集箱，父任务仍在回收站时作为顶层任务恢复
+func (ctrl *TrashController) restoreTask(c *gin.Context, userID uint64, taskID uint64) {
+	var task models.Task
+	if err := ctrl.db.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", taskID, userID).First(&task).Error; err != nil {
+		utils.NotFound(c, "Task not found in trash")
+		return
+	}
+
+	var inbox models.List
+	if err := ctrl.db.Where("user_id = ? AND is_default = ?", userID, true).First(&inbox).Error; err != nil {
+		utils.InternalError(c, "Default list not found")
+		return
+	}
+
+	var ids []uint64
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ids, err = restoreTaskTree(tx, task, inbox.ID)
+		return err
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to restore task", zap.Uint64("taskId", taskID), zap.Error(err))
+		utils.InternalError(c, "Failed to restore task")
+		return
+	}
+	ctrl.afterTasksRestored(userID, ids)
+
+	ctrl.db.Preload("Tags").First(&task, task.ID)
+	utils.Success(c, task)
+}
+
+// restoreList 恢复清单及随清单一起删除的视图配置和任务，所在文件夹已删除时移到顶层
+func (ctrl *TrashController) restoreList(c *gin.Context, userID uint64, listID uint64) {
+	var list models.List
+	if err := ctrl.db.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", listID, userID).First(&list).Error; err != nil {
+		utils.NotFound(c, "List not found in trash")
+		return
+	}
+
+	updates := map[string]interface{}{"deleted_at": nil}
+	if list.FolderID != nil {
+		var folderCount int64
+		ctrl.db.Model(&models.Folder{}).Where("id = ? AND user_id = ?", *list.FolderID, userID).Count(&folderCount)
+		if folderCount == 0 {
+			updates["folder_id"] = nil
+		}
+	}
+
+	// 更新后 list.DeletedAt 会被清空，先记下删除时间，只恢复在清单之后删除的内容
+	deletedAt := list.DeletedAt.Time
+	var taskIDs []uint64
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&list).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.ViewConfig{}).
+			Where("entity_type = ? AND entity_id = ? AND deleted_at >= ?", "list", list.ID, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		var tasks []models.Task
+		if err := tx.Unscoped().
+			Where("list_id = ? AND user_id = ? AND deleted_at >= ?", list.ID, userID, deletedAt).
+			Where(deletedRootCondition).
+			Find(&tasks).Error; err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			ids, err := restoreTaskTree(tx, task, list.ID)
+			if err != nil {
+				return err
+			}
+			taskIDs = append(taskIDs, ids...)
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to restore list", zap.Uint64("listId", listID), zap.Error(err))
+		utils.InternalError(c, "Failed to restore list")
+		return
+	}
+	ctrl.afterTasksRestored(userID, taskIDs)
+
+	ctrl.db.First(&list, list.ID)
+	utils.Success(c, list)
+}
+
+// restoreTaskTree 在事务中恢复已删除任务及与它一起删除的子任务和检查项，返回恢复的任务 ID；
+// 所属清单不存在的任务移到 fallbackListID，父任务不存在时作为顶层任务
+func restoreTaskTree(tx *gorm.DB, task models.Task, fallbackListID uint64) ([]uint64, error) {
+	ids := services.DeletedTaskTree(tx, task)
+
+	if err := tx.Unscoped().Model(&models.Task{}).Where("id IN ?", ids).Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Model(&models.ChecklistItem{}).
+		Where("task_id IN ? AND deleted_at >= ?", ids, task.DeletedAt.Time).
+		Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+
+	if task.ParentID != nil {
+		var parentCount int64
+		tx.Model(&models.Task{}).Where("id = ?", *task.ParentID).Count(&parentCount)
+		if parentCount == 0 {
+			if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Update("parent_id", nil).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	activeLists := tx.Model(&models.List{}).Select("id").Where("user_id = ?", task.UserID)
+	if err := tx.Model(&models.Task{}).Where("id IN ? AND list_id NOT IN (?)", ids, activeLists).Update("list_id", fallbackListID).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// afterTasksRestored 为恢复的任务重新生成提醒并通知其他设备
+func (ctrl *TrashController) afterTasksRestored(userID uint64, ids []uint64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	var restored []models.Task
+	ctrl.db.Where("id IN ?", ids).Find(&restored)
+	for i := range restored {
+		ctrl.reminderService.CreateReminderForTask(&restored[i])
+		ctrl.events.Publish(userID, services.EventTaskChanged, gin.H{"action": "restored", "taskId": restored[i].ID})
+	}
+}
+
+// restoreHabit 恢复习惯并重新生成提醒
+func (ctrl *TrashController) restoreHabit(c *gin.Context, userID uint64, habitID uint64) {
+	var habit models.Habit
+	if err := ctrl.db.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", habitID, userID).First(&habit).Error; err != nil {
+		utils.NotFound(c, "Habit not found in trash")
+		return
+	}
+
+	if err := ctrl.db.Unscoped().Model(&habit).Update("deleted_at", nil).Error; err != nil {
+		utils.InternalError(c, "Failed to restore habit")
+		return
+	}
+
+	ctrl.db.First(&habit, habit.ID)
+	ctrl.reminderService.CreateReminderForHabit(&habit)
+	utils.Success(c, habit)
+}
+
+// restoreCountdown 恢复倒数日
+func (ctrl *TrashController) restoreCountdown(c *gin.Context, userID uint64, countdownID uint64) {
+	var countdown models.Countdown
+	if err := ctrl.db.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", countdownID, userID).First(&countdown).Error; err != nil {
+		utils.NotFound(c, "Countdown not found in trash")
+		return
+	}
+
+	if err := ctrl.db.Unscoped().Model(&countdown).Update("deleted_at", nil).Error; err != nil {
+		utils.InternalError(c, "Failed to restore countdown")
+		return
+	}
+
+	ctrl.db.First(&countdown, countdown.ID)
+	utils.Success(c, countdown)
+}
+
+// PurgeItem 永久删除回收站中的一条记录，无法恢复
+func (ctrl *TrashController) PurgeItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	entityType, id, ok := parseTrashParams(c)
+	if !ok {
+		return
+	}
+
+	if err := services.PurgeTrashItem(ctrl.db, userID, entityType, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFound(c, "Item not found in trash")
+			return
+		}
+		utils.Logger.Error("Failed to purge trash item", zap.String("type", entityType), zap.Uint64("id", id), zap.Error(err))
+		utils.InternalError(c, "Failed to purge item")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Item permanently deleted"})
+}
+
+// EmptyTrash 清空回收站
+func (ctrl *TrashController) EmptyTrash(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	count, err := services.EmptyTrash(ctrl.db, userID)
+	if err != nil {
+		utils.Logger.Error("Failed to empty trash", zap.Error(err))
+		utils.InternalError(c, "Failed to empty trash")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Trash emptied", "count": count})
+}
+
+// parseTrashParams 解析路径中的实体类型和 ID，无效时直接返回 400
+func parseTrashParams(c *gin.Context) (string, uint64, bool) {
+	entityType := c.Param("type")
+	if !services.IsTrashEntityType(entityType) {
+		utils.BadRequest(c, "Invalid trash type")
+		return "", 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid ID")
+		return "", 0, false
+	}
+	return entityType, id, true
+}
@@ -1,20 +1,30 @@
 package controllers
 
 import (
+	"context"
 	"on-the-way/backend/middleware"
 	"on-the-way/backend/models"
+	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 type UserSettingsController struct {
-	db *gorm.DB
+	db            *gorm.DB
+	emailChannel  *services.EmailChannel
+	wechatChannel *services.WechatChannel
 }
 
-func NewUserSettingsController(db *gorm.DB) *UserSettingsController {
-	return &UserSettingsController{db: db}
+func NewUserSettingsController(db *gorm.DB, emailChannel *services.EmailChannel, wechatChannel *services.WechatChannel) *UserSettingsController {
+	return &UserSettingsController{
+		db:            db,
+		emailChannel:  emailChannel,
+		wechatChannel: wechatChannel,
+	}
 }
 
 type SettingsRequest struct {
@@ -24,6 +34,14 @@ type SettingsRequest struct {
 	EmailAddress     string  `json:"emailAddress"`
 	WechatEnabled    *bool   `json:"wechatEnabled"`
 	WechatWebhookURL string  `json:"wechatWebhookUrl"`
+	StrictDependencies *bool `json:"strictDependencies"`
+}
+
+// TestNotificationRequest 测试通知请求。邮件只发送到已保存的邮箱，避免被用来向任意地址发信；
+// 未填写 Webhook 地址时使用已保存的设置
+type TestNotificationRequest struct {
+	Channel          string `json:"channel" binding:"required,oneof=email wechat"`
+	WechatWebhookURL string `json:"wechatWebhookUrl"`
 }
 
 // GetSettings 获取用户设置
@@ -92,8 +110,15 @@ func (ctrl *UserSettingsController) UpdateSettings(c *gin.Context) {
 		settings.WechatEnabled = *req.WechatEnabled
 	}
 	if req.WechatWebhookURL != "" {
+		if err := services.ValidateWebhookURL(req.WechatWebhookURL); err != nil {
+			utils.BadRequest(c, "Invalid wechat webhook url")
+			return
+		}
 		settings.WechatWebhookURL = req.WechatWebhookURL
 	}
+	if req.StrictDependencies != nil {
+		settings.StrictDependencies = *req.StrictDependencies
+	}
 
 	if err := ctrl.db.Save(&settings).Error; err != nil {
 		utils.InternalError(c, "Failed to update settings")
@@ -103,3 +128,71 @@ func (ctrl *UserSettingsController) UpdateSettings(c *gin.Context) {
 	utils.Success(c, settings)
 }
 
+
+// TestNotification 发送一条测试通知，用于检查邮件或微信 Webhook 配置
+func (ctrl *UserSettingsController) TestNotification(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req TestNotificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	var settings models.UserSettings
+	if err := ctrl.db.Where("user_id = ?", userID).First(&settings).Error; err != nil && err != gorm.ErrRecordNotFound {
+		utils.InternalError(c, "Failed to get settings")
+		return
+	}
+
+	now := utils.Now()
+	msg := &services.ReminderMessage{
+		EntityType:    "task",
+		Title:         "测试通知",
+		Description:   "如果你收到了这条消息，说明通知配置正确。",
+		DueDate:       now.Format("2006-01-02"),
+		DueTime:       now.Format("15:04"),
+		PriorityLabel: services.PriorityLabel(0),
+		ReminderTime:  now.Format("2006-01-02 15:04"),
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	var err error
+	switch req.Channel {
+	case "email":
+		address := settings.EmailAddress
+		if address == "" {
+			utils.BadRequest(c, "Email address is not configured")
+			return
+		}
+		err = ctrl.emailChannel.SendMessage(ctx, address, msg)
+	case "wechat":
+		webhookURL := req.WechatWebhookURL
+		if webhookURL == "" {
+			webhookURL = settings.WechatWebhookURL
+		}
+		if webhookURL == "" {
+			utils.BadRequest(c, "Wechat webhook url is not configured")
+			return
+		}
+		if err := services.ValidateWebhookURL(webhookURL); err != nil {
+			utils.BadRequest(c, "Invalid wechat webhook url")
+			return
+		}
+		err = ctrl.wechatChannel.SendMessage(ctx, webhookURL, msg)
+	}
+
+	if err != nil {
+		utils.Logger.Warn("Test notification failed",
+			zap.Uint64("userID", userID),
+			zap.String("channel", req.Channel),
+			zap.Error(err))
+		// 失败原因只记录在日志中，不返回给客户端
+		utils.BadRequest(c, "Failed to send test notification")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Test notification sent successfully"})
+}
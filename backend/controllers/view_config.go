@@ -68,6 +68,7 @@ func (ctrl *ViewConfigController) GetViewConfig(c *gin.Context) {
 		return
 	}
 
+	utils.SetETag(c, config.Version)
 	utils.Success(c, config)
 }
 
@@ -117,6 +118,9 @@ func (ctrl *ViewConfigController) UpdateViewConfig(c *gin.Context) {
 		return
 	} else {
 		// 更新现有记录
+		if !checkIfMatch(c, config.Version, config) {
+			return
+		}
 		config.GroupBy = req.GroupBy
 		config.SortBy = req.SortBy
 		config.SortOrder = req.SortOrder
@@ -129,12 +133,12 @@ func (ctrl *ViewConfigController) UpdateViewConfig(c *gin.Context) {
 		if req.ShowDetail != nil {
 			config.ShowDetail = *req.ShowDetail
 		}
-		if err := ctrl.db.Save(&config).Error; err != nil {
-			utils.InternalError(c, "Failed to update view config")
+		if !saveVersioned(c, ctrl.db, &config, config.ID, config.Version, "Failed to update view config") {
 			return
 		}
 	}
 
+	utils.SetETag(c, config.Version)
 	utils.Success(c, config)
 }
 
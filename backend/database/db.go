@@ -27,12 +27,20 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// 更新带版本号的模型时自动递增版本
+	if err := registerVersionCallbacks(db); err != nil {
+		return nil, err
+	}
+
 	// 自动迁移模型
 	err = db.AutoMigrate(
 		&models.User{},
 		&models.Folder{},
 		&models.List{},
 		&models.Task{},
+		&models.TaskSeries{},
+		&models.ChecklistItem{},
+		&models.TaskDependency{},
 		&models.Tag{},
 		&models.TaskTag{},
 		&models.Pomodoro{},
@@ -45,6 +53,8 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 		&models.Filter{},
 		&models.ViewConfig{},
 		&models.Holiday{},
+		&models.PinyinIndex{},
+		&models.SyncMapping{},
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+)
+
+// versionSetKey 标记本次更新的 SET 子句由版本回调生成
+const versionSetKey = "version:set"
+
+// registerVersionCallbacks 为带 Version 字段的模型注册更新回调：每次 UPDATE 都把版本号加一，
+// 用于乐观并发控制和 ETag。原生 SQL 更新不经过回调，需要自行维护版本号
+func registerVersionCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Update().Before("gorm:update").Register("version:increment", incrementVersion); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("version:sync", syncVersion)
+}
+
+// incrementVersion 按 GORM 的规则生成 SET 子句，并把其中的版本号替换为 version + 1
+func incrementVersion(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.LookUpField("Version") == nil {
+		return
+	}
+	if _, ok := stmt.Clauses["SET"]; ok {
+		return
+	}
+
+	set := callbacks.ConvertToAssignments(stmt)
+	if len(set) == 0 {
+		return
+	}
+	assignments := make(clause.Set, 0, len(set)+1)
+	for _, assignment := range set {
+		if assignment.Column.Name != "version" {
+			assignments = append(assignments, assignment)
+		}
+	}
+	assignments = append(assignments, clause.Assignment{
+		Column: clause.Column{Name: "version"},
+		Value:  gorm.Expr("version + 1"),
+	})
+	stmt.AddClause(assignments)
+	stmt.Settings.Store(versionSetKey, true)
+}
+
+// syncVersion 更新成功后同步内存中模型的版本号，只处理已加载（版本号不为 0）的单个模型
+func syncVersion(db *gorm.DB) {
+	stmt := db.Statement
+	if _, ok := stmt.Settings.LoadAndDelete(versionSetKey); !ok {
+		return
+	}
+	delete(stmt.Clauses, "SET")
+	if db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+
+	field := stmt.Schema.LookUpField("Version")
+	if stmt.ReflectValue.Kind() != reflect.Struct || !stmt.ReflectValue.CanAddr() {
+		return
+	}
+	if value, isZero := field.ValueOf(stmt.Context, stmt.ReflectValue); !isZero {
+		if version, ok := value.(uint64); ok {
+			db.AddError(field.Set(stmt.Context, stmt.ReflectValue, version+1))
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package lunar 农历（中国阴阳历）与公历互相转换，支持 1900-2100 年，包含闰月
+package lunar
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 支持的农历年份范围
+const (
+	MinYear = 1900
+	MaxYear = 2100
+)
+
+// ErrOutOfRange 日期超出支持范围
+var ErrOutOfRange = errors.New("date out of supported lunar range (1900-2100)")
+
+// ErrInvalidDate 农历日期不存在（如该年没有指定的闰月，或日期超过当月天数）
+var ErrInvalidDate = errors.New("invalid lunar date")
+
+// lunarInfo 1900-2100 年的农历数据，每年用一个整数编码：
+//   - 低 4 位：闰月月份，0 表示无闰月
+//   - 第 4-15 位：1-12 月的大小月，从高位到低位依次为 1 月到 12 月，1 为大月（30 天），0 为小月（29 天）
+//   - 第 16 位：闰月的大小，1 为 30 天
+var lunarInfo = [...]int{
+	0x04bd8, 0x04ae0, 0x0a570, 0x054d5, 0x0d260, 0x0d950, 0x16554, 0x056a0, 0x09ad0, 0x055d2, // 1900-1909
+	0x04ae0, 0x0a5b6, 0x0a4d0, 0x0d250, 0x1d255, 0x0b540, 0x0d6a0, 0x0ada2, 0x095b0, 0x14977, // 1910-1919
+	0x04970, 0x0a4b0, 0x0b4b5, 0x06a50, 0x06d40, 0x1ab54, 0x02b60, 0x09570, 0x052f2, 0x04970, // 1920-1929
+	0x06566, 0x0d4a0, 0x0ea50, 0x16a95, 0x05ad0, 0x02b60, 0x186e3, 0x092e0, 0x1c8d7, 0x0c950, // 1930-1939
+	0x0d4a0, 0x1d8a6, 0x0b550, 0x056a0, 0x1a5b4, 0x025d0, 0x092d0, 0x0d2b2, 0x0a950, 0x0b557, // 1940-1949
+	0x06ca0, 0x0b550, 0x15355, 0x04da0, 0x0a5b0, 0x14573, 0x052b0, 0x0a9a8, 0x0e950, 0x06aa0, // 1950-1959
+	0x0aea6, 0x0ab50, 0x04b60, 0x0aae4, 0x0a570, 0x05260, 0x0f263, 0x0d950, 0x05b57, 0x056a0, // 1960-1969
+	0x096d0, 0x04dd5, 0x04ad0, 0x0a4d0, 0x0d4d4, 0x0d250, 0x0d558, 0x0b540, 0x0b6a0, 0x195a6, // 1970-1979
+	0x095b0, 0x049b0, 0x0a974, 0x0a4b0, 0x0b27a, 0x06a50, 0x06d40, 0x0af46, 0x0ab60, 0x09570, // 1980-1989
+	0x04af5, 0x04970, 0x064b0, 0x074a3, 0x0ea50, 0x06b58, 0x05ac0, 0x0ab60, 0x096d5, 0x092e0, // 1990-1999
+	0x0c960, 0x0d954, 0x0d4a0, 0x0da50, 0x07552, 0x056a0, 0x0abb7, 0x025d0, 0x092d0, 0x0cab5, // 2000-2009
+	0x0a950, 0x0b4a0, 0x0baa4, 0x0ad50, 0x055d9, 0x04ba0, 0x0a5b0, 0x15176, 0x052b0, 0x0a930, // 2010-2019
+	0x07954, 0x06aa0, 0x0ad50, 0x05b52, 0x04b60, 0x0a6e6, 0x0a4e0, 0x0d260, 0x0ea65, 0x0d530, // 2020-2029
+	0x05aa0, 0x076a3, 0x096d0, 0x04afb, 0x04ad0, 0x0a4d0, 0x1d0b6, 0x0d250, 0x0d520, 0x0dd45, // 2030-2039
+	0x0b5a0, 0x056d0, 0x055b2, 0x049b0, 0x0a577, 0x0a4b0, 0x0aa50, 0x1b255, 0x06d20, 0x0ada0, // 2040-2049
+	0x14b63, 0x09370, 0x049f8, 0x04970, 0x064b0, 0x168a6, 0x0ea50, 0x06b20, 0x1a6c4, 0x0aae0, // 2050-2059
+	0x092e0, 0x0d2e3, 0x0c960, 0x0d557, 0x0d4a0, 0x0da50, 0x05d55, 0x056a0, 0x0a6d0, 0x055d4, // 2060-2069
+	0x052d0, 0x0a9b8, 0x0a950, 0x0b4a0, 0x0b6a6, 0x0ad50, 0x055a0, 0x0aba4, 0x0a5b0, 0x052b0, // 2070-2079
+	0x0b273, 0x06930, 0x07337, 0x06aa0, 0x0ad50, 0x14b55, 0x04b60, 0x0a570, 0x054e4, 0x0d160, // 2080-2089
+	0x0e968, 0x0d520, 0x0daa0, 0x16aa6, 0x056d0, 0x04ae0, 0x0a9d4, 0x0a2d0, 0x0d150, 0x0f252, // 2090-2099
+	0x0d520, // 2100
+}
+
+// baseDate 农历 1900 年正月初一对应的公历日期
+var baseDate = time.Date(1900, 1, 31, 0, 0, 0, 0, time.UTC)
+
+// Date 农历日期
+type Date struct {
+	Year   int  `json:"year"`
+	Month  int  `json:"month"`
+	Day    int  `json:"day"`
+	IsLeap bool `json:"isLeap"` // 是否为闰月
+}
+
+// LeapMonth 返回农历某年的闰月月份，0 表示没有闰月
+func LeapMonth(year int) int {
+	if year < MinYear || year > MaxYear {
+		return 0
+	}
+	return lunarInfo[year-MinYear] & 0xf
+}
+
+// MonthDays 返回农历某月的天数（29 或 30），月份不存在时返回 0
+func MonthDays(year, month int, leap bool) int {
+	if year < MinYear || year > MaxYear || month < 1 || month > 12 {
+		return 0
+	}
+	info := lunarInfo[year-MinYear]
+	if leap {
+		if LeapMonth(year) != month {
+			return 0
+		}
+		if info&0x10000 != 0 {
+			return 30
+		}
+		return 29
+	}
+	if info&(0x10000>>uint(month)) != 0 {
+		return 30
+	}
+	return 29
+}
+
+// YearDays 返回农历某年的总天数
+func YearDays(year int) int {
+	days := 0
+	for month := 1; month <= 12; month++ {
+		days += MonthDays(year, month, false)
+	}
+	if leap := LeapMonth(year); leap > 0 {
+		days += MonthDays(year, leap, true)
+	}
+	return days
+}
+
+// FromSolar 公历转农历，只使用 t 的年月日
+func FromSolar(t time.Time) (Date, error) {
+	solar := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := int(solar.Sub(baseDate).Hours() / 24)
+	if offset < 0 {
+		return Date{}, ErrOutOfRange
+	}
+
+	year := MinYear
+	for ; year <= MaxYear; year++ {
+		days := YearDays(year)
+		if offset < days {
+			break
+		}
+		offset -= days
+	}
+	if year > MaxYear {
+		return Date{}, ErrOutOfRange
+	}
+
+	month, leap := 1, false
+	for {
+		days := MonthDays(year, month, leap)
+		if offset < days {
+			break
+		}
+		offset -= days
+		month, leap = nextMonth(year, month, leap)
+	}
+
+	return Date{Year: year, Month: month, Day: offset + 1, IsLeap: leap}, nil
+}
+
+// ToSolar 农历转公历，返回 UTC 零点
+func ToSolar(d Date) (time.Time, error) {
+	if d.Year < MinYear || d.Year > MaxYear {
+		return time.Time{}, ErrOutOfRange
+	}
+	days := MonthDays(d.Year, d.Month, d.IsLeap)
+	if days == 0 || d.Day < 1 || d.Day > days {
+		return time.Time{}, ErrInvalidDate
+	}
+
+	offset := 0
+	for year := MinYear; year < d.Year; year++ {
+		offset += YearDays(year)
+	}
+	for month, leap := 1, false; month != d.Month || leap != d.IsLeap; month, leap = nextMonth(d.Year, month, leap) {
+		offset += MonthDays(d.Year, month, leap)
+	}
+	offset += d.Day - 1
+
+	return baseDate.AddDate(0, 0, offset), nil
+}
+
+// NextMonth 返回下一个农历月（闰月排在同名月份之后）
+func NextMonth(year, month int, leap bool) (int, int, bool) {
+	month, leap = nextMonth(year, month, leap)
+	if month > 12 {
+		return year + 1, 1, false
+	}
+	return year, month, leap
+}
+
+// nextMonth 同一年内的下一个月，超过 12 月时返回 13
+func nextMonth(year, month int, leap bool) (int, bool) {
+	if !leap && LeapMonth(year) == month {
+		return month, true
+	}
+	return month + 1, false
+}
+
+// AddMonths 在农历月份上前进 n 个月（闰月计为一个月）
+func AddMonths(year, month int, leap bool, n int) (int, int, bool) {
+	for i := 0; i < n; i++ {
+		year, month, leap = NextMonth(year, month, leap)
+	}
+	return year, month, leap
+}
+
+var (
+	heavenlyStems   = []string{"甲", "乙", "丙", "丁", "戊", "己", "庚", "辛", "壬", "癸"}
+	earthlyBranches = []string{"子", "丑", "寅", "卯", "辰", "巳", "午", "未", "申", "酉", "戌", "亥"}
+	zodiacAnimals   = []string{"鼠", "牛", "虎", "兔", "龙", "蛇", "马", "羊", "猴", "鸡", "狗", "猪"}
+	monthNames      = []string{"正", "二", "三", "四", "五", "六", "七", "八", "九", "十", "冬", "腊"}
+	dayTens         = []string{"初", "十", "廿", "三"}
+	dayUnits        = []string{"十", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+)
+
+// GanZhiYear 农历年的干支纪年，如 2025 年为 "乙巳"
+func GanZhiYear(year int) string {
+	index := (year - 4) % 60
+	if index < 0 {
+		index += 60
+	}
+	return heavenlyStems[index%10] + earthlyBranches[index%12]
+}
+
+// Zodiac 农历年对应的生肖
+func Zodiac(year int) string {
+	index := (year - 4) % 12
+	if index < 0 {
+		index += 12
+	}
+	return zodiacAnimals[index]
+}
+
+// MonthName 月份中文名，如 "正月"、"闰六月"、"腊月"
+func (d Date) MonthName() string {
+	if d.Month < 1 || d.Month > 12 {
+		return ""
+	}
+	name := monthNames[d.Month-1] + "月"
+	if d.IsLeap {
+		name = "闰" + name
+	}
+	return name
+}
+
+// DayName 日期中文名，如 "初一"、"十五"、"廿三"、"三十"
+func (d Date) DayName() string {
+	switch d.Day {
+	case 10:
+		return "初十"
+	case 20:
+		return "二十"
+	case 30:
+		return "三十"
+	}
+	if d.Day < 1 || d.Day > 30 {
+		return ""
+	}
+	return dayTens[d.Day/10] + dayUnits[d.Day%10]
+}
+
+// String 中文表示，如 "乙巳年闰六月初一"
+func (d Date) String() string {
+	return fmt.Sprintf("%s年%s%s", GanZhiYear(d.Year), d.MonthName(), d.DayName())
+}
+
+// festivals 农历传统节日（不含闰月）
+var festivals = map[[2]int]string{
+	{1, 1}:  "春节",
+	{1, 15}: "元宵节",
+	{2, 2}:  "龙抬头",
+	{5, 5}:  "端午节",
+	{7, 7}:  "七夕",
+	{7, 15}: "中元节",
+	{8, 15}: "中秋节",
+	{9, 9}:  "重阳节",
+	{12, 8}: "腊八节",
+}
+
+// Festival 返回农历传统节日名称，没有时返回空字符串
+func (d Date) Festival() string {
+	if d.IsLeap {
+		return ""
+	}
+	// 除夕为腊月最后一天
+	if d.Month == 12 && d.Day == MonthDays(d.Year, 12, false) {
+		return "除夕"
+	}
+	return festivals[[2]int{d.Month, d.Day}]
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"on-the-way/backend/config"
 	"on-the-way/backend/database"
 	"on-the-way/backend/middleware"
 	"on-the-way/backend/routes"
+	"on-the-way/backend/services"
 	"on-the-way/backend/utils"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -29,6 +38,27 @@ func main() {
 		utils.LogFatal("Failed to initialize database", zap.Error(err))
 	}
 
+	// 为旧数据中的重复任务补建系列
+	if err := services.BackfillTaskSeries(db); err != nil {
+		utils.LogError("Failed to backfill task series", zap.Error(err))
+	}
+	// 为旧版按月、按年重复的任务补全规则起始日期
+	if err := services.BackfillRecurrenceStart(db); err != nil {
+		utils.LogError("Failed to backfill recurrence start dates", zap.Error(err))
+	}
+	// 为旧任务按原有顺序分配排序键
+	if err := services.BackfillTaskRanks(db); err != nil {
+		utils.LogError("Failed to backfill task ranks", zap.Error(err))
+	}
+	// 全文搜索索引
+	if err := services.InitSearchIndex(db); err != nil {
+		utils.LogError("Failed to initialize search index", zap.Error(err))
+	}
+	// 旧版扁平过滤器配置迁移为规则树
+	if err := services.MigrateStoredFilterConfigs(db); err != nil {
+		utils.LogError("Failed to migrate filter configs", zap.Error(err))
+	}
+
 	// 创建Gin实例（不使用默认中间件）
 	r := gin.New()
 
@@ -42,17 +72,105 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Last-Event-ID", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: true,
 	}))
 
+	// 提醒投递渠道（调度器与测试通知接口共用）
+	emailChannel := services.NewEmailChannel(db, services.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+		TLSMode:  cfg.SMTPTLSMode,
+	})
+	wechatChannel := services.NewWechatChannel(db)
+
+	// 实时事件中心
+	eventHub := services.NewEventHub(services.EventHubOptions{})
+
 	// 注册路由
-	routes.RegisterRoutes(r, db)
+	routes.RegisterRoutes(r, db, routes.Dependencies{
+		EmailChannel:  emailChannel,
+		WechatChannel: wechatChannel,
+		EventHub:      eventHub,
+
+		RequireIfMatch:       cfg.RequireIfMatch,
+		SyncTokenTTL:         time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour,
+		AccountImportMaxSize: int64(cfg.AccountImportMaxSizeMB) << 20,
+	})
+
+	// 监听退出信号，用于优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 启动后台提醒调度器
+	dispatcher := services.NewReminderDispatcher(db, services.ReminderDispatcherOptions{
+		Interval:   time.Duration(cfg.ReminderScanInterval) * time.Second,
+		MaxRetries: cfg.ReminderMaxRetries,
+	}, services.SystemClock)
+
+	// 注册投递渠道
+	dispatcher.RegisterChannel(emailChannel)
+	dispatcher.RegisterChannel(wechatChannel)
+	dispatcher.SetEventHub(eventHub)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dispatcher.Run(ctx)
+	}()
+
+	// 启动回收站清理任务，永久删除超过保留期的记录
+	trashCleaner := services.NewTrashCleaner(db, services.TrashCleanerOptions{
+		Interval:  time.Duration(cfg.TrashPurgeInterval) * time.Second,
+		Retention: time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour,
+	}, services.SystemClock)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		trashCleaner.Run(ctx)
+	}()
+
+	// 启动排序键整理任务，为排序键过长或重复的用户重新分配排序键
+	rankRebalancer := services.NewTaskRankRebalancer(db, services.TaskRankRebalancerOptions{
+		Interval: time.Duration(cfg.TaskRankRebalanceInterval) * time.Second,
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rankRebalancer.Run(ctx)
+	}()
 
 	// 启动服务器
-	utils.LogInfo("Server is running", zap.String("port", cfg.Port))
-	if err := r.Run(":" + cfg.Port); err != nil {
-		utils.LogFatal("Failed to start server", zap.Error(err))
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
 	}
+	// 关闭时断开事件流长连接，否则 Shutdown 会一直等待
+	srv.RegisterOnShutdown(eventHub.Close)
+	go func() {
+		utils.LogInfo("Server is running", zap.String("port", cfg.Port))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.LogFatal("Failed to start server", zap.Error(err))
+		}
+	}()
+
+	<-ctx.Done()
+	utils.LogInfo("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		utils.LogError("Server forced to shutdown", zap.Error(err))
+	}
+
+	// 等待后台任务退出
+	wg.Wait()
+	utils.LogInfo("Server exited")
 }
@@ -25,20 +25,41 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 解析token
-		claims, err := utils.ParseToken(tokenString)
-		if err != nil {
-			utils.Unauthorized(c, "Invalid token")
+		authenticate(c, tokenString)
+	}
+}
+
+// StreamAuthMiddleware 事件流认证中间件
+// 浏览器的 EventSource 无法设置请求头，因此额外支持通过 token 查询参数传递JWT
+func StreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			tokenString = c.Query("token")
+		}
+		if tokenString == "" {
+			utils.Unauthorized(c, "Missing authorization token")
 			c.Abort()
 			return
 		}
 
-		// 将用户ID存入上下文
-		c.Set("userID", claims.UserID)
-		c.Next()
+		authenticate(c, tokenString)
 	}
 }
 
+// authenticate 解析token并将用户ID存入上下文
+func authenticate(c *gin.Context, tokenString string) {
+	claims, err := utils.ParseToken(tokenString)
+	if err != nil {
+		utils.Unauthorized(c, "Invalid token")
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", claims.UserID)
+	c.Next()
+}
+
 // GetUserID 从上下文中获取用户ID
 func GetUserID(c *gin.Context) uint64 {
 	userID, exists := c.Get("userID")
@@ -47,4 +68,3 @@ func GetUserID(c *gin.Context) uint64 {
 	}
 	return userID.(uint64)
 }
-
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"on-the-way/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireIfMatch 要求修改请求携带 If-Match 头，缺少时返回 428；
+// required 为 false 时不检查，兼容还未发送 If-Match 的旧客户端
+func RequireIfMatch(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if required && c.GetHeader("If-Match") == "" {
+			utils.PreconditionRequired(c, "If-Match header is required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
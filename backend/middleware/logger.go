@@ -3,21 +3,39 @@ package middleware
 import (
 	"bytes"
 	"io"
+	"net/url"
 	"on-the-way/backend/utils"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// maxBodySize 日志中最多记录的请求体和响应体长度（10KB），避免日志过大
+const maxBodySize = 10 * 1024
+
+// eventStreamPath 事件流的路由，长连接不缓存响应内容
+const eventStreamPath = "/api/events"
+
 // responseWriter 包装 gin.ResponseWriter 以捕获响应内容
 type responseWriter struct {
 	gin.ResponseWriter
 	body *bytes.Buffer
 }
 
+// Write 只缓存日志需要的部分响应内容，下载大文件（如账户备份）时不占用额外内存；
+// 响应为事件流时不缓存
 func (w *responseWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		return w.ResponseWriter.Write(b)
+	}
+	if remaining := maxBodySize + 1 - w.body.Len(); remaining > 0 {
+		if remaining > len(b) {
+			remaining = len(b)
+		}
+		w.body.Write(b[:remaining])
+	}
 	return w.ResponseWriter.Write(b)
 }
 
@@ -41,18 +59,20 @@ func LoggerMiddleware() gin.HandlerFunc {
 			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		}
 
-		// 限制请求体大小避免日志过大（最多记录10KB）
-		const maxBodySize = 10 * 1024 // 10KB
+		// 限制请求体大小避免日志过大
 		if len(requestBody) > maxBodySize {
 			requestBody = append(requestBody[:maxBodySize], []byte("...(truncated)")...)
 		}
 
 		// 包装 ResponseWriter 以捕获响应
+		// 事件流按路由识别，不依赖客户端的 Accept 请求头
 		writer := &responseWriter{
 			ResponseWriter: c.Writer,
 			body:           bytes.NewBufferString(""),
 		}
-		c.Writer = writer
+		if c.FullPath() != eventStreamPath {
+			c.Writer = writer
+		}
 
 		// 处理请求
 		c.Next()
@@ -71,7 +91,7 @@ func LoggerMiddleware() gin.HandlerFunc {
 		fields := []zap.Field{
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
-			zap.String("query", c.Request.URL.RawQuery),
+			zap.String("query", redactQuery(c.Request.URL.RawQuery)),
 			zap.Int("status", c.Writer.Status()),
 			zap.String("client_ip", c.ClientIP()),
 			zap.Duration("duration", duration),
@@ -100,3 +120,15 @@ func LoggerMiddleware() gin.HandlerFunc {
 	}
 }
 
+// redactQuery 隐藏查询参数中的token，避免JWT写入日志
+func redactQuery(rawQuery string) string {
+	if !strings.Contains(rawQuery, "token=") {
+		return rawQuery
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil || values.Get("token") == "" {
+		return rawQuery
+	}
+	values.Set("token", "***")
+	return values.Encode()
+}
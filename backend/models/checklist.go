@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChecklistItem 任务内的检查项，比子任务更轻量，只有标题和勾选状态
+type ChecklistItem struct {
+	ID          uint64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint64         `json:"userId" gorm:"not null;index:idx_user_checklist"`
+	TaskID      uint64         `json:"taskId" gorm:"not null;index:idx_task_checklist"`
+	Title       string         `json:"title" gorm:"type:varchar(500);not null"`
+	IsCompleted bool           `json:"isCompleted" gorm:"default:false"`
+	CompletedAt string         `json:"completedAt" gorm:"type:varchar(14)"` // 完成时间，格式：20251105 18:20
+	SortOrder   int            `json:"sortOrder" gorm:"default:0"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-"`
+}
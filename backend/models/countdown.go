@@ -13,6 +13,7 @@ type Countdown struct {
 	TargetDate time.Time      `json:"targetDate" gorm:"not null;index:idx_user_target_date"`
 	ImageURL   string         `json:"imageUrl" gorm:"type:varchar(500)"`
 	Type       string         `json:"type" gorm:"type:varchar(20);default:'countdown';index:idx_countdown_type"` // countdown, anniversary
+	Version    uint64         `json:"version" gorm:"not null;default:1"`                                         // 版本号，每次修改加一，用于同步时检测冲突
 	CreatedAt  time.Time      `json:"createdAt"`
 	UpdatedAt  time.Time      `json:"updatedAt"`
 	DeletedAt  gorm.DeletedAt `json:"-"`
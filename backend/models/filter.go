@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -14,14 +15,23 @@ type Filter struct {
 	Icon         string         `json:"icon" gorm:"type:varchar(50)"`
 	IsPinned     bool           `json:"isPinned" gorm:"default:false"`
 	SortOrder    int            `json:"sortOrder" gorm:"default:0;index:idx_filter_sort"`
-	FilterConfig string         `json:"filterConfig" gorm:"type:text"` // JSON格式存储
+	FilterConfig string         `json:"filterConfig" gorm:"type:text"`     // JSON格式存储
+	Version      uint64         `json:"version" gorm:"not null;default:1"` // 版本号，每次修改加一，用作 ETag
 	CreatedAt    time.Time      `json:"createdAt"`
 	UpdatedAt    time.Time      `json:"updatedAt"`
 	DeletedAt    gorm.DeletedAt `json:"-"`
 }
 
+// FilterConfigVersion 当前过滤器配置版本：2 为规则树，旧版（无 version）为扁平条件
+const FilterConfigVersion = 2
+
 // FilterConfig 过滤器配置结构（用于JSON序列化）
+// 规则树 Rules 为条件的唯一依据；扁平字段只在规则树可以用它们表达时一并保存，供旧客户端使用
 type FilterConfig struct {
+	Version int         `json:"version,omitempty"`
+	Rules   *FilterRule `json:"rules,omitempty"`
+	Query   string      `json:"query,omitempty"` // 搜索查询语句，设置后规则树由它解析生成
+
 	ListIDs        []uint64   `json:"listIds,omitempty"`
 	TagIDs         []uint64   `json:"tagIds,omitempty"`
 	DateType       string     `json:"dateType,omitempty"` // today, tomorrow, week, overdue, noDate, custom, all
@@ -31,6 +41,20 @@ type FilterConfig struct {
 	TaskType       string     `json:"taskType,omitempty"` // all, task, note
 }
 
+// FilterRule 规则树节点：分组节点设置 Match 和 Rules，条件节点设置 Field、Operator 和 Value
+type FilterRule struct {
+	Match    string          `json:"match,omitempty"` // all（且）, any（或）, none（都不满足）
+	Rules    []FilterRule    `json:"rules,omitempty"`
+	Field    string          `json:"field,omitempty"`    // list, tag, priority, status, dueDate, createdDate, completedDate, keyword, hasReminder, isRecurring, taskType
+	Operator string          `json:"operator,omitempty"` // in, notIn, eq, neq, gte, lte, before, after, between, preset, isEmpty, isNotEmpty, contains, notContains
+	Value    json.RawMessage `json:"value,omitempty"`
+}
+
+// IsGroup 是否为分组节点
+func (r *FilterRule) IsGroup() bool {
+	return r.Match != ""
+}
+
 // DateRange 日期范围
 type DateRange struct {
 	Start string `json:"start"` // 格式：20251105
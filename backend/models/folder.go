@@ -14,6 +14,7 @@ type Folder struct {
 	Icon       string         `json:"icon" gorm:"type:varchar(50)"`
 	SortOrder  int            `json:"sortOrder" gorm:"default:0;index:idx_folder_sort"`
 	IsExpanded bool           `json:"isExpanded" gorm:"default:true"`
+	Version    uint64         `json:"version" gorm:"not null;default:1"` // 版本号，每次修改加一，用作 ETag
 	CreatedAt  time.Time      `json:"createdAt"`
 	UpdatedAt  time.Time      `json:"updatedAt"`
 	DeletedAt  gorm.DeletedAt `json:"-"`
@@ -21,14 +21,17 @@ type Habit struct {
 	Group             string         `json:"group" gorm:"type:varchar(50);index:idx_user_group"`        // morning, afternoon, evening, other, custom
 	ReminderTimes     string         `json:"reminderTimes" gorm:"type:varchar(200)"`                    // JSON数组: "[\"19:30\",\"20:00\"]"
 	AutoJournal       bool           `json:"autoJournal" gorm:"default:false"`                          // 自动弹出打卡日志
+	Version           uint64         `json:"version" gorm:"not null;default:1"`                         // 版本号，每次修改加一，用作 ETag
 	CreatedAt         time.Time      `json:"createdAt"`
 	UpdatedAt         time.Time      `json:"updatedAt"`
 	DeletedAt         gorm.DeletedAt `json:"-"`
 }
 
 type HabitRecord struct {
-	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
-	HabitID   uint64    `json:"habitId" gorm:"not null;index:idx_habit_records;index:idx_habit_date"`
-	CheckDate time.Time `json:"checkDate" gorm:"type:date;not null;index:idx_habit_date"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        uint64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	HabitID   uint64         `json:"habitId" gorm:"not null;index:idx_habit_records;index:idx_habit_date"`
+	CheckDate time.Time      `json:"checkDate" gorm:"type:date;not null;index:idx_habit_date"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-"` // 取消打卡时软删除，以便同步给其他设备
 }
@@ -18,6 +18,7 @@ type List struct {
 	SortOrder int            `json:"sortOrder" gorm:"default:0;index:idx_list_sort"`
 	IsDefault bool           `json:"isDefault" gorm:"default:false;index:idx_user_default"`
 	IsSystem  bool           `json:"isSystem" gorm:"default:false"`
+	Version   uint64         `json:"version" gorm:"not null;default:1"` // 版本号，每次修改加一，用作 ETag
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"-"`
@@ -0,0 +1,11 @@
+package models
+
+// PinyinIndex 任务标题、清单名称和标签名称的拼音索引，用于拼音和首字母搜索
+type PinyinIndex struct {
+	ID         uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     uint64 `json:"userId" gorm:"not null;index:idx_user_pinyin"`
+	EntityType string `json:"entityType" gorm:"type:varchar(20);not null;uniqueIndex:idx_pinyin_entity;index:idx_user_pinyin"` // task, list, tag
+	EntityID   uint64 `json:"entityId" gorm:"not null;uniqueIndex:idx_pinyin_entity"`
+	Full       string `json:"full" gorm:"type:text"`     // 全拼，如 "季度规划" 为 jiduguihua
+	Initials   string `json:"initials" gorm:"type:text"` // 首字母，如 jdgh
+}
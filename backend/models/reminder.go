@@ -13,9 +13,11 @@ type Reminder struct {
 	EntityID     uint64         `json:"entityId" gorm:"not null;index:idx_entity"`
 	ReminderTime string         `json:"reminderTime" gorm:"type:varchar(14);not null;index:idx_reminder_time;index:idx_status_time"` // 格式：20251105 18:20
 	ReminderType string         `json:"reminderType" gorm:"type:varchar(20);not null"`                                               // popup, email, wechat
-	Status       string         `json:"status" gorm:"type:varchar(20);default:'pending';index:idx_status_time"`                      // pending, sent, failed
+	Status       string         `json:"status" gorm:"type:varchar(20);default:'pending';index:idx_status_time"`                      // pending, sent, failed, dead
 	RetryCount   int            `json:"retryCount" gorm:"default:0"`
-	Metadata     string         `json:"metadata" gorm:"type:text"` // JSON格式: {"title":"", "description":"", "icon":""}
+	NextRetryAt  string         `json:"nextRetryAt" gorm:"type:varchar(14);index:idx_next_retry"` // 下次重试时间，格式：20251105 18:20
+	LastError    string         `json:"lastError" gorm:"type:text"`                               // 最近一次投递失败原因
+	Metadata     string         `json:"metadata" gorm:"type:text"`                                // JSON格式: {"title":"", "description":"", "icon":""}
 	CreatedAt    time.Time      `json:"createdAt"`
 	UpdatedAt    time.Time      `json:"updatedAt"`
 	DeletedAt    gorm.DeletedAt `json:"-"`
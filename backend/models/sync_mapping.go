@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SyncMapping 离线客户端生成的 ID 与服务器 ID 的对应关系。
+// 客户端重复提交同一条新建记录时直接返回已创建的记录，之后的修改也可以用客户端 ID 引用该记录
+type SyncMapping struct {
+	ID         uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     uint64    `json:"userId" gorm:"not null;uniqueIndex:idx_sync_client"`
+	EntityType string    `json:"entityType" gorm:"type:varchar(20);not null;uniqueIndex:idx_sync_client"` // task, list, folder, tag, habit, habitRecord, countdown, filter
+	ClientID   string    `json:"clientId" gorm:"type:varchar(64);not null;uniqueIndex:idx_sync_client"`
+	EntityID   uint64    `json:"entityId" gorm:"not null"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
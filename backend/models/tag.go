@@ -14,7 +14,9 @@ type Tag struct {
 	Color     string         `json:"color" gorm:"type:varchar(20)"`
 	IsPinned  bool           `json:"isPinned" gorm:"default:false"`
 	SortOrder int            `json:"sortOrder" gorm:"default:0"`
+	Version   uint64         `json:"version" gorm:"not null;default:1"` // 版本号，每次修改加一，用作 ETag
 	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 	
 	// 关联
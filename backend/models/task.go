@@ -14,7 +14,8 @@ type Task struct {
 	Description  string `json:"description" gorm:"type:text"`
 	Priority     int    `json:"priority" gorm:"default:0;index:idx_user_priority"`                   // 0-3 (四象限)
 	Status       string `json:"status" gorm:"type:varchar(20);default:'todo';index:idx_user_status"` // todo, completed, abandoned
-	SortOrder    int    `json:"sortOrder" gorm:"default:0;index:idx_sort_order"`                     // 排序顺序
+	SortOrder    int    `json:"sortOrder" gorm:"default:0;index:idx_sort_order"`                     // 旧排序顺序，已由 Rank 取代
+	Rank         string `json:"rank" gorm:"type:varchar(64);default:'';index:idx_task_rank"`         // 排序键，按字典序升序排列
 	DueDate      string `json:"dueDate" gorm:"type:varchar(8);index:idx_user_due_date"`              // 截止日期，格式：20251105
 	DueTime      string `json:"dueTime" gorm:"type:varchar(5)"`                                      // 截止时间，格式：18:20
 	ReminderTime string `json:"reminderTime" gorm:"type:varchar(14)"`                                // 提醒时间，格式：20251105 18:20
@@ -28,8 +29,16 @@ type Task struct {
 	RecurrenceMonthDay  int     `json:"recurrenceMonthDay"`                          // 每月第几天，1-31
 	RecurrenceLunarDate string  `json:"recurrenceLunarDate" gorm:"type:varchar(20)"` // 农历日期，格式: "MM-DD"
 	RecurrenceEndDate   string  `json:"recurrenceEndDate" gorm:"type:varchar(8)"`    // 重复结束日期，格式：20251231
+	RRule               string  `json:"rrule" gorm:"type:varchar(255)"`              // RFC 5545 重复规则，如 "FREQ=MONTHLY;BYDAY=2TU"，优先于上面的旧版字段
+	RecurrenceStart     string  `json:"recurrenceStart" gorm:"type:varchar(8)"`      // 重复规则起始日期（DTSTART），格式：20251105
+	RecurrenceAnchor    string  `json:"recurrenceAnchor" gorm:"type:varchar(20)"`    // 下次日期的计算起点：due（截止日期，默认）、completion（完成日期）
+	RecurrenceCatchUp   string  `json:"recurrenceCatchUp" gorm:"type:varchar(20)"`   // 逾期补偿：空（只生成下一个）、skip（跳过错过的日期）、each（每个错过的日期一个实例）
+	RecurrenceExDates   string  `json:"recurrenceExDates" gorm:"type:text"`          // 例外日期（EXDATE），JSON数组，如 ["20251105"]，记录被跳过或移动的实例
 	ParentTaskID        *uint64 `json:"parentTaskId" gorm:"index:idx_parent_task"`   // 原始重复任务ID
+	SeriesID            *uint64 `json:"seriesId" gorm:"index:idx_series"`            // 所属重复系列ID，同一系列的所有实例相同
+	ParentID            *uint64 `json:"parentId" gorm:"index:idx_parent"`            // 父任务ID，不为空时为子任务
 
+	Version   uint64         `json:"version" gorm:"not null;default:1"` // 版本号，每次修改加一，用作 ETag
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"-"`
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TaskDependency 任务依赖：TaskID 被 BlockedByID 阻塞，BlockedByID 完成前 TaskID 不应开始
+type TaskDependency struct {
+	ID          uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint64    `json:"userId" gorm:"not null;index:idx_user_dependencies"`
+	TaskID      uint64    `json:"taskId" gorm:"not null;uniqueIndex:idx_task_blocked_by"`
+	BlockedByID uint64    `json:"blockedById" gorm:"not null;uniqueIndex:idx_task_blocked_by;index:idx_blocked_by"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
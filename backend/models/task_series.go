@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskSeries 重复任务系列，所有实例共享同一个系列，下一个实例按系列模板生成
+// 模板字段的列名与 Task 保持一致，便于批量同步到各实例
+type TaskSeries struct {
+	ID          uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint64 `json:"userId" gorm:"not null;index:idx_user_series"`
+	ListID      uint64 `json:"listId" gorm:"not null"`
+	Title       string `json:"title" gorm:"type:varchar(500);not null"`
+	Description string `json:"description" gorm:"type:text"`
+	Priority    int    `json:"priority" gorm:"default:0"`
+	DueTime     string `json:"dueTime" gorm:"type:varchar(5)"`  // 截止时间，格式：18:20
+	TagIDs      string `json:"tagIds" gorm:"type:varchar(500)"` // JSON数组: "[1,2,3]"
+
+	// 重复规则
+	IsRecurring         bool   `json:"isRecurring" gorm:"default:true"`
+	RecurrenceType      string `json:"recurrenceType" gorm:"type:varchar(20)"`
+	RecurrenceInterval  int    `json:"recurrenceInterval" gorm:"default:1"`
+	RecurrenceWeekdays  string `json:"recurrenceWeekdays" gorm:"type:varchar(50)"`
+	RecurrenceMonthDay  int    `json:"recurrenceMonthDay"`
+	RecurrenceLunarDate string `json:"recurrenceLunarDate" gorm:"type:varchar(20)"`
+	RecurrenceEndDate   string `json:"recurrenceEndDate" gorm:"type:varchar(8)"`
+	RRule               string `json:"rrule" gorm:"type:varchar(255)"`
+	RecurrenceStart     string `json:"recurrenceStart" gorm:"type:varchar(8)"`
+	RecurrenceAnchor    string `json:"recurrenceAnchor" gorm:"type:varchar(20)"`
+	RecurrenceCatchUp   string `json:"recurrenceCatchUp" gorm:"type:varchar(20)"`
+
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+}
+
+// TableName 指定表名
+func (TaskSeries) TableName() string {
+	return "task_series"
+}
@@ -18,6 +18,7 @@ type ViewConfig struct {
 	ViewType      string         `json:"viewType" gorm:"type:varchar(20);default:'list'"` // "list", "kanban", "timeline"
 	HideCompleted bool           `json:"hideCompleted" gorm:"default:false"` // 是否隐藏已完成分组
 	ShowDetail    bool           `json:"showDetail" gorm:"default:false"` // 是否显示任务详情
+	Version       uint64         `json:"version" gorm:"not null;default:1"` // 版本号，每次修改加一，用作 ETag
 	CreatedAt     time.Time      `json:"createdAt"`
 	UpdatedAt     time.Time      `json:"updatedAt"`
 	DeletedAt     gorm.DeletedAt `json:"-"`
@@ -3,30 +3,51 @@ package routes
 import (
 	"on-the-way/backend/controllers"
 	"on-the-way/backend/middleware"
+	"on-the-way/backend/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
+// Dependencies 路由与后台任务共享的服务
+type Dependencies struct {
+	EmailChannel  *services.EmailChannel
+	WechatChannel *services.WechatChannel
+	EventHub      *services.EventHub
+
+	RequireIfMatch       bool          // 修改和删除资源时必须携带 If-Match 头
+	SyncTokenTTL         time.Duration // 同步游标的有效期，与回收站保留时间相同
+	AccountImportMaxSize int64         // 导入账户备份时文件的大小上限（字节）
+}
+
+func RegisterRoutes(r *gin.Engine, db *gorm.DB, deps Dependencies) {
 	api := r.Group("/api")
 
 	// 初始化controllers
 	authController := controllers.NewAuthController(db)
 	folderController := controllers.NewFolderController(db)
-	taskController := controllers.NewTaskController(db)
+	taskController := controllers.NewTaskController(db, deps.EventHub)
 	listController := controllers.NewListController(db)
-	pomodoroController := controllers.NewPomodoroController(db)
+	pomodoroController := controllers.NewPomodoroController(db, deps.EventHub)
 	habitController := controllers.NewHabitController(db)
 	countdownController := controllers.NewCountdownController(db)
 	statisticsController := controllers.NewStatisticsController(db)
 	searchController := controllers.NewSearchController(db)
-	reminderController := controllers.NewReminderController(db)
-	settingsController := controllers.NewUserSettingsController(db)
+	reminderController := controllers.NewReminderController(db, deps.EventHub)
+	settingsController := controllers.NewUserSettingsController(db, deps.EmailChannel, deps.WechatChannel)
 	tagController := controllers.NewTagController(db)
 	filterController := controllers.NewFilterController(db)
 	viewConfigController := controllers.NewViewConfigController(db)
 	holidayController := controllers.NewHolidayController(db)
+	eventController := controllers.NewEventController(deps.EventHub)
+	calendarController := controllers.NewCalendarController()
+	trashController := controllers.NewTrashController(db, deps.EventHub)
+	syncController := controllers.NewSyncController(db, deps.EventHub, deps.SyncTokenTTL)
+	accountController := controllers.NewAccountController(db, deps.EventHub, deps.AccountImportMaxSize)
+
+	// 带版本号的资源在修改和删除时校验 If-Match
+	ifMatch := middleware.RequireIfMatch(deps.RequireIfMatch)
 
 	// 认证路由 (不需要JWT)
 	auth := api.Group("/auth")
@@ -35,6 +56,9 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		auth.POST("/login", authController.Login)
 	}
 
+	// 实时事件流 (支持通过查询参数传递token)
+	api.GET("/events", middleware.StreamAuthMiddleware(), eventController.Stream)
+
 	// 需要认证的路由
 	authorized := api.Group("")
 	authorized.Use(middleware.AuthMiddleware())
@@ -48,27 +72,43 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		authorized.GET("/tasks", taskController.GetTasks)
 		authorized.POST("/tasks", taskController.CreateTask)
 		authorized.GET("/tasks/:id", taskController.GetTask)
-		authorized.PUT("/tasks/:id", taskController.UpdateTask)
-		authorized.DELETE("/tasks/:id", taskController.DeleteTask)
-		authorized.PUT("/tasks/:id/complete", taskController.CompleteTask)
-		authorized.PUT("/tasks/:id/abandon", taskController.AbandonTask)
-		authorized.PUT("/tasks/:id/priority", taskController.UpdatePriority)
+		authorized.PUT("/tasks/:id", ifMatch, taskController.UpdateTask)
+		authorized.DELETE("/tasks/:id", ifMatch, taskController.DeleteTask)
+		authorized.PUT("/tasks/:id/complete", ifMatch, taskController.CompleteTask)
+		authorized.PUT("/tasks/:id/abandon", ifMatch, taskController.AbandonTask)
+		authorized.PUT("/tasks/:id/priority", ifMatch, taskController.UpdatePriority)
+		authorized.GET("/tasks/:id/occurrences", taskController.GetOccurrences)
+		authorized.POST("/tasks/:id/skip", ifMatch, taskController.SkipOccurrence)
+		authorized.GET("/tasks/:id/series", taskController.GetSeries)
+		authorized.GET("/tasks/:id/subtasks", taskController.GetSubtasks)
+		authorized.POST("/tasks/:id/subtasks", taskController.CreateSubtask)
+		authorized.PUT("/tasks/:id/subtasks/:subtaskId", ifMatch, taskController.UpdateSubtask)
+		authorized.DELETE("/tasks/:id/subtasks/:subtaskId", ifMatch, taskController.DeleteSubtask)
+		authorized.GET("/tasks/:id/checklist", taskController.GetChecklist)
+		authorized.POST("/tasks/:id/checklist", taskController.CreateChecklistItem)
+		authorized.PUT("/tasks/:id/checklist/:itemId", taskController.UpdateChecklistItem)
+		authorized.DELETE("/tasks/:id/checklist/:itemId", taskController.DeleteChecklistItem)
+		authorized.GET("/tasks/:id/dependencies", taskController.GetDependencies)
+		authorized.POST("/tasks/:id/dependencies", taskController.AddDependency)
+		authorized.DELETE("/tasks/:id/dependencies/:blockedById", taskController.RemoveDependency)
 		authorized.PUT("/tasks/reorder", taskController.ReorderTasks)
+		authorized.PUT("/tasks/:id/move", ifMatch, taskController.MoveTask)
+		authorized.POST("/tasks/batch", taskController.BatchTasks)
 
 		// 文件夹相关
 		authorized.GET("/folders", folderController.GetFolders)
 		authorized.POST("/folders", folderController.CreateFolder)
 		authorized.GET("/folders/:id", folderController.GetFolder)
-		authorized.PUT("/folders/:id", folderController.UpdateFolder)
-		authorized.DELETE("/folders/:id", folderController.DeleteFolder)
-		authorized.PUT("/folders/:id/toggle", folderController.ToggleExpand)
+		authorized.PUT("/folders/:id", ifMatch, folderController.UpdateFolder)
+		authorized.DELETE("/folders/:id", ifMatch, folderController.DeleteFolder)
+		authorized.PUT("/folders/:id/toggle", ifMatch, folderController.ToggleExpand)
 
 		// 清单相关
 		authorized.GET("/lists", listController.GetLists)
 		authorized.POST("/lists", listController.CreateList)
-		authorized.PUT("/lists/:id", listController.UpdateList)
-		authorized.DELETE("/lists/:id", listController.DeleteList)
-		authorized.PUT("/lists/:id/move", listController.MoveList)
+		authorized.PUT("/lists/:id", ifMatch, listController.UpdateList)
+		authorized.DELETE("/lists/:id", ifMatch, listController.DeleteList)
+		authorized.PUT("/lists/:id/move", ifMatch, listController.MoveList)
 
 		// 番茄时钟相关
 		authorized.POST("/pomodoros", pomodoroController.Start)
@@ -80,8 +120,8 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		authorized.GET("/habits", habitController.GetHabits)
 		authorized.GET("/habits/today", habitController.GetTodayHabits)
 		authorized.POST("/habits", habitController.CreateHabit)
-		authorized.PUT("/habits/:id", habitController.UpdateHabit)
-		authorized.DELETE("/habits/:id", habitController.DeleteHabit)
+		authorized.PUT("/habits/:id", ifMatch, habitController.UpdateHabit)
+		authorized.DELETE("/habits/:id", ifMatch, habitController.DeleteHabit)
 		authorized.POST("/habits/:id/check", habitController.CheckIn)
 		authorized.DELETE("/habits/:id/check", habitController.CancelCheckIn)
 		authorized.GET("/habits/:id/records", habitController.GetRecords)
@@ -115,29 +155,50 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		// 用户设置
 		authorized.GET("/settings", settingsController.GetSettings)
 		authorized.PUT("/settings", settingsController.UpdateSettings)
+		authorized.POST("/settings/test-notification", settingsController.TestNotification)
 
 		// 标签相关
 		authorized.GET("/tags", tagController.GetTags)
 		authorized.POST("/tags", tagController.CreateTag)
-		authorized.PUT("/tags/:id", tagController.UpdateTag)
-		authorized.DELETE("/tags/:id", tagController.DeleteTag)
-		authorized.PUT("/tags/:id/move", tagController.MoveTag)
-		authorized.PUT("/tags/:id/toggle-pin", tagController.TogglePin)
+		authorized.PUT("/tags/:id", ifMatch, tagController.UpdateTag)
+		authorized.DELETE("/tags/:id", ifMatch, tagController.DeleteTag)
+		authorized.PUT("/tags/:id/move", ifMatch, tagController.MoveTag)
+		authorized.PUT("/tags/:id/toggle-pin", ifMatch, tagController.TogglePin)
 		authorized.GET("/tags/:id/tasks", tagController.GetTasksByTag)
 
 		// 过滤器相关
 		authorized.GET("/filters", filterController.GetFilters)
 		authorized.POST("/filters", filterController.CreateFilter)
-		authorized.PUT("/filters/:id", filterController.UpdateFilter)
-		authorized.DELETE("/filters/:id", filterController.DeleteFilter)
-		authorized.PUT("/filters/:id/toggle-pin", filterController.TogglePin)
+		authorized.PUT("/filters/:id", ifMatch, filterController.UpdateFilter)
+		authorized.DELETE("/filters/:id", ifMatch, filterController.DeleteFilter)
+		authorized.PUT("/filters/:id/toggle-pin", ifMatch, filterController.TogglePin)
 		authorized.PUT("/filters/reorder", filterController.ReorderFilters)
+		authorized.POST("/filters/preview", filterController.PreviewFilter)
+		authorized.GET("/filters/:id/tasks", filterController.GetFilterTasks)
 
 		// 视图配置相关
 		authorized.GET("/view-configs", viewConfigController.GetViewConfig)
-		authorized.PUT("/view-configs", viewConfigController.UpdateViewConfig)
+		authorized.PUT("/view-configs", ifMatch, viewConfigController.UpdateViewConfig)
 
 		// 节假日相关
 		authorized.GET("/holidays/:year", holidayController.GetHolidaysByYear)
+
+		// 日历相关
+		authorized.GET("/calendar/lunar", calendarController.GetLunarDate)
+
+		// 回收站相关
+		authorized.GET("/trash", trashController.GetTrash)
+		authorized.DELETE("/trash", trashController.EmptyTrash)
+		authorized.POST("/trash/:type/:id/restore", trashController.RestoreItem)
+		authorized.DELETE("/trash/:type/:id", trashController.PurgeItem)
+
+		// 离线同步
+		authorized.GET("/sync", syncController.GetChanges)
+		authorized.POST("/sync", syncController.PushChanges)
+
+		// 账户备份
+		authorized.GET("/account/export", accountController.ExportAccount)
+		authorized.POST("/account/import", accountController.ImportAccount)
+		authorized.POST("/account/import/:source", accountController.ImportExternal)
 	}
 }
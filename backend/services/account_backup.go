@@ -0,0 +1,1187 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 账户备份文件的格式标识和版本，备份结构不兼容地变化时增加版本号
+const (
+	AccountBackupFormat  = "on-the-way-backup"
+	AccountBackupVersion = 1
+)
+
+// AccountBackupZipEntry zip 格式备份中的 JSON 文件名
+const AccountBackupZipEntry = "backup.json"
+
+// maxImportWarnings 导入报告中最多列出的提示条数
+const maxImportWarnings = 200
+
+// ErrInvalidBackup 备份文件无法解析或格式、版本不受支持
+var ErrInvalidBackup = errors.New("invalid backup file")
+
+// BackupTask 备份中的任务，标签以 ID 列表保存
+type BackupTask struct {
+	models.Task
+	TagIDs []uint64 `json:"tagIds"`
+}
+
+// AccountBackup 账户备份：用户拥有的全部未删除记录，记录之间按备份中的 ID 互相引用
+type AccountBackup struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+
+	Settings         *models.UserSettings    `json:"settings"`
+	Folders          []models.Folder         `json:"folders"`
+	Lists            []models.List           `json:"lists"`
+	Tags             []models.Tag            `json:"tags"`
+	TaskSeries       []models.TaskSeries     `json:"taskSeries"`
+	Tasks            []BackupTask            `json:"tasks"`
+	ChecklistItems   []models.ChecklistItem  `json:"checklistItems"`
+	TaskDependencies []models.TaskDependency `json:"taskDependencies"`
+	Habits           []models.Habit          `json:"habits"`
+	HabitRecords     []models.HabitRecord    `json:"habitRecords"`
+	Pomodoros        []models.Pomodoro       `json:"pomodoros"`
+	Countdowns       []models.Countdown      `json:"countdowns"`
+	Filters          []models.Filter         `json:"filters"`
+	ViewConfigs      []models.ViewConfig     `json:"viewConfigs"`
+	Reminders        []models.Reminder       `json:"reminders"`
+	Statistics       []models.Statistics     `json:"statistics"`
+}
+
+// accountBackupSections 备份中各类记录的名称，与 AccountBackup 的 JSON 字段一致，用作导入报告的键
+var accountBackupSections = []string{
+	"settings", "folders", "lists", "tags", "taskSeries", "tasks", "checklistItems", "taskDependencies",
+	"habits", "habitRecords", "pomodoros", "countdowns", "filters", "viewConfigs", "reminders", "statistics",
+}
+
+// ExportAccount 读取用户的全部未删除记录。检查项、依赖和提醒只包含仍然存在的任务和习惯的记录
+func ExportAccount(db *gorm.DB, userID uint64, now time.Time) (*AccountBackup, error) {
+	backup := &AccountBackup{
+		Format:     AccountBackupFormat,
+		Version:    AccountBackupVersion,
+		ExportedAt: now,
+	}
+
+	byUser := func(query *gorm.DB) *gorm.DB {
+		return query.Where("user_id = ?", userID).Order("id ASC")
+	}
+	liveTasks := func() *gorm.DB { return db.Model(&models.Task{}).Select("id").Where("user_id = ?", userID) }
+	liveHabits := func() *gorm.DB { return db.Model(&models.Habit{}).Select("id").Where("user_id = ?", userID) }
+
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err == nil {
+		backup.Settings = &settings
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	var taskTags []models.TaskTag
+	loaders := []func() error{
+		func() error { return db.Scopes(byUser).Find(&backup.Folders).Error },
+		func() error { return db.Scopes(byUser).Find(&backup.Lists).Error },
+		func() error { return db.Scopes(byUser).Find(&backup.Tags).Error },
+		func() error { return db.Scopes(byUser).Find(&backup.TaskSeries).Error },
+		func() error { return db.Scopes(byUser).Find(&tasks).Error },
+		func() error {
+			return db.Where("task_id IN (?) AND tag_id IN (?)", liveTasks(), db.Model(&models.Tag{}).Select("id").Where("user_id = ?", userID)).
+				Order("task_id ASC, tag_id ASC").
+				Find(&taskTags).Error
+		},
+		func() error {
+			return db.Scopes(byUser).Where("task_id IN (?)", liveTasks()).Find(&backup.ChecklistItems).Error
+		},
+		func() error {
+			return db.Scopes(byUser).Where("task_id IN (?) AND blocked_by_id IN (?)", liveTasks(), liveTasks()).Find(&backup.TaskDependencies).Error
+		},
+		func() error { return db.Scopes(byUser).Find(&backup.Habits).Error },
+		func() error {
+			return db.Where("habit_id IN (?)", liveHabits()).Order("id ASC").Find(&backup.HabitRecords).Error
+		},
+		func() error { return db.Scopes(byUser).Find(&backup.Pomodoros).Error },
+		func() error { return db.Scopes(byUser).Find(&backup.Countdowns).Error },
+		func() error { return db.Scopes(byUser).Find(&backup.Filters).Error },
+		func() error { return db.Scopes(byUser).Find(&backup.ViewConfigs).Error },
+		func() error {
+			return db.Scopes(byUser).
+				Where("(entity_type = ? AND entity_id IN (?)) OR (entity_type = ? AND entity_id IN (?))", "task", liveTasks(), "habit", liveHabits()).
+				Find(&backup.Reminders).Error
+		},
+		func() error { return db.Scopes(byUser).Find(&backup.Statistics).Error },
+	}
+	for _, load := range loaders {
+		if err := load(); err != nil {
+			return nil, err
+		}
+	}
+
+	tagIDs := make(map[uint64][]uint64)
+	for _, taskTag := range taskTags {
+		tagIDs[taskTag.TaskID] = append(tagIDs[taskTag.TaskID], taskTag.TagID)
+	}
+	backup.Tasks = make([]BackupTask, 0, len(tasks))
+	for _, task := range tasks {
+		ids := tagIDs[task.ID]
+		if ids == nil {
+			ids = []uint64{}
+		}
+		backup.Tasks = append(backup.Tasks, BackupTask{Task: task, TagIDs: ids})
+	}
+	return backup, nil
+}
+
+// WriteAccountBackup 把备份写入 w，zipped 为 true 时打包为只包含 backup.json 的 zip 文件
+func WriteAccountBackup(w io.Writer, backup *AccountBackup, zipped bool) error {
+	if !zipped {
+		return json.NewEncoder(w).Encode(backup)
+	}
+
+	archive := zip.NewWriter(w)
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     AccountBackupZipEntry,
+		Method:   zip.Deflate,
+		Modified: backup.ExportedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(entry).Encode(backup); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// ParseAccountBackup 解析 JSON 或 zip 格式的备份文件并校验格式和版本，maxSize 为解压后 JSON 的大小上限
+func ParseAccountBackup(data []byte, maxSize int64) (*AccountBackup, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		var entry *zip.File
+		for _, file := range archive.File {
+			if file.Name == AccountBackupZipEntry || (entry == nil && path.Ext(file.Name) == ".json") {
+				entry = file
+			}
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("%w: %s not found in archive", ErrInvalidBackup, AccountBackupZipEntry)
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		defer reader.Close()
+		// 限制解压后的大小，避免压缩炸弹
+		data, err = io.ReadAll(io.LimitReader(reader, maxSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if int64(len(data)) > maxSize {
+			return nil, fmt.Errorf("%w: %s is too large", ErrInvalidBackup, entry.Name)
+		}
+	}
+
+	var backup AccountBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if backup.Format != AccountBackupFormat {
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidBackup, backup.Format)
+	}
+	if backup.Version < 1 || backup.Version > AccountBackupVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, backup.Version)
+	}
+	return &backup, nil
+}
+
+// AccountImportReport 导入结果，各类记录按 AccountBackup 中的名称统计
+type AccountImportReport struct {
+	DryRun   bool           `json:"dryRun"`
+	Imported map[string]int `json:"imported"` // 新建的记录数
+	Merged   map[string]int `json:"merged"`   // 与账户中已有记录合并的记录数，如收集箱、同名标签、已有的视图配置
+	Skipped  map[string]int `json:"skipped"`  // 无效或引用的记录不存在而跳过的记录数
+	Warnings []string       `json:"warnings"` // 跳过或调整记录的原因
+
+	omittedWarnings int
+}
+
+func (r *AccountImportReport) warn(format string, args ...interface{}) {
+	if len(r.Warnings) >= maxImportWarnings {
+		r.omittedWarnings++
+		return
+	}
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// AccountImportOptions 导入选项
+type AccountImportOptions struct {
+	// SkipDuplicates 账户中导入前已有相同的记录（如同一清单中同名、同截止日期的任务）时不再创建，计为合并，
+	// 重复导入同一个文件不会产生重复数据
+	SkipDuplicates bool
+	// CountCompletions 导入的已完成任务计入完成日期的每日统计，用于没有统计数据的其他应用的导出文件
+	CountCompletions bool
+}
+
+// duplicateSections 开启 SkipDuplicates 时检测重复的记录，值为对应的模型
+var duplicateSections = map[string]interface{}{
+	"folders":          &models.Folder{},
+	"lists":            &models.List{},
+	"taskSeries":       &models.TaskSeries{},
+	"tasks":            &models.Task{},
+	"checklistItems":   &models.ChecklistItem{},
+	"taskDependencies": &models.TaskDependency{},
+	"habits":           &models.Habit{},
+	"habitRecords":     &models.HabitRecord{},
+	"pomodoros":        &models.Pomodoro{},
+	"countdowns":       &models.Countdown{},
+	"filters":          &models.Filter{},
+	"reminders":        &models.Reminder{},
+}
+
+// dailyCompletions 某一天完成的任务数，分类与完成任务时更新的每日统计相同
+type dailyCompletions struct {
+	completed, onTime, overdue, noDate int
+}
+
+// accountImporter 导入过程中的状态：备份中的旧 ID 到新记录 ID 的映射
+type accountImporter struct {
+	tx     *gorm.DB
+	userID uint64
+	now    time.Time
+	opts   AccountImportOptions
+	report *AccountImportReport
+
+	inboxID uint64
+	folders map[uint64]uint64
+	lists   map[uint64]uint64
+	tags    map[uint64]uint64
+	series  map[uint64]uint64
+	tasks   map[uint64]uint64
+	habits  map[uint64]uint64
+	filters map[uint64]uint64
+
+	// 导入前各类记录的最大 ID，只与这些记录比较是否重复，备份中相同的记录仍分别导入
+	maxIDs map[string]uint64
+	// 与已有记录合并的任务和习惯（新 ID），只有它们的检查项、打卡记录等可能重复
+	mergedTasks  map[uint64]bool
+	mergedHabits map[uint64]bool
+	completions  map[string]*dailyCompletions
+
+	rebalanceRanks bool
+}
+
+// ImportAccount 在事务 tx 中把备份导入到用户的账户（新账户或已有数据的账户）。
+// 记录以新 ID 创建，引用按新 ID 重新关联；备份中的收集箱与账户的收集箱合并，同名同级的标签合并为一个。
+// 无效或引用缺失的记录跳过，并在报告中说明原因；只有数据库错误时返回 error
+func ImportAccount(tx *gorm.DB, userID uint64, backup *AccountBackup, now time.Time, opts AccountImportOptions) (*AccountImportReport, error) {
+	report := &AccountImportReport{
+		Imported: make(map[string]int),
+		Merged:   make(map[string]int),
+		Skipped:  make(map[string]int),
+		Warnings: []string{},
+	}
+	for _, section := range accountBackupSections {
+		report.Imported[section] = 0
+		report.Merged[section] = 0
+		report.Skipped[section] = 0
+	}
+
+	var inbox models.List
+	if err := tx.Where("user_id = ? AND is_default = ?", userID, true).First(&inbox).Error; err != nil {
+		return nil, err
+	}
+
+	im := &accountImporter{
+		tx:      tx,
+		userID:  userID,
+		now:     now,
+		opts:    opts,
+		report:  report,
+		inboxID: inbox.ID,
+		folders: make(map[uint64]uint64),
+		lists:   make(map[uint64]uint64),
+		tags:    make(map[uint64]uint64),
+		series:  make(map[uint64]uint64),
+		tasks:   make(map[uint64]uint64),
+		habits:  make(map[uint64]uint64),
+		filters: make(map[uint64]uint64),
+
+		maxIDs:       make(map[string]uint64),
+		mergedTasks:  make(map[uint64]bool),
+		mergedHabits: make(map[uint64]bool),
+		completions:  make(map[string]*dailyCompletions),
+	}
+	if opts.SkipDuplicates {
+		for section, model := range duplicateSections {
+			var maxID uint64
+			if err := tx.Unscoped().Model(model).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+				return nil, err
+			}
+			im.maxIDs[section] = maxID
+		}
+	}
+
+	steps := []func() error{
+		func() error { return im.importSettings(backup.Settings) },
+		func() error { return importEach(backup.Folders, im.importFolder) },
+		func() error { return importEach(backup.Lists, im.importList) },
+		func() error {
+			return importInOrder(backup.Tags, func(tag *models.Tag) uint64 { return tag.ID },
+				func(tag *models.Tag) []*uint64 { return []*uint64{tag.ParentID} }, im.importTag)
+		},
+		func() error { return importEach(backup.TaskSeries, im.importTaskSeries) },
+		func() error {
+			return importInOrder(backup.Tasks, func(task *BackupTask) uint64 { return task.ID },
+				func(task *BackupTask) []*uint64 { return []*uint64{task.ParentID, task.ParentTaskID} }, im.importTask)
+		},
+		func() error { return importEach(backup.ChecklistItems, im.importChecklistItem) },
+		func() error { return im.importTaskDependencies(backup.TaskDependencies) },
+		func() error { return importEach(backup.Habits, im.importHabit) },
+		func() error { return importEach(backup.HabitRecords, im.importHabitRecord) },
+		func() error { return importEach(backup.Pomodoros, im.importPomodoro) },
+		func() error { return importEach(backup.Countdowns, im.importCountdown) },
+		func() error { return importEach(backup.Filters, im.importFilter) },
+		func() error { return importEach(backup.ViewConfigs, im.importViewConfig) },
+		func() error { return importEach(backup.Reminders, im.importReminder) },
+		func() error { return importEach(backup.Statistics, im.importStatistics) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	if err := im.applyCompletions(); err != nil {
+		return nil, err
+	}
+
+	// 备份中没有有效排序键的任务按原来的顺序重新分配
+	if im.rebalanceRanks {
+		if err := RebalanceTaskRanks(tx, userID); err != nil {
+			return nil, err
+		}
+	}
+	if report.omittedWarnings > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d more warnings omitted", report.omittedWarnings))
+	}
+	return report, nil
+}
+
+// importEach 依次导入一类记录
+func importEach[T any](records []T, importOne func(*T) error) error {
+	for i := range records {
+		if err := importOne(&records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importInOrder 导入引用同类记录的记录（如子任务引用父任务），被引用的记录先导入。
+// 循环引用时最先访问的记录找不到被引用记录的新 ID，按引用缺失处理
+func importInOrder[T any](records []T, idOf func(*T) uint64, refsOf func(*T) []*uint64, importOne func(*T) error) error {
+	index := make(map[uint64]int, len(records))
+	for i := range records {
+		if _, ok := index[idOf(&records[i])]; !ok {
+			index[idOf(&records[i])] = i
+		}
+	}
+
+	const visiting, done = 1, 2
+	state := make([]int, len(records))
+	var visit func(i int) error
+	visit = func(i int) error {
+		if state[i] != 0 {
+			return nil
+		}
+		state[i] = visiting
+		for _, ref := range refsOf(&records[i]) {
+			if ref == nil {
+				continue
+			}
+			if j, ok := index[*ref]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = done
+		return importOne(&records[i])
+	}
+	for i := range records {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip 跳过一条记录并记录原因
+func (im *accountImporter) skip(section, format string, args ...interface{}) {
+	im.report.Skipped[section]++
+	im.report.warn(format, args...)
+}
+
+// isDuplicate 备份中同一类记录的 ID 重复时跳过后出现的记录
+func (im *accountImporter) isDuplicate(section string, mapping map[uint64]uint64, id uint64) bool {
+	if _, ok := mapping[id]; !ok {
+		return false
+	}
+	im.skip(section, "%s %d: duplicate id", section, id)
+	return true
+}
+
+// findDuplicate 开启 SkipDuplicates 时在导入前已有的未删除记录中查找满足条件的记录，返回其 ID，没有时返回 0
+func (im *accountImporter) findDuplicate(section string, query string, args ...interface{}) (uint64, error) {
+	if !im.opts.SkipDuplicates {
+		return 0, nil
+	}
+	var ids []uint64
+	err := im.tx.Model(duplicateSections[section]).
+		Where("id <= ?", im.maxIDs[section]).
+		Where(query, args...).
+		Order("id").Limit(1).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// mergeDuplicate 查找重复的已有记录，找到时把备份中的旧 ID 映射到已有记录并计为合并
+func (im *accountImporter) mergeDuplicate(section string, mapping map[uint64]uint64, oldID uint64, query string, args ...interface{}) (bool, error) {
+	existingID, err := im.findDuplicate(section, query, args...)
+	if err != nil || existingID == 0 {
+		return false, err
+	}
+	if mapping != nil {
+		mapping[oldID] = existingID
+	}
+	im.report.Merged[section]++
+	return true, nil
+}
+
+// mapRef 把可选引用换成新 ID，被引用的记录不存在时返回 nil
+func mapRef(mapping map[uint64]uint64, ref *uint64) (*uint64, bool) {
+	if ref == nil {
+		return nil, true
+	}
+	if id, ok := mapping[*ref]; ok {
+		return &id, true
+	}
+	return nil, false
+}
+
+// mapIDs 把 ID 列表换成新 ID，返回新 ID 和找不到的旧 ID 个数；重复的新 ID 只保留一个
+func mapIDs(mapping map[uint64]uint64, ids []uint64) ([]uint64, int) {
+	mapped := make([]uint64, 0, len(ids))
+	seen := make(map[uint64]bool, len(ids))
+	missing := 0
+	for _, id := range ids {
+		newID, ok := mapping[id]
+		if !ok {
+			missing++
+			continue
+		}
+		if !seen[newID] {
+			seen[newID] = true
+			mapped = append(mapped, newID)
+		}
+	}
+	return mapped, missing
+}
+
+// listOrInbox 返回清单的新 ID，清单不存在时返回收集箱
+func (im *accountImporter) listOrInbox(label string, listID uint64) uint64 {
+	if id, ok := im.lists[listID]; ok {
+		return id
+	}
+	im.report.warn("%s: list %d not found, moved to inbox", label, listID)
+	return im.inboxID
+}
+
+// createImported 以新 ID 创建导入的记录：版本号从 1 开始，updated_at 为导入时间，以便同步给其他设备。
+// GORM 创建时会把带默认值的零值布尔字段（如为 false 的 isExpanded）替换为默认值，创建后再改回备份中的值
+func createImported(tx *gorm.DB, record interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(record); err != nil {
+		return err
+	}
+	ctx := tx.Statement.Context
+	value := reflect.Indirect(reflect.ValueOf(record))
+
+	restore := make(map[string]interface{})
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		switch {
+		case field.PrimaryKey || field.Name == "Version" || field.Name == "UpdatedAt":
+			if err := field.Set(ctx, value, reflect.Zero(field.FieldType).Interface()); err != nil {
+				return err
+			}
+		case field.FieldType.Kind() == reflect.Bool && field.DefaultValueInterface == true:
+			if _, isZero := field.ValueOf(ctx, value); isZero {
+				restore[field.DBName] = false
+			}
+		}
+	}
+
+	if err := tx.Create(record).Error; err != nil {
+		return err
+	}
+	if len(restore) == 0 {
+		return nil
+	}
+
+	// 不经过模型更新，版本号和 updated_at 保持不变
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, value)
+	if err := tx.Table(stmt.Schema.Table).Where("id = ?", id).UpdateColumns(restore).Error; err != nil {
+		return err
+	}
+	for column, zero := range restore {
+		if err := stmt.Schema.FieldsByDBName[column].Set(ctx, value, zero); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importSettings 用备份中的设置覆盖账户的设置
+func (im *accountImporter) importSettings(backup *models.UserSettings) error {
+	if backup == nil {
+		return nil
+	}
+	settings := *backup
+	settings.UserID = im.userID
+
+	// user_id 唯一，已删除的设置也要覆盖
+	var existing models.UserSettings
+	err := im.tx.Unscoped().Where("user_id = ?", im.userID).First(&existing).Error
+	switch {
+	case err == nil:
+		settings.ID = existing.ID
+		settings.CreatedAt = existing.CreatedAt
+		settings.UpdatedAt = time.Time{}
+		err = im.tx.Save(&settings).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = createImported(im.tx, &settings)
+	}
+	if err != nil {
+		return err
+	}
+	im.report.Imported["settings"]++
+	return nil
+}
+
+func (im *accountImporter) importFolder(folder *models.Folder) error {
+	oldID := folder.ID
+	if im.isDuplicate("folders", im.folders, oldID) {
+		return nil
+	}
+	if strings.TrimSpace(folder.Name) == "" {
+		im.skip("folders", "folder %d: name is empty", oldID)
+		return nil
+	}
+
+	merged, err := im.mergeDuplicate("folders", im.folders, oldID, "user_id = ? AND name = ?", im.userID, folder.Name)
+	if err != nil || merged {
+		return err
+	}
+
+	folder.UserID = im.userID
+	if err := createImported(im.tx, folder); err != nil {
+		return err
+	}
+	im.folders[oldID] = folder.ID
+	im.report.Imported["folders"]++
+	return nil
+}
+
+// importList 导入清单，系统清单（收集箱）与账户中同类型的系统清单合并
+func (im *accountImporter) importList(list *models.List) error {
+	oldID := list.ID
+	if im.isDuplicate("lists", im.lists, oldID) {
+		return nil
+	}
+	if strings.TrimSpace(list.Name) == "" {
+		im.skip("lists", "list %d: name is empty", oldID)
+		return nil
+	}
+
+	if list.IsSystem || list.IsDefault {
+		var existing models.List
+		query := im.tx.Where("user_id = ? AND is_system = ? AND type = ?", im.userID, true, list.Type)
+		if list.IsDefault {
+			query = im.tx.Where("id = ?", im.inboxID)
+		}
+		if err := query.First(&existing).Error; err == nil {
+			im.lists[oldID] = existing.ID
+			im.report.Merged["lists"]++
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		// 账户只有一个默认清单
+		list.IsDefault = false
+	}
+	merged, err := im.mergeDuplicate("lists", im.lists, oldID, "user_id = ? AND name = ? AND is_system = ?", im.userID, list.Name, false)
+	if err != nil || merged {
+		return err
+	}
+
+	folderID, ok := mapRef(im.folders, list.FolderID)
+	if !ok {
+		im.report.warn("list %d: folder %d not found, moved to top level", oldID, *list.FolderID)
+	}
+	list.FolderID = folderID
+	list.UserID = im.userID
+	if err := createImported(im.tx, list); err != nil {
+		return err
+	}
+	im.lists[oldID] = list.ID
+	im.report.Imported["lists"]++
+	return nil
+}
+
+// importTag 导入标签，账户中已有同名同级标签时合并
+func (im *accountImporter) importTag(tag *models.Tag) error {
+	oldID := tag.ID
+	if im.isDuplicate("tags", im.tags, oldID) {
+		return nil
+	}
+	if strings.TrimSpace(tag.Name) == "" {
+		im.skip("tags", "tag %d: name is empty", oldID)
+		return nil
+	}
+
+	parentID, ok := mapRef(im.tags, tag.ParentID)
+	if !ok {
+		im.report.warn("tag %d: parent tag %d not found, moved to top level", oldID, *tag.ParentID)
+	}
+
+	var existing models.Tag
+	query := im.tx.Where("user_id = ? AND name = ?", im.userID, tag.Name)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	if err := query.First(&existing).Error; err == nil {
+		im.tags[oldID] = existing.ID
+		im.report.Merged["tags"]++
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	tag.ParentID = parentID
+	tag.UserID = im.userID
+	tag.Parent, tag.Children = nil, nil
+	if err := createImported(im.tx, tag); err != nil {
+		return err
+	}
+	im.tags[oldID] = tag.ID
+	im.report.Imported["tags"]++
+	return nil
+}
+
+func (im *accountImporter) importTaskSeries(series *models.TaskSeries) error {
+	oldID := series.ID
+	if im.isDuplicate("taskSeries", im.series, oldID) {
+		return nil
+	}
+
+	label := fmt.Sprintf("task series %d", oldID)
+	series.UserID = im.userID
+	series.ListID = im.listOrInbox(label, series.ListID)
+	tagIDs, missing := mapIDs(im.tags, ParseTagIDs(series.TagIDs))
+	if missing > 0 {
+		im.report.warn("%s: %d tags not found", label, missing)
+	}
+	series.TagIDs = FormatTagIDs(tagIDs)
+	merged, err := im.mergeDuplicate("taskSeries", im.series, oldID, "user_id = ? AND list_id = ? AND title = ? AND r_rule = ?",
+		im.userID, series.ListID, series.Title, series.RRule)
+	if err != nil || merged {
+		return err
+	}
+	if err := createImported(im.tx, series); err != nil {
+		return err
+	}
+	im.series[oldID] = series.ID
+	im.report.Imported["taskSeries"]++
+	return nil
+}
+
+// importTask 导入任务及其标签关联，父任务先于子任务导入
+func (im *accountImporter) importTask(backup *BackupTask) error {
+	oldID := backup.ID
+	if im.isDuplicate("tasks", im.tasks, oldID) {
+		return nil
+	}
+	task := backup.Task
+	if strings.TrimSpace(task.Title) == "" {
+		im.skip("tasks", "task %d: title is empty", oldID)
+		return nil
+	}
+
+	label := fmt.Sprintf("task %d", oldID)
+	task.UserID = im.userID
+	task.ListID = im.listOrInbox(label, task.ListID)
+	if task.Priority < 0 || task.Priority > 3 {
+		im.report.warn("%s: invalid priority %d, reset to 0", label, task.Priority)
+		task.Priority = 0
+	}
+	switch task.Status {
+	case "todo", "completed", "abandoned":
+	default:
+		im.report.warn("%s: invalid status %q, reset to todo", label, task.Status)
+		task.Status = "todo"
+	}
+
+	parentID, ok := mapRef(im.tasks, task.ParentID)
+	if !ok {
+		im.report.warn("%s: parent task %d not found, moved to top level", label, *task.ParentID)
+	}
+	task.ParentID = parentID
+	// 原始重复任务可能已删除，此时不再关联
+	task.ParentTaskID, _ = mapRef(im.tasks, task.ParentTaskID)
+	seriesID, ok := mapRef(im.series, task.SeriesID)
+	if !ok {
+		im.report.warn("%s: task series %d not found", label, *task.SeriesID)
+	}
+	task.SeriesID = seriesID
+
+	// 同一清单、同一父任务下标题、截止日期和完成时间都相同的任务视为同一个任务
+	query := "user_id = ? AND list_id = ? AND title = ? AND due_date = ? AND completed_at = ? AND parent_id IS NULL"
+	args := []interface{}{im.userID, task.ListID, task.Title, task.DueDate, task.CompletedAt}
+	if task.ParentID != nil {
+		query = strings.TrimSuffix(query, "IS NULL") + "= ?"
+		args = append(args, *task.ParentID)
+	}
+	merged, err := im.mergeDuplicate("tasks", im.tasks, oldID, query, args...)
+	if err != nil {
+		return err
+	}
+	if merged {
+		im.mergedTasks[im.tasks[oldID]] = true
+		return nil
+	}
+
+	if task.Rank == "" || validateRank(task.Rank) != nil {
+		task.Rank = ""
+		im.rebalanceRanks = true
+	}
+	task.List, task.Tags, task.ParentTask = nil, nil, nil
+	if err := createImported(im.tx, &task); err != nil {
+		return err
+	}
+	im.tasks[oldID] = task.ID
+
+	tagIDs, missing := mapIDs(im.tags, backup.TagIDs)
+	if missing > 0 {
+		im.report.warn("%s: %d tags not found", label, missing)
+	}
+	for _, tagID := range tagIDs {
+		if err := im.tx.Create(&models.TaskTag{TaskID: task.ID, TagID: tagID}).Error; err != nil {
+			return err
+		}
+	}
+	// 备份中没有系列的未完成重复任务（如其他应用的重复任务）创建系列，与新建的重复任务一致
+	if task.Status == "todo" {
+		if err := EnsureTaskSeries(im.tx, &task); err != nil {
+			return err
+		}
+	}
+	im.countCompletion(&task)
+	im.report.Imported["tasks"]++
+	return nil
+}
+
+// countCompletion 开启 CountCompletions 时记录已完成任务的完成日期，导入结束后更新每日统计
+func (im *accountImporter) countCompletion(task *models.Task) {
+	if !im.opts.CountCompletions || task.Status != "completed" || len(task.CompletedAt) < 8 {
+		return
+	}
+	date := task.CompletedAt[:8]
+	day, ok := im.completions[date]
+	if !ok {
+		day = &dailyCompletions{}
+		im.completions[date] = day
+	}
+	day.completed++
+	switch {
+	case task.DueDate == "":
+		day.noDate++
+	case date <= task.DueDate:
+		day.onTime++
+	default:
+		day.overdue++
+	}
+}
+
+// applyCompletions 把导入的已完成任务累加到每日统计，没有当天的统计时创建
+func (im *accountImporter) applyCompletions() error {
+	for date, day := range im.completions {
+		// user_id 和 date 唯一，已删除的统计也要恢复并累加
+		var stats models.Statistics
+		if err := im.tx.Unscoped().Where("user_id = ? AND date = ?", im.userID, date).
+			Attrs(models.Statistics{UserID: im.userID, Date: date}).
+			FirstOrCreate(&stats).Error; err != nil {
+			return err
+		}
+		if err := im.tx.Unscoped().Model(&stats).UpdateColumns(map[string]interface{}{
+			"completed_tasks":         gorm.Expr("completed_tasks + ?", day.completed),
+			"on_time_completed_tasks": gorm.Expr("on_time_completed_tasks + ?", day.onTime),
+			"overdue_completed_tasks": gorm.Expr("overdue_completed_tasks + ?", day.overdue),
+			"no_date_completed_tasks": gorm.Expr("no_date_completed_tasks + ?", day.noDate),
+			"deleted_at":              nil,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *accountImporter) importChecklistItem(item *models.ChecklistItem) error {
+	taskID, ok := im.tasks[item.TaskID]
+	if !ok {
+		im.skip("checklistItems", "checklist item %d: task %d not found", item.ID, item.TaskID)
+		return nil
+	}
+
+	if im.mergedTasks[taskID] {
+		merged, err := im.mergeDuplicate("checklistItems", nil, item.ID, "task_id = ? AND title = ?", taskID, item.Title)
+		if err != nil || merged {
+			return err
+		}
+	}
+
+	item.UserID = im.userID
+	item.TaskID = taskID
+	if err := createImported(im.tx, item); err != nil {
+		return err
+	}
+	im.report.Imported["checklistItems"]++
+	return nil
+}
+
+func (im *accountImporter) importTaskDependencies(dependencies []models.TaskDependency) error {
+	seen := make(map[[2]uint64]bool)
+	for i := range dependencies {
+		dependency := &dependencies[i]
+		taskID, ok := im.tasks[dependency.TaskID]
+		blockedByID, blockedOK := im.tasks[dependency.BlockedByID]
+		if !ok || !blockedOK {
+			im.skip("taskDependencies", "dependency %d: task %d or %d not found", dependency.ID, dependency.TaskID, dependency.BlockedByID)
+			continue
+		}
+		key := [2]uint64{taskID, blockedByID}
+		if seen[key] || taskID == blockedByID {
+			im.skip("taskDependencies", "dependency %d: duplicate or self dependency", dependency.ID)
+			continue
+		}
+		seen[key] = true
+		if im.mergedTasks[taskID] && im.mergedTasks[blockedByID] {
+			merged, err := im.mergeDuplicate("taskDependencies", nil, dependency.ID, "task_id = ? AND blocked_by_id = ?", taskID, blockedByID)
+			if err != nil {
+				return err
+			}
+			if merged {
+				continue
+			}
+		}
+
+		dependency.UserID = im.userID
+		dependency.TaskID = taskID
+		dependency.BlockedByID = blockedByID
+		if err := createImported(im.tx, dependency); err != nil {
+			return err
+		}
+		im.report.Imported["taskDependencies"]++
+	}
+	return nil
+}
+
+func (im *accountImporter) importHabit(habit *models.Habit) error {
+	oldID := habit.ID
+	if im.isDuplicate("habits", im.habits, oldID) {
+		return nil
+	}
+	if strings.TrimSpace(habit.Name) == "" {
+		im.skip("habits", "habit %d: name is empty", oldID)
+		return nil
+	}
+
+	merged, err := im.mergeDuplicate("habits", im.habits, oldID, "user_id = ? AND name = ?", im.userID, habit.Name)
+	if err != nil {
+		return err
+	}
+	if merged {
+		im.mergedHabits[im.habits[oldID]] = true
+		return nil
+	}
+
+	habit.UserID = im.userID
+	if err := createImported(im.tx, habit); err != nil {
+		return err
+	}
+	im.habits[oldID] = habit.ID
+	im.report.Imported["habits"]++
+	return nil
+}
+
+func (im *accountImporter) importHabitRecord(record *models.HabitRecord) error {
+	habitID, ok := im.habits[record.HabitID]
+	if !ok {
+		im.skip("habitRecords", "habit record %d: habit %d not found", record.ID, record.HabitID)
+		return nil
+	}
+
+	if im.mergedHabits[habitID] {
+		merged, err := im.mergeDuplicate("habitRecords", nil, record.ID, "habit_id = ? AND check_date = ?", habitID, record.CheckDate)
+		if err != nil || merged {
+			return err
+		}
+	}
+
+	record.HabitID = habitID
+	if err := createImported(im.tx, record); err != nil {
+		return err
+	}
+	im.report.Imported["habitRecords"]++
+	return nil
+}
+
+// importPomodoro 导入番茄记录，任务不存在时保留记录但不再关联任务
+func (im *accountImporter) importPomodoro(pomodoro *models.Pomodoro) error {
+	merged, err := im.mergeDuplicate("pomodoros", nil, pomodoro.ID, "user_id = ? AND start_time = ?", im.userID, pomodoro.StartTime)
+	if err != nil || merged {
+		return err
+	}
+
+	pomodoro.UserID = im.userID
+	pomodoro.TaskID, _ = mapRef(im.tasks, pomodoro.TaskID)
+	if err := createImported(im.tx, pomodoro); err != nil {
+		return err
+	}
+	im.report.Imported["pomodoros"]++
+	return nil
+}
+
+func (im *accountImporter) importCountdown(countdown *models.Countdown) error {
+	if strings.TrimSpace(countdown.Title) == "" {
+		im.skip("countdowns", "countdown %d: title is empty", countdown.ID)
+		return nil
+	}
+
+	merged, err := im.mergeDuplicate("countdowns", nil, countdown.ID, "user_id = ? AND title = ? AND target_date = ?",
+		im.userID, countdown.Title, countdown.TargetDate)
+	if err != nil || merged {
+		return err
+	}
+
+	countdown.UserID = im.userID
+	if err := createImported(im.tx, countdown); err != nil {
+		return err
+	}
+	im.report.Imported["countdowns"]++
+	return nil
+}
+
+// importFilter 导入过滤器，条件中的清单和标签换成新 ID
+func (im *accountImporter) importFilter(filter *models.Filter) error {
+	oldID := filter.ID
+	if im.isDuplicate("filters", im.filters, oldID) {
+		return nil
+	}
+	if strings.TrimSpace(filter.Name) == "" {
+		im.skip("filters", "filter %d: name is empty", oldID)
+		return nil
+	}
+	merged, err := im.mergeDuplicate("filters", im.filters, oldID, "user_id = ? AND name = ?", im.userID, filter.Name)
+	if err != nil || merged {
+		return err
+	}
+
+	var config models.FilterConfig
+	if filter.FilterConfig != "" {
+		if err := json.Unmarshal([]byte(filter.FilterConfig), &config); err != nil {
+			im.skip("filters", "filter %d: invalid filter config", oldID)
+			return nil
+		}
+	}
+	if err := MigrateFilterConfig(&config); err != nil {
+		im.skip("filters", "filter %d: %v", oldID, err)
+		return nil
+	}
+	if config.Rules != nil {
+		im.remapFilterRule(oldID, config.Rules)
+	}
+	if err := NormalizeFilterConfig(&config); err != nil {
+		im.skip("filters", "filter %d: %v", oldID, err)
+		return nil
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	filter.FilterConfig = string(data)
+	filter.UserID = im.userID
+	if err := createImported(im.tx, filter); err != nil {
+		return err
+	}
+	im.filters[oldID] = filter.ID
+	im.report.Imported["filters"]++
+	return nil
+}
+
+// remapFilterRule 把规则树中按 ID 匹配清单和标签的条件换成新 ID。
+// 找不到的 ID 被去掉，全部找不到时保留一个不存在的 ID 0，使条件仍然匹配不到任何清单或标签
+func (im *accountImporter) remapFilterRule(filterID uint64, rule *models.FilterRule) {
+	if rule.IsGroup() {
+		for i := range rule.Rules {
+			im.remapFilterRule(filterID, &rule.Rules[i])
+		}
+		return
+	}
+	if rule.Operator != "in" && rule.Operator != "notIn" {
+		return
+	}
+
+	var mapping map[uint64]uint64
+	switch rule.Field {
+	case "list":
+		mapping = im.lists
+	case "tag":
+		mapping = im.tags
+	default:
+		return
+	}
+	var ids []uint64
+	if err := json.Unmarshal(rule.Value, &ids); err != nil {
+		return
+	}
+	mapped, missing := mapIDs(mapping, ids)
+	if missing > 0 {
+		im.report.warn("filter %d: %d %ss in conditions not found", filterID, missing, rule.Field)
+	}
+	if len(mapped) == 0 {
+		mapped = []uint64{0}
+	}
+	rule.Value, _ = json.Marshal(mapped)
+}
+
+// importViewConfig 导入视图配置，账户中已有同一清单、过滤器或预设视图的配置时保留已有配置
+func (im *accountImporter) importViewConfig(config *models.ViewConfig) error {
+	entityID := config.EntityID
+	ok := true
+	switch config.EntityType {
+	case "list":
+		entityID, ok = im.lists[config.EntityID]
+	case "filter":
+		entityID, ok = im.filters[config.EntityID]
+	case "preset":
+	default:
+		ok = false
+	}
+	if !ok {
+		im.skip("viewConfigs", "view config %d: %s %d not found", config.ID, config.EntityType, config.EntityID)
+		return nil
+	}
+
+	var count int64
+	if err := im.tx.Model(&models.ViewConfig{}).
+		Where("user_id = ? AND entity_type = ? AND entity_id = ?", im.userID, config.EntityType, entityID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		im.report.Merged["viewConfigs"]++
+		return nil
+	}
+
+	config.UserID = im.userID
+	config.EntityID = entityID
+	if err := createImported(im.tx, config); err != nil {
+		return err
+	}
+	im.report.Imported["viewConfigs"]++
+	return nil
+}
+
+// importReminder 导入提醒。尚未投递且已经过期的提醒不再导入，避免导入后立即集中推送
+func (im *accountImporter) importReminder(reminder *models.Reminder) error {
+	var entityID uint64
+	ok := false
+	switch reminder.EntityType {
+	case "task":
+		entityID, ok = im.tasks[reminder.EntityID]
+	case "habit":
+		entityID, ok = im.habits[reminder.EntityID]
+	}
+	if !ok {
+		im.skip("reminders", "reminder %d: %s %d not found", reminder.ID, reminder.EntityType, reminder.EntityID)
+		return nil
+	}
+
+	now := utils.FormatDateTime(im.now)
+	if (reminder.Status == ReminderStatusPending && reminder.ReminderTime <= now) ||
+		(reminder.Status == ReminderStatusFailed && reminder.NextRetryAt <= now) {
+		im.report.Skipped["reminders"]++
+		return nil
+	}
+
+	if (reminder.EntityType == "task" && im.mergedTasks[entityID]) || (reminder.EntityType == "habit" && im.mergedHabits[entityID]) {
+		merged, err := im.mergeDuplicate("reminders", nil, reminder.ID,
+			"user_id = ? AND entity_type = ? AND entity_id = ? AND reminder_type = ? AND reminder_time = ?",
+			im.userID, reminder.EntityType, entityID, reminder.ReminderType, reminder.ReminderTime)
+		if err != nil || merged {
+			return err
+		}
+	}
+
+	reminder.UserID = im.userID
+	reminder.EntityID = entityID
+	if err := createImported(im.tx, reminder); err != nil {
+		return err
+	}
+	im.report.Imported["reminders"]++
+	return nil
+}
+
+// importStatistics 导入每日统计，账户中已有同一天的统计时保留已有数据
+func (im *accountImporter) importStatistics(statistics *models.Statistics) error {
+	var count int64
+	if err := im.tx.Unscoped().Model(&models.Statistics{}).
+		Where("user_id = ? AND date = ?", im.userID, statistics.Date).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		im.report.Merged["statistics"]++
+		return nil
+	}
+
+	statistics.UserID = im.userID
+	if err := createImported(im.tx, statistics); err != nil {
+		return err
+	}
+	im.report.Imported["statistics"]++
+	return nil
+}
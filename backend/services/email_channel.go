@@ -0,0 +1,293 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SMTP 加密方式
+const (
+	SMTPTLSNone     = "none"     // 明文（本地调试用）
+	SMTPTLSStartTLS = "starttls" // 连接后升级为 TLS
+	SMTPTLSImplicit = "tls"      // 直接建立 TLS 连接（通常为 465 端口）
+)
+
+// SMTPConfig SMTP 服务器配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	TLSMode  string // none, starttls, tls
+}
+
+// Enabled 是否配置了 SMTP 服务器
+func (c SMTPConfig) Enabled() bool {
+	return c.Host != "" && c.From != ""
+}
+
+// EmailChannel 邮件提醒渠道
+type EmailChannel struct {
+	db  *gorm.DB
+	cfg SMTPConfig
+}
+
+// NewEmailChannel 创建邮件提醒渠道
+func NewEmailChannel(db *gorm.DB, cfg SMTPConfig) *EmailChannel {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.TLSMode == "" {
+		cfg.TLSMode = SMTPTLSStartTLS
+	}
+	return &EmailChannel{db: db, cfg: cfg}
+}
+
+// Type 渠道类型
+func (ch *EmailChannel) Type() string {
+	return "email"
+}
+
+// Send 发送邮件提醒
+func (ch *EmailChannel) Send(ctx context.Context, reminder *models.Reminder) error {
+	var settings models.UserSettings
+	if err := ch.db.Where("user_id = ?", reminder.UserID).First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return Permanent(errors.New("user settings not found"))
+		}
+		return err
+	}
+	if !settings.EmailEnabled || settings.EmailAddress == "" {
+		return Permanent(errors.New("email notification is disabled"))
+	}
+
+	msg, err := BuildReminderMessage(ch.db, reminder)
+	if err != nil {
+		return err
+	}
+
+	if err := ch.SendMessage(ctx, settings.EmailAddress, msg); err != nil {
+		utils.Logger.Warn("Failed to send reminder email",
+			zap.Uint64("reminderID", reminder.ID),
+			zap.String("to", settings.EmailAddress),
+			zap.Error(err))
+		return err
+	}
+
+	utils.LogInfo("Reminder email sent",
+		zap.Uint64("reminderID", reminder.ID),
+		zap.String("to", settings.EmailAddress))
+	return nil
+}
+
+// SendMessage 渲染并发送一封提醒邮件
+func (ch *EmailChannel) SendMessage(ctx context.Context, to string, msg *ReminderMessage) error {
+	if !ch.cfg.Enabled() {
+		return Permanent(errors.New("smtp server is not configured"))
+	}
+
+	body, err := renderReminderEmail(ch.cfg.From, to, msg)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	return classifySMTPError(ch.sendMail(ctx, to, body))
+}
+
+// sendMail 建立 SMTP 连接并投递邮件
+func (ch *EmailChannel) sendMail(ctx context.Context, to string, body []byte) error {
+	addr := net.JoinHostPort(ch.cfg.Host, strconv.Itoa(ch.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: ch.cfg.Host}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if ch.cfg.TLSMode == SMTPTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 整个会话受 ctx 超时控制
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, ch.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ch.cfg.TLSMode == SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return Permanent(errors.New("smtp server does not support STARTTLS"))
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if ch.cfg.Username != "" {
+		auth := smtp.PlainAuth("", ch.cfg.Username, ch.cfg.Password, ch.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(extractAddress(ch.cfg.From)); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// classifySMTPError 5xx 响应（如收件人不存在、认证失败）视为不可重试
+func classifySMTPError(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+// extractAddress 从 "名称 <addr>" 格式中提取邮箱地址
+func extractAddress(from string) string {
+	if start := strings.LastIndex(from, "<"); start >= 0 {
+		if end := strings.LastIndex(from, ">"); end > start {
+			return from[start+1 : end]
+		}
+	}
+	return from
+}
+
+var emailSubjectTemplate = texttemplate.Must(texttemplate.New("subject").Parse(
+	`【On The Way】{{if eq .EntityType "habit"}}习惯打卡提醒{{else}}任务提醒{{end}}：{{.Title}}`))
+
+var emailTextTemplate = texttemplate.Must(texttemplate.New("text").Parse(`{{if .Icon}}{{.Icon}} {{end}}{{.Title}}
+{{if .Description}}
+{{.Description}}
+{{end}}
+{{- if .DueDate}}
+截止时间：{{.DueDate}}{{if .DueTime}} {{.DueTime}}{{end}}{{end}}
+{{- if .ListName}}
+所属清单：{{.ListName}}{{end}}
+{{- if eq .EntityType "task"}}
+优先级：{{.PriorityLabel}}{{end}}
+提醒时间：{{.ReminderTime}}
+`))
+
+var emailHTMLTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, 'PingFang SC', 'Microsoft YaHei', sans-serif; color: #1f2937;">
+  <div style="max-width: 560px; margin: 0 auto; padding: 24px; border: 1px solid #e5e7eb; border-radius: 8px;">
+    <h2 style="margin: 0 0 12px;">{{if .Icon}}{{.Icon}} {{end}}{{.Title}}</h2>
+    {{if .Description}}<p style="white-space: pre-wrap; color: #4b5563;">{{.Description}}</p>{{end}}
+    <table style="font-size: 14px; color: #374151;">
+      {{if .DueDate}}<tr><td style="padding: 4px 12px 4px 0;">截止时间</td><td>{{.DueDate}}{{if .DueTime}} {{.DueTime}}{{end}}</td></tr>{{end}}
+      {{if .ListName}}<tr><td style="padding: 4px 12px 4px 0;">所属清单</td><td>{{.ListName}}</td></tr>{{end}}
+      {{if eq .EntityType "task"}}<tr><td style="padding: 4px 12px 4px 0;">优先级</td><td>{{.PriorityLabel}}</td></tr>{{end}}
+      <tr><td style="padding: 4px 12px 4px 0;">提醒时间</td><td>{{.ReminderTime}}</td></tr>
+    </table>
+  </div>
+</body>
+</html>
+`))
+
+// renderReminderEmail 渲染包含纯文本和 HTML 两种格式的邮件
+func renderReminderEmail(from, to string, msg *ReminderMessage) ([]byte, error) {
+	var subject, text, html bytes.Buffer
+	if err := emailSubjectTemplate.Execute(&subject, msg); err != nil {
+		return nil, err
+	}
+	if err := emailTextTemplate.Execute(&text, msg); err != nil {
+		return nil, err
+	}
+	if err := emailHTMLTemplate.Execute(&html, msg); err != nil {
+		return nil, err
+	}
+
+	boundary := randomBoundary()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject.String()))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain", text.Bytes()},
+		{"text/html", html.Bytes()},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+func randomBoundary() string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
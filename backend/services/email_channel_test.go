@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"on-the-way/backend/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSink 进程内的 SMTP 服务器，记录收到的邮件
+type smtpSink struct {
+	listener net.Listener
+	rcptCode int // RCPT TO 的响应码，0 表示接受
+	done     chan struct{}
+
+	from string
+	rcpt []string
+	data string
+}
+
+func newSMTPSink(t *testing.T, rcptCode int) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{listener: listener, rcptCode: rcptCode, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+	go sink.serve()
+	return sink
+}
+
+func (s *smtpSink) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// serve 处理一次 SMTP 会话
+func (s *smtpSink) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP sink")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			s.from = arg
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rcptCode != 0 {
+				tp.PrintfLine("%d mailbox unavailable", s.rcptCode)
+				continue
+			}
+			s.rcpt = append(s.rcpt, arg)
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// wait 等待会话结束后再读取记录，避免数据竞争
+func (s *smtpSink) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("smtp session did not finish")
+	}
+}
+
+func newTestEmailChannel(t *testing.T, sink *smtpSink) (*EmailChannel, *models.Reminder) {
+	t.Helper()
+	db := newTestDB(t, &models.UserSettings{}, &models.List{}, &models.Task{}, &models.Reminder{})
+	if err := db.Create(&models.UserSettings{UserID: 1, EmailEnabled: true, EmailAddress: "user@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	list := models.List{UserID: 1, Name: "工作", Type: "custom"}
+	if err := db.Create(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	task := models.Task{UserID: 1, ListID: list.ID, Title: "提交周报", Description: "本周进展和下周计划", DueDate: "20251105", DueTime: "18:00", Priority: 3, Status: "todo"}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	reminder := &models.Reminder{UserID: 1, EntityType: "task", EntityID: task.ID, ReminderTime: "20251105 17:30", ReminderType: "email", Status: ReminderStatusPending}
+	if err := db.Create(reminder).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	channel := NewEmailChannel(db, SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    sink.port(),
+		From:    "On The Way <noreply@example.com>",
+		TLSMode: SMTPTLSNone,
+	})
+	return channel, reminder
+}
+
+func TestEmailChannelSend(t *testing.T) {
+	sink := newSMTPSink(t, 0)
+	channel, reminder := newTestEmailChannel(t, sink)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := channel.Send(ctx, reminder); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sink.wait(t)
+
+	if sink.from != "FROM:<noreply@example.com>" {
+		t.Errorf("MAIL %s, want FROM:<noreply@example.com>", sink.from)
+	}
+	if len(sink.rcpt) != 1 || sink.rcpt[0] != "TO:<user@example.com>" {
+		t.Errorf("RCPT %v, want [TO:<user@example.com>]", sink.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(sink.data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if to := msg.Header.Get("To"); to != "user@example.com" {
+		t.Errorf("To = %q, want user@example.com", to)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+	if want := "【On The Way】任务提醒：提交周报"; subject != want {
+		t.Errorf("Subject = %q, want %q", subject, want)
+	}
+
+	// 逐个读取 multipart 内容，quoted-printable 会自动解码
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+	parts := make(map[string]string)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(content)
+	}
+	for _, contentType := range []string{"text/plain", "text/html"} {
+		body, ok := parts[contentType]
+		if !ok {
+			t.Errorf("missing %s part", contentType)
+			continue
+		}
+		for _, want := range []string{"提交周报", "本周进展和下周计划", "2025-11-05", "18:00", "工作", "高优先级", "2025-11-05 17:30"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s part does not contain %q:\n%s", contentType, want, body)
+			}
+		}
+	}
+}
+
+func TestEmailChannelSendClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		rcptCode  int
+		permanent bool
+	}{
+		{name: "5xx reply is permanent", rcptCode: 550, permanent: true},
+		{name: "4xx reply is retried", rcptCode: 451, permanent: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newSMTPSink(t, tt.rcptCode)
+			channel, reminder := newTestEmailChannel(t, sink)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := channel.Send(ctx, reminder)
+			if err == nil {
+				t.Fatal("Send should fail")
+			}
+			if IsPermanent(err) != tt.permanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, IsPermanent(err), tt.permanent)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// 实时事件类型
+const (
+	EventReminderFired     = "reminder.fired"     // 提醒到期
+	EventReminderDismissed = "reminder.dismissed" // 提醒已在某台设备上确认、延迟或删除
+	EventTaskChanged       = "task.changed"       // 任务新增、修改、删除、完成等
+	EventPomodoroState     = "pomodoro.state"     // 番茄钟开始、结束
+	EventResync            = "resync"             // 错过的事件超出重放窗口或导入了备份，客户端需要全量刷新
+)
+
+// Event 推送给客户端的实时事件
+type Event struct {
+	ID        uint64      `json:"id"`
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// EventHubOptions 事件中心配置
+type EventHubOptions struct {
+	HistorySize int           // 每个用户保留的最近事件数，用于断线重放
+	HistoryTTL  time.Duration // 重放窗口时长
+	BufferSize  int           // 每个订阅的缓冲区大小，写满后断开慢客户端
+}
+
+// EventHub 进程内发布/订阅中心，按用户分发事件到所有已连接的设备
+type EventHub struct {
+	mu          sync.Mutex
+	opts        EventHubOptions
+	nextID      uint64
+	subscribers map[uint64]map[*Subscription]struct{}
+	history     map[uint64][]Event
+	closed      bool
+}
+
+// Subscription 单个连接的订阅
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	userID uint64
+	hub    *EventHub
+	once   sync.Once
+}
+
+// NewEventHub 创建事件中心
+func NewEventHub(opts EventHubOptions) *EventHub {
+	if opts.HistorySize <= 0 {
+		opts.HistorySize = 200
+	}
+	if opts.HistoryTTL <= 0 {
+		opts.HistoryTTL = 10 * time.Minute
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64
+	}
+
+	return &EventHub{
+		opts: opts,
+		// 以启动时间作为起始ID，服务重启后ID仍然递增，客户端的 Last-Event-ID 不会误判
+		nextID:      uint64(time.Now().UnixNano()),
+		subscribers: make(map[uint64]map[*Subscription]struct{}),
+		history:     make(map[uint64][]Event),
+	}
+}
+
+// Publish 向用户的所有连接发布事件，hub 为 nil 时忽略
+func (h *EventHub) Publish(userID uint64, eventType string, data interface{}) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.nextID++
+	event := Event{
+		ID:        h.nextID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+
+	// 记录历史用于重放
+	history := append(h.pruneHistory(userID), event)
+	if len(history) > h.opts.HistorySize {
+		history = history[len(history)-h.opts.HistorySize:]
+	}
+	h.history[userID] = history
+
+	for sub := range h.subscribers[userID] {
+		select {
+		case sub.ch <- event:
+		default:
+			// 客户端消费过慢，断开连接，由客户端携带 Last-Event-ID 重连后重放
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// Subscribe 订阅用户事件。lastEventID 大于 0 时返回其之后的历史事件；
+// complete 为 false 表示部分事件已超出重放窗口
+func (h *EventHub) Subscribe(userID uint64, lastEventID uint64) (sub *Subscription, replay []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, h.opts.BufferSize)
+	sub = &Subscription{C: ch, ch: ch, userID: userID, hub: h}
+
+	if h.closed {
+		close(ch)
+		return sub, nil, true
+	}
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+
+	complete = true
+	if lastEventID > 0 {
+		history := h.pruneHistory(userID)
+		h.history[userID] = history
+
+		// 最早可重放的事件之前还有未收到的事件
+		if len(history) > 0 && history[0].ID > lastEventID+1 {
+			complete = false
+		}
+		if len(history) == 0 && lastEventID < h.nextID {
+			complete = false
+		}
+		for _, event := range history {
+			if event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	return sub, replay, complete
+}
+
+// HasSubscribers 用户当前是否有在线连接
+func (h *EventHub) HasSubscribers(userID uint64) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers[userID]) > 0
+}
+
+// Close 关闭所有订阅，用于服务退出
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
+
+// removeLocked 移除订阅并关闭通道，调用方需持有锁
+func (h *EventHub) removeLocked(sub *Subscription) {
+	sub.once.Do(func() {
+		if subs, ok := h.subscribers[sub.userID]; ok {
+			delete(subs, sub)
+			if len(subs) == 0 {
+				delete(h.subscribers, sub.userID)
+			}
+		}
+		close(sub.ch)
+	})
+}
+
+// pruneHistory 丢弃超出重放窗口的历史事件，调用方需持有锁
+func (h *EventHub) pruneHistory(userID uint64) []Event {
+	history := h.history[userID]
+	cutoff := time.Now().Add(-h.opts.HistoryTTL)
+
+	start := 0
+	for start < len(history) && history[start].CreatedAt.Before(cutoff) {
+		start++
+	}
+	return history[start:]
+}
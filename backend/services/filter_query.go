@@ -0,0 +1,120 @@
+package services
+
+import (
+	"on-the-way/backend/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApplyFilterConfig 将过滤器配置编译为 tasks 表上的查询条件，today 为计算 today/tomorrow/week/overdue 等日期的基准
+// 旧版扁平配置和查询语句会先转换为规则树；规则中没有 status 条件时不处理任务状态，由调用方决定
+func ApplyFilterConfig(query *gorm.DB, userID uint64, config *models.FilterConfig, today time.Time) (*gorm.DB, error) {
+	query = query.Where("tasks.user_id = ?", userID)
+	if config == nil {
+		return query, nil
+	}
+
+	normalized := *config
+	if err := resolveFilterRules(&normalized); err != nil {
+		return nil, err
+	}
+	if normalized.Rules == nil {
+		return query, nil
+	}
+	return ApplyFilterRule(query, userID, normalized.Rules, today)
+}
+
+// ApplyFilterRule 校验规则树并编译为 tasks 表上的查询条件（不含 user_id 条件）
+func ApplyFilterRule(query *gorm.DB, userID uint64, rule *models.FilterRule, today time.Time) (*gorm.DB, error) {
+	return applyFilterRule(query, userID, rule, today, false)
+}
+
+// ApplySearchRule 与 ApplyFilterRule 相同，但关键词通过全文索引匹配，调用前需先 SyncSearchIndex
+func ApplySearchRule(query *gorm.DB, userID uint64, rule *models.FilterRule, today time.Time) (*gorm.DB, error) {
+	return applyFilterRule(query, userID, rule, today, true)
+}
+
+func applyFilterRule(query *gorm.DB, userID uint64, rule *models.FilterRule, today time.Time, useIndex bool) (*gorm.DB, error) {
+	if err := ValidateFilterRule(rule); err != nil {
+		return nil, err
+	}
+
+	compiler := &filterRuleCompiler{
+		db:       query.Session(&gorm.Session{NewDB: true}),
+		userID:   userID,
+		today:    today,
+		useIndex: useIndex,
+	}
+	sql, args, err := compiler.compile(rule)
+	if err != nil {
+		return nil, err
+	}
+	if sql != "" {
+		query = query.Where(sql, args...)
+	}
+
+	return query, nil
+}
+
+// ApplyTaskSort 按视图配置排序，与前端 sortTasks 的规则一致：
+// time 按截止日期和时间（无日期排在最后），title 按标题，tag 按标签名（无标签排在最后），priority 按优先级从高到低；
+// desc 将整体顺序反转
+func ApplyTaskSort(query *gorm.DB, sortBy, sortOrder string) *gorm.DB {
+	asc, desc := "ASC", "DESC"
+	if sortOrder == "desc" {
+		asc, desc = desc, asc
+	}
+
+	switch sortBy {
+	case "title":
+		query = query.Order("tasks.title " + asc)
+	case "tag":
+		tagName := "(SELECT MIN(tags.name) FROM task_tags JOIN tags ON tags.id = task_tags.tag_id AND tags.deleted_at IS NULL WHERE task_tags.task_id = tasks.id)"
+		query = query.Order(tagName + " IS NULL " + asc).Order(tagName + " " + asc)
+	case "priority":
+		query = query.Order("tasks.priority " + desc)
+	default:
+		query = query.Order("(tasks.due_date = '' OR tasks.due_date IS NULL) " + asc).
+			Order("tasks.due_date " + asc).
+			Order("tasks.due_time " + asc)
+	}
+
+	return query.Order("tasks.rank ASC").Order("tasks.id ASC")
+}
+
+// ExpandTagIDs 返回标签及其所有子标签的ID
+func ExpandTagIDs(db *gorm.DB, userID uint64, tagIDs []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(tagIDs))
+	result := make([]uint64, 0, len(tagIDs))
+	for _, id := range tagIDs {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+
+	current := result
+	for len(current) > 0 {
+		var children []uint64
+		db.Model(&models.Tag{}).Where("parent_id IN ? AND user_id = ?", current, userID).Pluck("id", &children)
+
+		var next []uint64
+		for _, id := range children {
+			if !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+				next = append(next, id)
+			}
+		}
+		current = next
+	}
+	return result
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+	return replacer.Replace(value)
+}
@@ -0,0 +1,677 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 规则树的限制，防止过深或过大的规则拖慢查询
+const (
+	maxFilterRuleDepth = 5
+	maxFilterRuleCount = 100
+)
+
+// 规则分组方式
+const (
+	FilterMatchAll  = "all"  // 全部满足
+	FilterMatchAny  = "any"  // 任一满足
+	FilterMatchNone = "none" // 都不满足
+)
+
+// filterFieldOperators 各字段支持的运算符
+var filterFieldOperators = map[string][]string{
+	"list":          {"in", "notIn", "eq", "neq"},
+	"tag":           {"in", "notIn", "eq", "neq", "isEmpty", "isNotEmpty"},
+	"priority":      {"in", "notIn", "eq", "neq", "gte", "lte"},
+	"status":        {"in", "notIn", "eq", "neq"},
+	"dueDate":       {"eq", "before", "after", "between", "preset", "isEmpty", "isNotEmpty"},
+	"createdDate":   {"eq", "before", "after", "between", "preset"},
+	"completedDate": {"eq", "before", "after", "between", "preset", "isEmpty", "isNotEmpty"},
+	"keyword":       {"contains", "notContains"},
+	"hasReminder":   {"eq"},
+	"isRecurring":   {"eq"},
+	"taskType":      {"eq", "neq"},
+}
+
+// MigrateFilterConfig 将旧版扁平配置转换为规则树（版本 2），已是新版时不做处理
+func MigrateFilterConfig(config *models.FilterConfig) error {
+	if config.Version >= models.FilterConfigVersion {
+		return nil
+	}
+
+	root := &models.FilterRule{Match: FilterMatchAll}
+	if len(config.ListIDs) > 0 {
+		root.Rules = append(root.Rules, filterLeaf("list", "in", config.ListIDs))
+	}
+	if len(config.TagIDs) > 0 {
+		root.Rules = append(root.Rules, filterLeaf("tag", "in", config.TagIDs))
+	}
+	switch config.DateType {
+	case "today", "tomorrow", "week", "overdue":
+		root.Rules = append(root.Rules, filterLeaf("dueDate", "preset", config.DateType))
+	case "noDate":
+		root.Rules = append(root.Rules, filterLeaf("dueDate", "isEmpty", nil))
+	case "custom":
+		var dateRange [2]string
+		if config.DateRange != nil {
+			dateRange = [2]string{config.DateRange.Start, config.DateRange.End}
+		}
+		root.Rules = append(root.Rules, filterLeaf("dueDate", "between", dateRange))
+	case "", "all":
+	default:
+		return fmt.Errorf("unsupported dateType %q", config.DateType)
+	}
+	if len(config.Priorities) > 0 {
+		root.Rules = append(root.Rules, filterLeaf("priority", "in", config.Priorities))
+	}
+	if keyword := strings.TrimSpace(config.ContentKeyword); keyword != "" {
+		root.Rules = append(root.Rules, filterLeaf("keyword", "contains", keyword))
+	}
+	switch config.TaskType {
+	case "", "all":
+	case "task", "note":
+		root.Rules = append(root.Rules, filterLeaf("taskType", "eq", config.TaskType))
+	default:
+		return fmt.Errorf("unsupported taskType %q", config.TaskType)
+	}
+
+	config.Version = models.FilterConfigVersion
+	config.Rules = root
+	return nil
+}
+
+// NormalizeFilterConfig 迁移并校验过滤器配置，并按规则树重新生成扁平字段（无法表达时清空）
+func NormalizeFilterConfig(config *models.FilterConfig) error {
+	if err := resolveFilterRules(config); err != nil {
+		return err
+	}
+	if config.Rules == nil {
+		config.Rules = &models.FilterRule{Match: FilterMatchAll}
+	}
+	if err := ValidateFilterRule(config.Rules); err != nil {
+		return err
+	}
+
+	rules := config.Rules
+	flat, ok := flattenFilterRules(rules)
+	if !ok {
+		flat = models.FilterConfig{}
+	}
+	flat.Version = models.FilterConfigVersion
+	flat.Rules = rules
+	flat.Query = strings.TrimSpace(config.Query)
+	*config = flat
+	return nil
+}
+
+// resolveFilterRules 迁移旧版配置；设置了查询语句时规则树由查询语句解析生成
+func resolveFilterRules(config *models.FilterConfig) error {
+	if err := MigrateFilterConfig(config); err != nil {
+		return err
+	}
+	if strings.TrimSpace(config.Query) != "" {
+		query, err := ParseSearchQuery(config.Query)
+		if err != nil {
+			return err
+		}
+		config.Rules = query.Rules
+	}
+	return nil
+}
+
+// ValidateFilterRule 校验规则树的结构、字段、运算符和值
+func ValidateFilterRule(rule *models.FilterRule) error {
+	count := 0
+	return validateFilterRule(rule, 1, &count, true)
+}
+
+func validateFilterRule(rule *models.FilterRule, depth int, count *int, root bool) error {
+	*count++
+	if *count > maxFilterRuleCount {
+		return fmt.Errorf("too many rules (max %d)", maxFilterRuleCount)
+	}
+
+	if rule.IsGroup() {
+		if depth > maxFilterRuleDepth {
+			return fmt.Errorf("rules nested too deeply (max %d levels)", maxFilterRuleDepth)
+		}
+		if rule.Match != FilterMatchAll && rule.Match != FilterMatchAny && rule.Match != FilterMatchNone {
+			return fmt.Errorf("unsupported match %q", rule.Match)
+		}
+		if rule.Field != "" || rule.Operator != "" {
+			return fmt.Errorf("a group cannot have field or operator")
+		}
+		// 只有根分组可以为空（匹配全部任务）
+		if len(rule.Rules) == 0 && !root {
+			return fmt.Errorf("group must contain at least one rule")
+		}
+		for i := range rule.Rules {
+			if err := validateFilterRule(&rule.Rules[i], depth+1, count, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(rule.Rules) > 0 {
+		return fmt.Errorf("rule %q has nested rules but no match", rule.Field)
+	}
+	operators, ok := filterFieldOperators[rule.Field]
+	if !ok {
+		return fmt.Errorf("unsupported field %q", rule.Field)
+	}
+	if !containsString(operators, rule.Operator) {
+		return fmt.Errorf("unsupported operator %q for field %q", rule.Operator, rule.Field)
+	}
+
+	// 值的校验与编译共用同一套解析逻辑
+	compiler := &filterRuleCompiler{today: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), validateOnly: true}
+	if _, _, err := compiler.compileLeaf(rule); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FilterConfigUsesField 规则树中是否用到了某个字段（如调用方据此决定是否默认只查待办任务）
+func FilterConfigUsesField(config *models.FilterConfig, field string) bool {
+	if config == nil {
+		return false
+	}
+	migrated := *config
+	if err := resolveFilterRules(&migrated); err != nil {
+		return false
+	}
+	return filterRuleUsesField(migrated.Rules, field)
+}
+
+func filterRuleUsesField(rule *models.FilterRule, field string) bool {
+	if rule == nil {
+		return false
+	}
+	if rule.Field == field {
+		return true
+	}
+	for i := range rule.Rules {
+		if filterRuleUsesField(&rule.Rules[i], field) {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateStoredFilterConfigs 将数据库中旧版扁平格式的过滤器配置迁移为规则树
+func MigrateStoredFilterConfigs(db *gorm.DB) error {
+	var filters []models.Filter
+	if err := db.Where("filter_config NOT LIKE ?", `%"version":2%`).Find(&filters).Error; err != nil {
+		return err
+	}
+
+	migrated := 0
+	for _, filter := range filters {
+		var config models.FilterConfig
+		if filter.FilterConfig != "" {
+			if err := json.Unmarshal([]byte(filter.FilterConfig), &config); err != nil {
+				utils.LogError("Skipping filter with invalid config", zap.Uint64("filterID", filter.ID), zap.Error(err))
+				continue
+			}
+		}
+		if config.Version >= models.FilterConfigVersion {
+			continue
+		}
+		if err := NormalizeFilterConfig(&config); err != nil {
+			utils.LogError("Skipping filter with invalid config", zap.Uint64("filterID", filter.ID), zap.Error(err))
+			continue
+		}
+
+		data, _ := json.Marshal(config)
+		if err := db.Model(&models.Filter{}).Where("id = ?", filter.ID).UpdateColumn("filter_config", string(data)).Error; err != nil {
+			return err
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		utils.LogInfo("Migrated filter configs to rule trees", zap.Int("count", migrated))
+	}
+	return nil
+}
+
+// filterLeaf 构造条件节点
+func filterLeaf(field, operator string, value interface{}) models.FilterRule {
+	rule := models.FilterRule{Field: field, Operator: operator}
+	if value != nil {
+		rule.Value, _ = json.Marshal(value)
+	}
+	return rule
+}
+
+// flattenFilterRules 规则树能用旧版扁平字段表达时返回对应的扁平配置
+func flattenFilterRules(root *models.FilterRule) (models.FilterConfig, bool) {
+	var flat models.FilterConfig
+	if root.Match != FilterMatchAll {
+		return flat, false
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range root.Rules {
+		if rule.IsGroup() || seen[rule.Field] {
+			return flat, false
+		}
+		seen[rule.Field] = true
+
+		var err error
+		switch {
+		case rule.Field == "list" && rule.Operator == "in":
+			err = json.Unmarshal(rule.Value, &flat.ListIDs)
+		case rule.Field == "tag" && rule.Operator == "in":
+			err = json.Unmarshal(rule.Value, &flat.TagIDs)
+		case rule.Field == "priority" && rule.Operator == "in":
+			err = json.Unmarshal(rule.Value, &flat.Priorities)
+		case rule.Field == "keyword" && rule.Operator == "contains":
+			err = json.Unmarshal(rule.Value, &flat.ContentKeyword)
+		case rule.Field == "taskType" && rule.Operator == "eq":
+			err = json.Unmarshal(rule.Value, &flat.TaskType)
+		case rule.Field == "dueDate" && rule.Operator == "preset":
+			err = json.Unmarshal(rule.Value, &flat.DateType)
+			if flat.DateType != "today" && flat.DateType != "tomorrow" && flat.DateType != "week" && flat.DateType != "overdue" {
+				return flat, false
+			}
+		case rule.Field == "dueDate" && rule.Operator == "isEmpty":
+			flat.DateType = "noDate"
+		case rule.Field == "dueDate" && rule.Operator == "between":
+			var dateRange [2]string
+			err = json.Unmarshal(rule.Value, &dateRange)
+			flat.DateType = "custom"
+			flat.DateRange = &models.DateRange{Start: dateRange[0], End: dateRange[1]}
+		default:
+			return flat, false
+		}
+		if err != nil {
+			return flat, false
+		}
+	}
+	return flat, true
+}
+
+// filterRuleCompiler 将规则树编译为 SQL 条件
+type filterRuleCompiler struct {
+	db           *gorm.DB
+	userID       uint64
+	today        time.Time
+	validateOnly bool // 只校验值，不访问数据库
+	useIndex     bool // 关键词使用全文索引和拼音索引匹配（搜索），否则按 LIKE 子串匹配（过滤器）
+}
+
+// compile 编译节点，返回空字符串表示没有条件
+func (c *filterRuleCompiler) compile(rule *models.FilterRule) (string, []interface{}, error) {
+	if !rule.IsGroup() {
+		return c.compileLeaf(rule)
+	}
+
+	var parts []string
+	var args []interface{}
+	for i := range rule.Rules {
+		sql, childArgs, err := c.compile(&rule.Rules[i])
+		if err != nil {
+			return "", nil, err
+		}
+		if sql == "" {
+			continue
+		}
+		parts = append(parts, sql)
+		args = append(args, childArgs...)
+	}
+	if len(parts) == 0 {
+		return "", nil, nil
+	}
+
+	switch rule.Match {
+	case FilterMatchAny:
+		return "(" + strings.Join(parts, " OR ") + ")", args, nil
+	case FilterMatchNone:
+		return "NOT (" + strings.Join(parts, " OR ") + ")", args, nil
+	default:
+		return "(" + strings.Join(parts, " AND ") + ")", args, nil
+	}
+}
+
+// compileLeaf 编译条件节点
+func (c *filterRuleCompiler) compileLeaf(rule *models.FilterRule) (string, []interface{}, error) {
+	switch rule.Field {
+	case "list":
+		if rule.Operator == "eq" || rule.Operator == "neq" {
+			// 按清单名称匹配（不区分大小写）
+			var name string
+			if err := decodeFilterValue(rule, &name); err != nil {
+				return "", nil, err
+			}
+			sql := "tasks.list_id IN (SELECT id FROM lists WHERE user_id = ? AND LOWER(name) = LOWER(?) AND deleted_at IS NULL)"
+			return negateIf(rule.Operator == "neq", sql), []interface{}{c.userID, name}, nil
+		}
+		var ids []uint64
+		if err := decodeFilterList(rule, &ids); err != nil {
+			return "", nil, err
+		}
+		return negateIf(rule.Operator == "notIn", "tasks.list_id IN ?"), []interface{}{ids}, nil
+
+	case "tag":
+		const taggedTasks = "SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id AND tags.deleted_at IS NULL"
+		switch rule.Operator {
+		case "isEmpty":
+			return "tasks.id NOT IN (" + taggedTasks + ")", nil, nil
+		case "isNotEmpty":
+			return "tasks.id IN (" + taggedTasks + ")", nil, nil
+		}
+		var ids []uint64
+		if rule.Operator == "eq" || rule.Operator == "neq" {
+			// 按标签名称匹配（不区分大小写）
+			var name string
+			if err := decodeFilterValue(rule, &name); err != nil {
+				return "", nil, err
+			}
+			if !c.validateOnly {
+				c.db.Model(&models.Tag{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", c.userID, name).Pluck("id", &ids)
+			}
+		} else if err := decodeFilterList(rule, &ids); err != nil {
+			return "", nil, err
+		}
+		if !c.validateOnly {
+			// 包含子标签
+			ids = ExpandTagIDs(c.db, c.userID, ids)
+		}
+		if len(ids) == 0 {
+			// 没有同名标签
+			ids = []uint64{0}
+		}
+		sql := "tasks.id IN (SELECT task_id FROM task_tags WHERE tag_id IN ?)"
+		if rule.Operator == "notIn" || rule.Operator == "neq" {
+			sql = "tasks.id NOT IN (SELECT task_id FROM task_tags WHERE tag_id IN ?)"
+		}
+		return sql, []interface{}{ids}, nil
+
+	case "priority":
+		if rule.Operator == "in" || rule.Operator == "notIn" {
+			var priorities []int
+			if err := decodeFilterList(rule, &priorities); err != nil {
+				return "", nil, err
+			}
+			return negateIf(rule.Operator == "notIn", "tasks.priority IN ?"), []interface{}{priorities}, nil
+		}
+		var priority int
+		if err := decodeFilterValue(rule, &priority); err != nil {
+			return "", nil, err
+		}
+		return "tasks.priority " + comparisonOperators[rule.Operator] + " ?", []interface{}{priority}, nil
+
+	case "status":
+		var statuses []string
+		if rule.Operator == "eq" || rule.Operator == "neq" {
+			var status string
+			if err := decodeFilterValue(rule, &status); err != nil {
+				return "", nil, err
+			}
+			statuses = []string{status}
+		} else if err := decodeFilterList(rule, &statuses); err != nil {
+			return "", nil, err
+		}
+		for _, status := range statuses {
+			if status != "todo" && status != "completed" && status != "abandoned" {
+				return "", nil, fmt.Errorf("unsupported status %q", status)
+			}
+		}
+		negate := rule.Operator == "notIn" || rule.Operator == "neq"
+		return negateIf(negate, "tasks.status IN ?"), []interface{}{statuses}, nil
+
+	case "dueDate":
+		return c.compileDate(rule, "tasks.due_date", false)
+
+	case "completedDate":
+		// completed_at 格式为 "20251105 18:20"，取前 8 位比较
+		return c.compileDate(rule, "SUBSTR(tasks.completed_at, 1, 8)", false)
+
+	case "createdDate":
+		return c.compileDate(rule, "tasks.created_at", true)
+
+	case "keyword":
+		var keyword string
+		if err := decodeFilterValue(rule, &keyword); err != nil {
+			return "", nil, err
+		}
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			return "", nil, fmt.Errorf("keyword must not be empty")
+		}
+		if c.useIndex {
+			// 全文索引匹配，或按拼音、首字母匹配标题
+			if match := BuildSearchMatch(keyword); match != "" {
+				pinyinIDs, err := MatchPinyinIndex(c.db, c.userID, SearchEntityTask, keyword)
+				if err != nil {
+					return "", nil, err
+				}
+				if len(pinyinIDs) == 0 {
+					pinyinIDs = []uint64{0}
+				}
+				sql := "(tasks.id IN (SELECT entity_id FROM search_index WHERE search_index MATCH ? AND entity_type = 'task') OR tasks.id IN ?)"
+				return negateIf(rule.Operator == "notContains", sql), []interface{}{match, pinyinIDs}, nil
+			}
+		}
+		pattern := "%" + escapeLike(keyword) + "%"
+		sql := "(tasks.title LIKE ? ESCAPE '\\' OR COALESCE(tasks.description, '') LIKE ? ESCAPE '\\')"
+		return negateIf(rule.Operator == "notContains", sql), []interface{}{pattern, pattern}, nil
+
+	case "hasReminder":
+		var hasReminder bool
+		if err := decodeFilterValue(rule, &hasReminder); err != nil {
+			return "", nil, err
+		}
+		if hasReminder {
+			return "(tasks.reminder_time IS NOT NULL AND tasks.reminder_time <> '')", nil, nil
+		}
+		return "(tasks.reminder_time IS NULL OR tasks.reminder_time = '')", nil, nil
+
+	case "isRecurring":
+		var isRecurring bool
+		if err := decodeFilterValue(rule, &isRecurring); err != nil {
+			return "", nil, err
+		}
+		return "tasks.is_recurring = ?", []interface{}{isRecurring}, nil
+
+	case "taskType":
+		var taskType string
+		if err := decodeFilterValue(rule, &taskType); err != nil {
+			return "", nil, err
+		}
+		if taskType != "task" && taskType != "note" {
+			return "", nil, fmt.Errorf("unsupported taskType %q", taskType)
+		}
+		// 暂不支持笔记，所有任务都是 task 类型
+		matches := (taskType == "task") == (rule.Operator == "eq")
+		if matches {
+			return "1 = 1", nil, nil
+		}
+		return "1 = 0", nil, nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported field %q", rule.Field)
+}
+
+// compileDate 编译日期条件；isTime 为 true 时列为时间类型，否则为 20251105 格式的字符串
+func (c *filterRuleCompiler) compileDate(rule *models.FilterRule, column string, isTime bool) (string, []interface{}, error) {
+	switch rule.Operator {
+	case "isEmpty":
+		return "(" + column + " IS NULL OR " + column + " = '')", nil, nil
+	case "isNotEmpty":
+		return "(" + column + " IS NOT NULL AND " + column + " <> '')", nil, nil
+	}
+
+	// 统一转换为闭区间 [start, end]，空字符串表示不限
+	var start, end string
+	switch rule.Operator {
+	case "eq", "before", "after":
+		var date string
+		if err := decodeFilterValue(rule, &date); err != nil {
+			return "", nil, err
+		}
+		date, err := c.resolveDate(rule.Field, date)
+		if err != nil {
+			return "", nil, err
+		}
+		switch rule.Operator {
+		case "eq":
+			start, end = date, date
+		case "before":
+			end = shiftDate(date, -1)
+		case "after":
+			start = shiftDate(date, 1)
+		}
+
+	case "between":
+		var dateRange [2]string
+		if err := decodeFilterValue(rule, &dateRange); err != nil {
+			return "", nil, err
+		}
+		for i, date := range dateRange {
+			if date == "" {
+				continue
+			}
+			resolved, err := c.resolveDate(rule.Field, date)
+			if err != nil {
+				return "", nil, err
+			}
+			dateRange[i] = resolved
+		}
+		if dateRange[0] == "" && dateRange[1] == "" {
+			return "", nil, fmt.Errorf("between requires a start or end date for field %q", rule.Field)
+		}
+		start, end = dateRange[0], dateRange[1]
+
+	case "preset":
+		var preset string
+		if err := decodeFilterValue(rule, &preset); err != nil {
+			return "", nil, err
+		}
+		today := utils.FormatDate(c.today)
+		switch preset {
+		case "today":
+			start, end = today, today
+		case "tomorrow":
+			start, end = shiftDate(today, 1), shiftDate(today, 1)
+		case "yesterday":
+			start, end = shiftDate(today, -1), shiftDate(today, -1)
+		case "week":
+			// 今天起的 7 天
+			start, end = today, shiftDate(today, 6)
+		case "last7Days":
+			// 包括今天在内的过去 7 天
+			start, end = shiftDate(today, -6), today
+		case "overdue":
+			end = shiftDate(today, -1)
+		default:
+			return "", nil, fmt.Errorf("unsupported date preset %q", preset)
+		}
+
+	default:
+		return "", nil, fmt.Errorf("unsupported operator %q for field %q", rule.Operator, rule.Field)
+	}
+
+	var conditions []string
+	var args []interface{}
+	if isTime {
+		// 时间列按当天零点比较，end 取次日零点（不含）
+		if start != "" {
+			conditions = append(conditions, column+" >= ?")
+			args = append(args, c.startOfDay(start))
+		}
+		if end != "" {
+			conditions = append(conditions, column+" < ?")
+			args = append(args, c.startOfDay(shiftDate(end, 1)))
+		}
+	} else {
+		conditions = append(conditions, column+" <> ''")
+		if start != "" {
+			conditions = append(conditions, column+" >= ?")
+			args = append(args, start)
+		}
+		if end != "" {
+			conditions = append(conditions, column+" <= ?")
+			args = append(args, end)
+		}
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", args, nil
+}
+
+// resolveDate 校验日期值，today、tomorrow、yesterday 按 today 换算为具体日期
+func (c *filterRuleCompiler) resolveDate(field, date string) (string, error) {
+	today := utils.FormatDate(c.today)
+	switch date {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return shiftDate(today, 1), nil
+	case "yesterday":
+		return shiftDate(today, -1), nil
+	}
+	if _, err := utils.ParseDate(date); err != nil {
+		return "", fmt.Errorf("invalid date %q for field %q", date, field)
+	}
+	return date, nil
+}
+
+// startOfDay 日期在 today 所在时区的零点
+func (c *filterRuleCompiler) startOfDay(date string) time.Time {
+	t, _ := time.ParseInLocation("20060102", date, c.today.Location())
+	return t
+}
+
+var comparisonOperators = map[string]string{
+	"eq":  "=",
+	"neq": "<>",
+	"gte": ">=",
+	"lte": "<=",
+}
+
+// decodeFilterValue 解析条件节点的值
+func decodeFilterValue(rule *models.FilterRule, dst interface{}) error {
+	if len(rule.Value) == 0 {
+		return fmt.Errorf("value is required for %s %s", rule.Field, rule.Operator)
+	}
+	if err := json.Unmarshal(rule.Value, dst); err != nil {
+		return fmt.Errorf("invalid value for %s %s", rule.Field, rule.Operator)
+	}
+	return nil
+}
+
+// decodeFilterList 解析列表值，列表不能为空
+func decodeFilterList[T any](rule *models.FilterRule, dst *[]T) error {
+	if err := decodeFilterValue(rule, dst); err != nil {
+		return err
+	}
+	if len(*dst) == 0 {
+		return fmt.Errorf("value must not be empty for %s %s", rule.Field, rule.Operator)
+	}
+	return nil
+}
+
+// negateIf 需要时对条件取反
+func negateIf(negate bool, sql string) string {
+	if negate {
+		return "NOT (" + sql + ")"
+	}
+	return sql
+}
+
+// shiftDate 日期（20251105 格式）加减天数
+func shiftDate(date string, days int) string {
+	t, err := utils.ParseDate(date)
+	if err != nil {
+		return date
+	}
+	return utils.FormatDate(t.AddDate(0, 0, days))
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// HolidayCalendar 节假日日历，提供法定节假日和调休工作日信息
+type HolidayCalendar interface {
+	// Lookup 查询某天的安排：found 为 false 表示没有特殊安排（按周末/工作日处理），
+	// 否则 isOffDay 为 true 表示法定休息日，false 表示调休工作日
+	Lookup(date time.Time) (isOffDay bool, found bool)
+}
+
+// IsWorkday 是否为工作日：调休工作日算工作日，法定休息日不算，其余按周一到周五
+func IsWorkday(calendar HolidayCalendar, date time.Time) bool {
+	if calendar != nil {
+		if isOffDay, found := calendar.Lookup(date); found {
+			return !isOffDay
+		}
+	}
+	weekday := date.Weekday()
+	return weekday != time.Saturday && weekday != time.Sunday
+}
+
+// IsOffDay 是否为休息日（法定节假日或非调休的周末）
+func IsOffDay(calendar HolidayCalendar, date time.Time) bool {
+	return !IsWorkday(calendar, date)
+}
+
+// holidayCacheTTL 缓存有效期，导入新的节假日数据后无需重启即可生效
+const holidayCacheTTL = time.Hour
+
+// DBHolidayCalendar 基于 holidays 表的节假日日历，按年缓存
+type DBHolidayCalendar struct {
+	db    *gorm.DB
+	mu    sync.Mutex
+	years map[int]*holidayYear
+}
+
+type holidayYear struct {
+	days     map[string]bool // 日期（2006-01-02）-> 是否休息
+	loadedAt time.Time
+}
+
+// NewDBHolidayCalendar 创建基于数据库的节假日日历
+func NewDBHolidayCalendar(db *gorm.DB) *DBHolidayCalendar {
+	return &DBHolidayCalendar{
+		db:    db,
+		years: make(map[int]*holidayYear),
+	}
+}
+
+// Lookup 查询某天的节假日安排
+func (c *DBHolidayCalendar) Lookup(date time.Time) (bool, bool) {
+	year := c.loadYear(date.Year())
+	if year == nil {
+		return false, false
+	}
+	isOffDay, found := year.days[date.Format("2006-01-02")]
+	return isOffDay, found
+}
+
+// loadYear 读取某年的节假日数据，优先使用缓存
+func (c *DBHolidayCalendar) loadYear(year int) *holidayYear {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.years[year]; ok && time.Since(cached.loadedAt) < holidayCacheTTL {
+		return cached
+	}
+
+	// 按日期而不是 year 列查询：某年的节假日数据可能包含相邻年份的日期（如元旦假期跨年）
+	var holidays []models.Holiday
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	end := time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	if err := c.db.Where("date >= ? AND date <= ?", start, end).Find(&holidays).Error; err != nil {
+		// 查询失败时不缓存，退化为按周末判断
+		utils.LogError("Failed to load holidays", zap.Int("year", year), zap.Error(err))
+		return nil
+	}
+
+	loaded := &holidayYear{
+		days:     make(map[string]bool, len(holidays)),
+		loadedAt: time.Now(),
+	}
+	for _, holiday := range holidays {
+		loaded.days[holiday.Date] = holiday.IsOffDay
+	}
+	c.years[year] = loaded
+	return loaded
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"path"
+	"strings"
+	"time"
+)
+
+// 支持导入的其他应用
+const (
+	ImportSourceTickTick = "ticktick" // 滴答清单 / TickTick 的 CSV 备份
+	ImportSourceTodoist  = "todoist"  // Todoist 的 CSV（项目导出或备份 zip）或 Sync API JSON
+	ImportSourceMSTodo   = "mstodo"   // Microsoft To Do 通过 Graph API 导出的 JSON
+)
+
+// ErrInvalidImportFile 导入文件无法解析
+var ErrInvalidImportFile = errors.New("invalid import file")
+
+// ImportFile 上传的导入文件，Name 用于判断格式，Todoist 的项目 CSV 以文件名作为清单名
+type ImportFile struct {
+	Name string
+	Data []byte
+}
+
+// externalConverters 各应用的转换函数，把一个文件的内容加入备份
+var externalConverters = map[string]func(b *externalBackup, file ImportFile) error{
+	ImportSourceTickTick: convertTickTick,
+	ImportSourceTodoist:  convertTodoist,
+	ImportSourceMSTodo:   convertMSTodo,
+}
+
+// IsImportSource 判断是否为支持导入的应用
+func IsImportSource(source string) bool {
+	_, ok := externalConverters[source]
+	return ok
+}
+
+// ConvertExternalExport 把其他应用的导出文件转换为账户备份，再由 ImportAccount 导入。
+// zip 文件中的每个 CSV 或 JSON 文件分别转换；返回的提示为转换时跳过或调整的内容
+func ConvertExternalExport(source string, file ImportFile, maxSize int64) (*AccountBackup, []string, error) {
+	convert, ok := externalConverters[source]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unsupported source %q", ErrInvalidImportFile, source)
+	}
+
+	files, err := expandImportFile(file, maxSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	b := newExternalBackup()
+	for _, f := range files {
+		if err := convert(b, f); err != nil {
+			if len(files) > 1 {
+				return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidImportFile, f.Name, err)
+			}
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+	}
+	return b.backup, b.warnings, nil
+}
+
+// expandImportFile 解压 zip 文件中的 CSV 和 JSON 文件，其他文件原样返回。maxSize 为解压后的总大小上限
+func expandImportFile(file ImportFile, maxSize int64) ([]ImportFile, error) {
+	if !bytes.HasPrefix(file.Data, []byte("PK\x03\x04")) {
+		return []ImportFile{file}, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(file.Data), int64(len(file.Data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+	var files []ImportFile
+	remaining := maxSize
+	for _, entry := range archive.File {
+		ext := strings.ToLower(path.Ext(entry.Name))
+		if entry.FileInfo().IsDir() || (ext != ".csv" && ext != ".json") || strings.HasPrefix(path.Base(entry.Name), ".") {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		// 限制解压后的大小，避免压缩炸弹
+		data, err := io.ReadAll(io.LimitReader(reader, remaining+1))
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		remaining -= int64(len(data))
+		if remaining < 0 {
+			return nil, fmt.Errorf("%w: archive is too large", ErrInvalidImportFile)
+		}
+		files = append(files, ImportFile{Name: entry.Name, Data: data})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no CSV or JSON file found in archive", ErrInvalidImportFile)
+	}
+	return files, nil
+}
+
+// externalInboxID 备份中收集箱的临时 ID，导入时与账户的收集箱合并
+const externalInboxID = 1
+
+// externalBackup 把其他应用的数据整理为账户备份。记录使用递增的临时 ID，导入时换成新 ID；
+// 文件夹、清单和标签按名称去重
+type externalBackup struct {
+	backup   *AccountBackup
+	nextID   uint64
+	folders  map[string]uint64
+	lists    map[string]uint64
+	tags     map[string]uint64
+	warnings []string
+}
+
+func newExternalBackup() *externalBackup {
+	b := &externalBackup{
+		backup: &AccountBackup{
+			Format:     AccountBackupFormat,
+			Version:    AccountBackupVersion,
+			ExportedAt: time.Now(),
+			Lists: []models.List{
+				{ID: externalInboxID, Name: "收集箱", Type: "inbox", IsDefault: true, IsSystem: true},
+			},
+		},
+		nextID:  externalInboxID,
+		folders: make(map[string]uint64),
+		lists:   make(map[string]uint64),
+		tags:    make(map[string]uint64),
+	}
+	return b
+}
+
+func (b *externalBackup) newID() uint64 {
+	b.nextID++
+	return b.nextID
+}
+
+func (b *externalBackup) warn(format string, args ...interface{}) {
+	if len(b.warnings) < maxImportWarnings {
+		b.warnings = append(b.warnings, fmt.Sprintf(format, args...))
+	}
+}
+
+// folder 返回文件夹的临时 ID，名称为空时返回 nil
+func (b *externalBackup) folder(name string) *uint64 {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	id, ok := b.folders[name]
+	if !ok {
+		id = b.newID()
+		b.folders[name] = id
+		b.backup.Folders = append(b.backup.Folders, models.Folder{ID: id, Name: name, IsExpanded: true, SortOrder: len(b.backup.Folders)})
+	}
+	return &id
+}
+
+// list 返回文件夹中清单的临时 ID。名称为空或为收集箱（Inbox）时返回收集箱
+func (b *externalBackup) list(folder, name string) uint64 {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, "inbox") || name == "收集箱" {
+		return externalInboxID
+	}
+	key := strings.TrimSpace(folder) + "/" + name
+	id, ok := b.lists[key]
+	if !ok {
+		id = b.newID()
+		b.lists[key] = id
+		b.backup.Lists = append(b.backup.Lists, models.List{
+			ID:        id,
+			FolderID:  b.folder(folder),
+			Name:      name,
+			Type:      "custom",
+			SortOrder: len(b.backup.Lists),
+		})
+	}
+	return id
+}
+
+// tag 返回标签的临时 ID。名称中的 "/" 表示层级，如 "工作/会议" 为 "工作" 的子标签；空名称返回 0
+func (b *externalBackup) tag(name string) uint64 {
+	var parentID *uint64
+	var id uint64
+	key := ""
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(name), "#"), "/") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key += "/" + part
+		var ok bool
+		id, ok = b.tags[key]
+		if !ok {
+			id = b.newID()
+			b.tags[key] = id
+			b.backup.Tags = append(b.backup.Tags, models.Tag{ID: id, ParentID: parentID, Name: part})
+		}
+		parent := id
+		parentID = &parent
+	}
+	return id
+}
+
+// addTask 加入一个任务，为任务分配临时 ID 并补全状态和清单，返回任务的临时 ID
+func (b *externalBackup) addTask(task *models.Task, tags []string) uint64 {
+	task.ID = b.newID()
+	if task.Status == "" {
+		task.Status = "todo"
+	}
+	if task.ListID == 0 {
+		task.ListID = externalInboxID
+	}
+	tagIDs := []uint64{}
+	for _, name := range tags {
+		if id := b.tag(name); id != 0 {
+			tagIDs = append(tagIDs, id)
+		}
+	}
+	b.backup.Tasks = append(b.backup.Tasks, BackupTask{Task: *task, TagIDs: tagIDs})
+	return task.ID
+}
+
+// addChecklistItem 为任务加入一个检查项
+func (b *externalBackup) addChecklistItem(taskID uint64, title string, completed bool, completedAt string) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return
+	}
+	b.backup.ChecklistItems = append(b.backup.ChecklistItems, models.ChecklistItem{
+		ID:          b.newID(),
+		TaskID:      taskID,
+		Title:       title,
+		IsCompleted: completed,
+		CompletedAt: completedAt,
+		SortOrder:   len(b.backup.ChecklistItems),
+	})
+}
+
+// addReminder 为设置了提醒时间的未完成任务加入弹窗提醒，已经过期的提醒导入时跳过
+func (b *externalBackup) addReminder(task *models.Task) {
+	if task.ReminderTime == "" || task.Status != "todo" {
+		return
+	}
+	metadata, _ := json.Marshal(map[string]string{
+		"title":       task.Title,
+		"description": task.Description,
+	})
+	b.backup.Reminders = append(b.backup.Reminders, models.Reminder{
+		ID:           b.newID(),
+		EntityType:   "task",
+		EntityID:     task.ID,
+		ReminderTime: task.ReminderTime,
+		ReminderType: "popup",
+		Status:       ReminderStatusPending,
+		Metadata:     string(metadata),
+	})
+}
+
+// setTaskRecurrence 为任务设置重复规则，规则无效时返回错误。
+// 没有截止日期的重复任务以今天之后（含今天）的第一个日期作为截止日期
+func setTaskRecurrence(task *models.Task, rrule string) error {
+	rule, err := utils.ParseRRule(rrule)
+	if err != nil {
+		return err
+	}
+	if task.DueDate == "" {
+		today := utils.BeginningOfDay(utils.Now())
+		if next, ok := rule.After(today, today.Add(-time.Nanosecond)); ok {
+			task.DueDate = utils.FormatDate(next)
+		}
+	}
+	task.IsRecurring = true
+	task.RRule = rule.String()
+	task.RecurrenceStart = task.DueDate
+	return nil
+}
+
+// setTaskDue 按截止时间设置任务的截止日期和时间，allDay 为 true 时只设置日期（按 t 所在时区的日期）
+func setTaskDue(task *models.Task, t time.Time, allDay bool) {
+	if allDay {
+		task.DueDate = utils.FormatDate(t)
+		task.DueTime = ""
+		return
+	}
+	local := t.In(time.Local)
+	task.DueDate = utils.FormatDate(local)
+	task.DueTime = utils.FormatTime(local)
+}
+
+// setTaskCompleted 把任务标记为已完成，完成时间未知时使用截止日期或创建时间
+func setTaskCompleted(task *models.Task, completedAt time.Time) {
+	task.Status = "completed"
+	switch {
+	case !completedAt.IsZero():
+		task.CompletedAt = utils.FormatDateTime(completedAt.In(time.Local))
+	case task.DueDate != "":
+		task.CompletedAt = task.DueDate + " 00:00"
+	case !task.CreatedAt.IsZero():
+		task.CompletedAt = utils.FormatDateTime(task.CreatedAt.In(time.Local))
+	}
+}
+
+// loadLocation 加载时区，名称为空或无法识别时使用 fallback
+func loadLocation(name string, fallback *time.Location) *time.Location {
+	if name == "" {
+		return fallback
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return fallback
+}
+
+// parseTimeLayouts 按顺序尝试多种格式解析时间，没有时区信息的时间按 loc 解析
+func parseTimeLayouts(value string, loc *time.Location, layouts ...string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// msTodoTimeLayouts Graph API 中 dateTimeTimeZone 的时间格式，如 2024-03-01T09:00:00.0000000
+var msTodoTimeLayouts = []string{"2006-01-02T15:04:05.9999999", "2006-01-02T15:04:05.9999999Z07:00", "2006-01-02"}
+
+// msTodoHTMLBreak 换行的 HTML 标签，msTodoHTMLTag 其他 HTML 标签
+var (
+	msTodoHTMLBreak = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>`)
+	msTodoHTMLTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// msTodoWeekdays 星期对应的 BYDAY
+var msTodoWeekdays = map[string]string{
+	"monday": "MO", "tuesday": "TU", "wednesday": "WE", "thursday": "TH", "friday": "FR", "saturday": "SA", "sunday": "SU",
+}
+
+// msTodoIndexes relativeMonthly 等重复中的第几个
+var msTodoIndexes = map[string]int{"first": 1, "second": 2, "third": 3, "fourth": 4, "last": -1}
+
+// msTodoDateTime Graph API 的 dateTimeTimeZone
+type msTodoDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+// time 按所在时区解析时间，时区无法识别时按 UTC 处理
+func (d *msTodoDateTime) time() (time.Time, bool) {
+	if d == nil {
+		return time.Time{}, false
+	}
+	return parseTimeLayouts(d.DateTime, loadLocation(d.TimeZone, time.UTC), msTodoTimeLayouts...)
+}
+
+type msTodoList struct {
+	DisplayName       string       `json:"displayName"`
+	WellknownListName string       `json:"wellknownListName"` // defaultList 为默认的"任务"清单
+	Tasks             []msTodoTask `json:"tasks"`
+}
+
+type msTodoTask struct {
+	Title string `json:"title"`
+	Body  *struct {
+		Content     string `json:"content"`
+		ContentType string `json:"contentType"`
+	} `json:"body"`
+	Importance        string          `json:"importance"` // low, normal, high
+	Status            string          `json:"status"`     // notStarted, inProgress, completed, waitingOnOthers, deferred
+	CreatedDateTime   string          `json:"createdDateTime"`
+	CompletedDateTime *msTodoDateTime `json:"completedDateTime"`
+	DueDateTime       *msTodoDateTime `json:"dueDateTime"`
+	ReminderDateTime  *msTodoDateTime `json:"reminderDateTime"`
+	IsReminderOn      bool            `json:"isReminderOn"`
+	Categories        []string        `json:"categories"`
+	Recurrence        *struct {
+		Pattern struct {
+			Type       string   `json:"type"`
+			Interval   int      `json:"interval"`
+			DaysOfWeek []string `json:"daysOfWeek"`
+			DayOfMonth int      `json:"dayOfMonth"`
+			Month      int      `json:"month"`
+			Index      string   `json:"index"`
+		} `json:"pattern"`
+		Range struct {
+			Type                string `json:"type"` // noEnd, endDate, numbered
+			EndDate             string `json:"endDate"`
+			NumberOfOccurrences int    `json:"numberOfOccurrences"`
+		} `json:"range"`
+	} `json:"recurrence"`
+	ChecklistItems []struct {
+		DisplayName     string `json:"displayName"`
+		IsChecked       bool   `json:"isChecked"`
+		CheckedDateTime string `json:"checkedDateTime"`
+	} `json:"checklistItems"`
+}
+
+// convertMSTodo 转换 Microsoft To Do 的数据：Graph API 的清单（/me/todo/lists，每个清单带 tasks），
+// 可以是 {"lists": [...]}、{"value": [...]} 或清单数组
+func convertMSTodo(b *externalBackup, file ImportFile) error {
+	data := bytes.TrimSpace(bytes.TrimPrefix(file.Data, []byte("\xef\xbb\xbf")))
+	var lists []msTodoList
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &lists); err != nil {
+			return err
+		}
+	} else {
+		var export struct {
+			Lists []msTodoList `json:"lists"`
+			Value []msTodoList `json:"value"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			return err
+		}
+		lists = append(export.Lists, export.Value...)
+	}
+	if len(lists) == 0 {
+		return errors.New("no lists found, not a Microsoft To Do export")
+	}
+
+	for _, list := range lists {
+		var listID uint64 = externalInboxID
+		if list.WellknownListName != "defaultList" {
+			listID = b.list("", list.DisplayName)
+		}
+		for i := range list.Tasks {
+			b.addMSTodoTask(listID, &list.Tasks[i])
+		}
+	}
+	return nil
+}
+
+func (b *externalBackup) addMSTodoTask(listID uint64, item *msTodoTask) {
+	title := strings.TrimSpace(item.Title)
+	if title == "" {
+		b.warn("mstodo: task title is empty, skipped")
+		return
+	}
+
+	task := models.Task{Title: title, ListID: listID}
+	// 标记为重要的任务对应"重要"象限
+	if item.Importance == "high" {
+		task.Priority = 2
+	}
+	if item.Body != nil {
+		task.Description = item.Body.Content
+		if strings.EqualFold(item.Body.ContentType, "html") {
+			task.Description = msTodoPlainText(item.Body.Content)
+		}
+		task.Description = strings.TrimSpace(task.Description)
+	}
+	if created, ok := parseTimeLayouts(item.CreatedDateTime, time.UTC, time.RFC3339Nano); ok {
+		task.CreatedAt = created
+	}
+	// 截止日期只有日期，按原时区取日期部分
+	if due, ok := item.DueDateTime.time(); ok {
+		setTaskDue(&task, due, true)
+	}
+	if reminder, ok := item.ReminderDateTime.time(); ok && item.IsReminderOn {
+		task.ReminderTime = utils.FormatDateTime(reminder.In(time.Local))
+	}
+	if item.Recurrence != nil {
+		rrule, err := msTodoRRule(item)
+		if err == nil {
+			err = setTaskRecurrence(&task, rrule)
+		}
+		if err != nil {
+			b.warn("mstodo task %q: %v, imported as a one-time task", title, err)
+		}
+	}
+	if item.Status == "completed" {
+		completed, _ := item.CompletedDateTime.time()
+		setTaskCompleted(&task, completed)
+	}
+
+	taskID := b.addTask(&task, item.Categories)
+	for _, checklistItem := range item.ChecklistItems {
+		completedAt := ""
+		if checked, ok := parseTimeLayouts(checklistItem.CheckedDateTime, time.UTC, time.RFC3339Nano); ok && checklistItem.IsChecked {
+			completedAt = utils.FormatDateTime(checked.In(time.Local))
+		}
+		b.addChecklistItem(taskID, checklistItem.DisplayName, checklistItem.IsChecked, completedAt)
+	}
+	b.addReminder(&task)
+}
+
+// msTodoRRule 把 Graph API 的 patternedRecurrence 转换为 RRULE
+func msTodoRRule(item *msTodoTask) (string, error) {
+	pattern, recurrenceRange := item.Recurrence.Pattern, item.Recurrence.Range
+	var parts []string
+	byDay := func(index int) (string, error) {
+		var days []string
+		for _, name := range pattern.DaysOfWeek {
+			day, ok := msTodoWeekdays[strings.ToLower(name)]
+			if !ok {
+				return "", fmt.Errorf("unsupported day of week %q", name)
+			}
+			if index != 0 {
+				day = fmt.Sprintf("%d%s", index, day)
+			}
+			days = append(days, day)
+		}
+		if len(days) == 0 {
+			return "", errors.New("recurrence has no days of week")
+		}
+		return "BYDAY=" + strings.Join(days, ","), nil
+	}
+	relative := func() (string, error) {
+		index, ok := msTodoIndexes[strings.ToLower(pattern.Index)]
+		if !ok {
+			index = 1
+		}
+		return byDay(index)
+	}
+
+	switch pattern.Type {
+	case "daily":
+		parts = append(parts, "FREQ=DAILY")
+	case "weekly":
+		days, err := byDay(0)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "FREQ=WEEKLY", days)
+	case "absoluteMonthly":
+		parts = append(parts, "FREQ=MONTHLY", fmt.Sprintf("BYMONTHDAY=%d", pattern.DayOfMonth))
+	case "relativeMonthly":
+		days, err := relative()
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "FREQ=MONTHLY", days)
+	case "absoluteYearly":
+		parts = append(parts, "FREQ=YEARLY", fmt.Sprintf("BYMONTH=%d", pattern.Month), fmt.Sprintf("BYMONTHDAY=%d", pattern.DayOfMonth))
+	case "relativeYearly":
+		days, err := relative()
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "FREQ=YEARLY", fmt.Sprintf("BYMONTH=%d", pattern.Month), days)
+	default:
+		return "", fmt.Errorf("unsupported recurrence type %q", pattern.Type)
+	}
+	if pattern.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", pattern.Interval))
+	}
+	switch recurrenceRange.Type {
+	case "endDate":
+		if end, err := time.Parse("2006-01-02", recurrenceRange.EndDate); err == nil {
+			parts = append(parts, "UNTIL="+utils.FormatDate(end))
+		}
+	case "numbered":
+		if recurrenceRange.NumberOfOccurrences > 0 {
+			parts = append(parts, fmt.Sprintf("COUNT=%d", recurrenceRange.NumberOfOccurrences))
+		}
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// msTodoPlainText 把 HTML 格式的备注转换为纯文本
+func msTodoPlainText(content string) string {
+	content = msTodoHTMLBreak.ReplaceAllString(content, "\n")
+	content = html.UnescapeString(msTodoHTMLTag.ReplaceAllString(content, ""))
+	lines := strings.Split(strings.ReplaceAll(content, "\r", ""), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t ")
+	}
+	return strings.Join(lines, "\n")
+}
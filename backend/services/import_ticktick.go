@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tickTickTimeLayouts 滴答清单备份中的时间格式，如 2024-01-15T09:00:00+0000
+var tickTickTimeLayouts = []string{"2006-01-02T15:04:05-0700", "2006-01-02T15:04:05Z07:00", "2006-01-02"}
+
+// tickTickPriorities 滴答清单的优先级（0 无、1 低、3 中、5 高）对应的四象限
+var tickTickPriorities = map[string]int{"0": 0, "1": 1, "3": 2, "5": 3}
+
+// tickTickDuration 提醒相对截止时间的偏移，如 -PT30M、-P1DT15H0M0S、PT0S
+var tickTickDuration = regexp.MustCompile(`^(-)?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// convertTickTick 转换滴答清单（TickTick）设置中"生成备份"得到的 CSV 文件。
+// 文件开头是几行说明，之后是以 "Folder Name" 开头的表头；子任务以 parentId 关联父任务
+func convertTickTick(b *externalBackup, file ImportFile) error {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(file.Data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var columns map[string]int
+	type pendingTask struct {
+		index    int // 在备份任务列表中的位置
+		parentID string
+	}
+	taskIDs := make(map[string]uint64)
+	var subtasks []pendingTask
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if columns == nil {
+			if len(record) > 0 && strings.TrimSpace(record[0]) == "Folder Name" {
+				columns = make(map[string]int, len(record))
+				for i, name := range record {
+					columns[strings.TrimSpace(name)] = i
+				}
+			}
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		title := field("Title")
+		if title == "" {
+			b.warn("ticktick line %d: title is empty, skipped", line)
+			continue
+		}
+
+		task := models.Task{
+			Title:    title,
+			ListID:   b.list(field("Folder Name"), field("List Name")),
+			Priority: tickTickPriorities[field("Priority")],
+		}
+		loc := loadLocation(field("Timezone"), time.Local)
+		allDay := strings.EqualFold(field("Is All Day"), "true")
+		if created, ok := parseTimeLayouts(field("Created Time"), time.UTC, tickTickTimeLayouts...); ok {
+			task.CreatedAt = created
+		}
+		if order, err := strconv.Atoi(field("Order")); err == nil {
+			task.SortOrder = order
+		}
+
+		due, hasDue := parseTimeLayouts(field("Due Date"), time.UTC, tickTickTimeLayouts...)
+		if !hasDue {
+			due, hasDue = parseTimeLayouts(field("Start Date"), time.UTC, tickTickTimeLayouts...)
+		}
+		if hasDue {
+			// 全天任务的时间是所在时区的零点，按所在时区取日期
+			setTaskDue(&task, due.In(loc), allDay)
+			if reminder, ok := tickTickReminder(field("Reminder"), due); ok {
+				task.ReminderTime = utils.FormatDateTime(reminder.In(time.Local))
+			}
+		}
+
+		if repeat := field("Repeat"); repeat != "" {
+			if err := setTaskRecurrence(&task, tickTickRRule(repeat)); err != nil {
+				b.warn("ticktick task %q: unsupported repeat rule %q, imported as a one-time task", title, repeat)
+			}
+		}
+
+		switch field("Status") {
+		case "1", "2":
+			completed, _ := parseTimeLayouts(field("Completed Time"), time.UTC, tickTickTimeLayouts...)
+			setTaskCompleted(&task, completed)
+		case "-1":
+			task.Status = "abandoned"
+		}
+
+		// 检查项清单的内容每行一项，▫ 为未完成、▪ 为已完成，其他行作为描述
+		content := strings.ReplaceAll(field("Content"), "\r", "")
+		var description []string
+		type checklistLine struct {
+			title string
+			done  bool
+		}
+		var checklist []checklistLine
+		for _, text := range strings.Split(content, "\n") {
+			switch {
+			case strings.HasPrefix(text, "▫"):
+				checklist = append(checklist, checklistLine{title: strings.TrimPrefix(text, "▫")})
+			case strings.HasPrefix(text, "▪"):
+				checklist = append(checklist, checklistLine{title: strings.TrimPrefix(text, "▪"), done: true})
+			default:
+				description = append(description, text)
+			}
+		}
+		task.Description = strings.TrimSpace(strings.Join(description, "\n"))
+
+		taskID := b.addTask(&task, strings.Split(field("Tags"), ","))
+		for _, item := range checklist {
+			completedAt := ""
+			if item.done {
+				completedAt = task.CompletedAt
+			}
+			b.addChecklistItem(taskID, item.title, item.done, completedAt)
+		}
+		b.addReminder(&task)
+
+		if id := field("taskId"); id != "" {
+			taskIDs[id] = taskID
+		}
+		if parentID := field("parentId"); parentID != "" {
+			subtasks = append(subtasks, pendingTask{index: len(b.backup.Tasks) - 1, parentID: parentID})
+		}
+	}
+	if columns == nil {
+		return errors.New("header row not found, not a TickTick backup")
+	}
+
+	// 父任务可能出现在子任务之后，全部读完后再关联
+	for _, subtask := range subtasks {
+		task := &b.backup.Tasks[subtask.index]
+		if parentID, ok := taskIDs[subtask.parentID]; ok && parentID != task.ID {
+			task.ParentID = &parentID
+		} else {
+			b.warn("ticktick task %q: parent task not found, imported as a top-level task", task.Title)
+		}
+	}
+	return nil
+}
+
+// tickTickRRule 去掉滴答清单在 RRULE 中加入的扩展项（如 TT_SKIP=HOLIDAY）
+func tickTickRRule(repeat string) string {
+	var parts []string
+	for _, part := range strings.Split(repeat, ";") {
+		if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(part)), "TT_") {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// tickTickReminder 计算提醒时间，reminders 为逗号分隔的相对截止时间的偏移，只取第一个
+func tickTickReminder(reminders string, due time.Time) (time.Time, bool) {
+	first := strings.TrimSpace(strings.Split(reminders, ",")[0])
+	first = strings.TrimPrefix(first, "TRIGGER:")
+	match := tickTickDuration.FindStringSubmatch(first)
+	if match == nil || first == "P" || first == "-P" {
+		return time.Time{}, false
+	}
+	number := func(i int) int {
+		n, _ := strconv.Atoi(match[i])
+		return n
+	}
+	offset := time.Duration(number(2)*7+number(3))*24*time.Hour +
+		time.Duration(number(4))*time.Hour + time.Duration(number(5))*time.Minute + time.Duration(number(6))*time.Second
+	if match[1] == "-" {
+		offset = -offset
+	}
+	return due.Add(offset), true
+}
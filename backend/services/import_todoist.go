@@ -0,0 +1,502 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"on-the-way/backend/models"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// todoistCSVPriorities CSV 中的优先级（1 为最高的 p1，4 为无优先级）对应的四象限
+var todoistCSVPriorities = map[string]int{"1": 3, "2": 2, "3": 1, "4": 0}
+
+// todoistDateLayouts Todoist 截止日期的格式，没有时区的时间为浮动时间，按服务器所在时区解析
+var todoistDateLayouts = []string{
+	time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02",
+	"Jan 2 2006", "2 Jan 2006", "January 2 2006", "2 January 2006",
+}
+
+// todoistLabel 任务内容中的 @标签
+var todoistLabel = regexp.MustCompile(`(?:^|\s)@([^\s@]+)`)
+
+// convertTodoist 根据内容转换 Todoist 的导出文件：JSON 为 Sync API 的完整同步数据，其他为项目导出的 CSV
+func convertTodoist(b *externalBackup, file ImportFile) error {
+	data := bytes.TrimSpace(bytes.TrimPrefix(file.Data, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(data, []byte("{")) {
+		return convertTodoistJSON(b, data)
+	}
+	return convertTodoistCSV(b, file.Name, string(data))
+}
+
+// convertTodoistCSV 转换 Todoist 项目导出的 CSV，一个文件为一个项目，以文件名作为清单名。
+// INDENT 表示层级，缩进更深的任务为上一个缩进更浅的任务的子任务；note 行附加到上一个任务的描述
+func convertTodoistCSV(b *externalBackup, name, data string) error {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.ToUpper(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return errors.New("CONTENT column not found, not a Todoist export")
+	}
+	if _, ok := columns["TYPE"]; !ok {
+		return errors.New("TYPE column not found, not a Todoist export")
+	}
+
+	listName := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	listID := b.list("", todoistProjectName(listName))
+	var parents []uint64 // parents[i] 为缩进 i+1 的最近一个任务
+	var lastTask *BackupTask
+	sections := 0
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		switch strings.ToLower(field("TYPE")) {
+		case "task":
+		case "note":
+			if lastTask != nil && field("CONTENT") != "" {
+				lastTask.Description = strings.TrimSpace(lastTask.Description + "\n\n" + field("CONTENT"))
+			}
+			continue
+		case "section":
+			sections++
+			continue
+		default:
+			continue
+		}
+
+		title, labels := todoistContent(field("CONTENT"))
+		if title == "" {
+			b.warn("todoist %s line %d: content is empty, skipped", listName, line)
+			continue
+		}
+		task := models.Task{
+			Title:       title,
+			Description: field("DESCRIPTION"),
+			ListID:      listID,
+			Priority:    todoistCSVPriorities[field("PRIORITY")],
+		}
+		if date := field("DATE"); date != "" {
+			loc := loadLocation(field("TIMEZONE"), time.Local)
+			if err := setTodoistDue(&task, date, loc); err != nil {
+				b.warn("todoist task %q: %v", title, err)
+			}
+		}
+
+		indent, _ := strconv.Atoi(field("INDENT"))
+		if indent < 1 {
+			indent = 1
+		}
+		if indent > len(parents)+1 {
+			indent = len(parents) + 1
+		}
+		if indent > 1 {
+			parentID := parents[indent-2]
+			task.ParentID = &parentID
+		}
+		taskID := b.addTask(&task, labels)
+		parents = append(parents[:indent-1], taskID)
+		lastTask = &b.backup.Tasks[len(b.backup.Tasks)-1]
+	}
+	if sections > 0 {
+		b.warn("todoist %s: %d sections ignored, their tasks are imported into the list", listName, sections)
+	}
+	return nil
+}
+
+// todoistProjectName 去掉 Todoist 备份中项目文件名的 ID 后缀，如 "Work [2203306141]"
+func todoistProjectName(name string) string {
+	if i := strings.LastIndex(name, " ["); i > 0 && strings.HasSuffix(name, "]") {
+		return strings.TrimSpace(name[:i])
+	}
+	return name
+}
+
+// todoistContent 从任务内容中取出 @标签，返回去掉标签后的标题
+func todoistContent(content string) (string, []string) {
+	var labels []string
+	for _, match := range todoistLabel.FindAllStringSubmatch(content, -1) {
+		labels = append(labels, match[1])
+	}
+	title := todoistLabel.ReplaceAllString(content, "")
+	return strings.Join(strings.Fields(title), " "), labels
+}
+
+// setTodoistDue 按 Todoist 的日期设置截止日期：日期或时间，或者 "every monday" 等重复日期
+func setTodoistDue(task *models.Task, date string, loc *time.Location) error {
+	if t, ok := parseTimeLayouts(date, loc, todoistDateLayouts...); ok {
+		setTaskDue(task, t, !strings.Contains(date, ":"))
+		return nil
+	}
+	rrule, anchor, dueTime, ok := todoistRRule(date)
+	if !ok {
+		return fmt.Errorf("unsupported date %q", date)
+	}
+	if err := setTaskRecurrence(task, rrule); err != nil {
+		return fmt.Errorf("unsupported date %q", date)
+	}
+	task.RecurrenceAnchor = anchor
+	task.DueTime = dueTime
+	return nil
+}
+
+// todoistJSON Todoist Sync API 完整同步（resource_types=["all"]）返回的数据，completed 为已完成任务接口的返回值
+type todoistJSON struct {
+	Projects []struct {
+		ID           json.RawMessage `json:"id"`
+		ParentID     json.RawMessage `json:"parent_id"`
+		Name         string          `json:"name"`
+		InboxProject bool            `json:"inbox_project"`
+		ChildOrder   int             `json:"child_order"`
+	} `json:"projects"`
+	Items     []todoistItem `json:"items"`
+	Completed struct {
+		Items []todoistItem `json:"items"`
+	} `json:"completed"`
+	Notes []struct {
+		ItemID  json.RawMessage `json:"item_id"`
+		Content string          `json:"content"`
+	} `json:"notes"`
+}
+
+type todoistItem struct {
+	ID          json.RawMessage `json:"id"`
+	TaskID      json.RawMessage `json:"task_id"` // 已完成任务接口中的任务 ID
+	ProjectID   json.RawMessage `json:"project_id"`
+	ParentID    json.RawMessage `json:"parent_id"`
+	Content     string          `json:"content"`
+	Description string          `json:"description"`
+	Priority    int             `json:"priority"` // 4 为最高的 p1，1 为无优先级
+	Labels      []string        `json:"labels"`
+	Checked     bool            `json:"checked"`
+	CompletedAt string          `json:"completed_at"`
+	AddedAt     string          `json:"added_at"`
+	ChildOrder  int             `json:"child_order"`
+	Due         *struct {
+		Date        string `json:"date"`
+		IsRecurring bool   `json:"is_recurring"`
+		String      string `json:"string"`
+		Timezone    string `json:"timezone"`
+	} `json:"due"`
+}
+
+// todoistID ID 在旧版 API 中为数字，新版中为字符串
+func todoistID(raw json.RawMessage) string {
+	value := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if value == "null" {
+		return ""
+	}
+	return value
+}
+
+// convertTodoistJSON 转换 Todoist Sync API 的数据。有子项目的顶级项目转为文件夹，
+// 其中的项目转为文件夹中的清单（更深的项目以 "父项目 / 子项目" 命名），顶级项目本身的任务放在同名清单中
+func convertTodoistJSON(b *externalBackup, data []byte) error {
+	var export todoistJSON
+	if err := json.Unmarshal(data, &export); err != nil {
+		return err
+	}
+	if export.Projects == nil && export.Items == nil && export.Completed.Items == nil {
+		return errors.New("no projects or items found, not a Todoist export")
+	}
+
+	type project struct {
+		parent string
+		name   string
+		inbox  bool
+	}
+	projects := make(map[string]project, len(export.Projects))
+	hasChildren := make(map[string]bool)
+	sort.SliceStable(export.Projects, func(i, j int) bool { return export.Projects[i].ChildOrder < export.Projects[j].ChildOrder })
+	for _, p := range export.Projects {
+		parent := todoistID(p.ParentID)
+		projects[todoistID(p.ID)] = project{parent: parent, name: p.Name, inbox: p.InboxProject}
+		if parent != "" {
+			hasChildren[parent] = true
+		}
+	}
+	listOf := func(projectID string) uint64 {
+		p, ok := projects[projectID]
+		if !ok || p.inbox {
+			return externalInboxID
+		}
+		// 找到顶级项目，路径上的项目名作为清单名
+		names := []string{p.name}
+		rootID := projectID
+		for seen := 0; p.parent != "" && seen < len(projects); seen++ {
+			parent, ok := projects[p.parent]
+			if !ok {
+				break
+			}
+			rootID = p.parent
+			p = parent
+			names = append([]string{p.name}, names...)
+		}
+		if !hasChildren[rootID] {
+			return b.list("", names[0])
+		}
+		if len(names) == 1 {
+			return b.list(names[0], names[0])
+		}
+		return b.list(names[0], strings.Join(names[1:], " / "))
+	}
+
+	notes := make(map[string][]string)
+	for _, note := range export.Notes {
+		if content := strings.TrimSpace(note.Content); content != "" {
+			notes[todoistID(note.ItemID)] = append(notes[todoistID(note.ItemID)], content)
+		}
+	}
+
+	items := export.Items
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		seen[todoistID(item.ID)] = true
+	}
+	// 已完成任务接口中的任务可能也在 items 中
+	for _, item := range export.Completed.Items {
+		if id := todoistID(item.TaskID); id != "" {
+			item.ID = item.TaskID
+		}
+		if !seen[todoistID(item.ID)] {
+			item.Checked = true
+			items = append(items, item)
+		}
+	}
+
+	taskIDs := make(map[string]uint64, len(items))
+	parents := make(map[int]string)
+	for _, item := range items {
+		title, contentLabels := todoistContent(item.Content)
+		if title == "" {
+			b.warn("todoist item %s: content is empty, skipped", todoistID(item.ID))
+			continue
+		}
+		task := models.Task{
+			Title:       title,
+			Description: strings.TrimSpace(strings.Join(append([]string{item.Description}, notes[todoistID(item.ID)]...), "\n\n")),
+			ListID:      listOf(todoistID(item.ProjectID)),
+			SortOrder:   item.ChildOrder,
+		}
+		if item.Priority >= 1 && item.Priority <= 4 {
+			task.Priority = item.Priority - 1
+		}
+		if added, ok := parseTimeLayouts(item.AddedAt, time.UTC, time.RFC3339Nano); ok {
+			task.CreatedAt = added
+		}
+		if item.Due != nil && item.Due.Date != "" {
+			loc := loadLocation(item.Due.Timezone, time.Local)
+			if due, ok := parseTimeLayouts(item.Due.Date, loc, todoistDateLayouts...); ok {
+				setTaskDue(&task, due, !strings.Contains(item.Due.Date, "T"))
+			} else {
+				b.warn("todoist task %q: unsupported due date %q", title, item.Due.Date)
+			}
+			if item.Due.IsRecurring {
+				rrule, anchor, _, ok := todoistRRule(item.Due.String)
+				if !ok || setTaskRecurrence(&task, rrule) != nil {
+					b.warn("todoist task %q: unsupported recurrence %q, imported as a one-time task", title, item.Due.String)
+				} else {
+					task.RecurrenceAnchor = anchor
+				}
+			}
+		}
+		if item.Checked || item.CompletedAt != "" {
+			completed, _ := parseTimeLayouts(item.CompletedAt, time.UTC, time.RFC3339Nano)
+			setTaskCompleted(&task, completed)
+		}
+
+		taskIDs[todoistID(item.ID)] = b.addTask(&task, append(item.Labels, contentLabels...))
+		if parentID := todoistID(item.ParentID); parentID != "" {
+			parents[len(b.backup.Tasks)-1] = parentID
+		}
+	}
+
+	// 子任务按父任务的 ID 关联，父任务可能出现在子任务之后
+	for index, parentID := range parents {
+		task := &b.backup.Tasks[index]
+		if id, ok := taskIDs[parentID]; ok {
+			task.ParentID = &id
+		} else {
+			b.warn("todoist task %q: parent task not found, imported as a top-level task", task.Title)
+		}
+	}
+	return nil
+}
+
+// todoistWeekdays 英文星期名称（全称或缩写）对应的 BYDAY
+var todoistWeekdays = map[string]string{
+	"mon": "MO", "monday": "MO", "tue": "TU", "tues": "TU", "tuesday": "TU", "wed": "WE", "wednesday": "WE",
+	"thu": "TH", "thur": "TH", "thurs": "TH", "thursday": "TH", "fri": "FR", "friday": "FR",
+	"sat": "SA", "saturday": "SA", "sun": "SU", "sunday": "SU",
+}
+
+// todoistOrdinals "every first monday" 等中的序数
+var todoistOrdinals = map[string]int{"first": 1, "1st": 1, "second": 2, "2nd": 2, "third": 3, "3rd": 3, "fourth": 4, "4th": 4, "last": -1}
+
+// todoistChinese 中文的重复日期
+var todoistChinese = map[string]string{
+	"每天": "FREQ=DAILY", "每日": "FREQ=DAILY", "每周": "FREQ=WEEKLY", "每星期": "FREQ=WEEKLY",
+	"每月": "FREQ=MONTHLY", "每年": "FREQ=YEARLY", "每个工作日": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+	"工作日": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+}
+
+// todoistTimeOfDay 重复日期中的时间，如 "at 9am"、"at 18:30"
+var todoistTimeOfDay = regexp.MustCompile(`\s+at\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+
+// todoistRRule 把 Todoist 常见的英文重复日期转换为 RRULE，如 "every 2 weeks"、"every mon, fri at 9am"、
+// "every! 3 days"（从完成日期计算）、"every 15th"、"every last friday"。返回 RRULE、重复锚点和时间
+func todoistRRule(value string) (rrule, anchor, dueTime string, ok bool) {
+	value = strings.ToLower(strings.Join(strings.Fields(value), " "))
+	if rule, found := todoistChinese[value]; found {
+		return rule, "", "", true
+	}
+	// 去掉起止日期，只保留规则
+	for _, marker := range []string{" starting ", " from ", " until ", " ending ", " for "} {
+		if i := strings.Index(value, marker); i > 0 {
+			value = value[:i]
+		}
+	}
+	if match := todoistTimeOfDay.FindStringSubmatch(value); match != nil {
+		hour, _ := strconv.Atoi(match[1])
+		minute, _ := strconv.Atoi(match[2])
+		if match[3] == "pm" && hour < 12 {
+			hour += 12
+		} else if match[3] == "am" && hour == 12 {
+			hour = 0
+		}
+		if hour > 23 || minute > 59 {
+			return "", "", "", false
+		}
+		dueTime = fmt.Sprintf("%02d:%02d", hour, minute)
+		value = value[:len(value)-len(match[0])]
+	}
+
+	switch value {
+	case "daily":
+		return "FREQ=DAILY", "", dueTime, true
+	case "weekly":
+		return "FREQ=WEEKLY", "", dueTime, true
+	case "monthly":
+		return "FREQ=MONTHLY", "", dueTime, true
+	case "yearly", "annually":
+		return "FREQ=YEARLY", "", dueTime, true
+	}
+	switch {
+	case strings.HasPrefix(value, "every! "):
+		anchor = RecurrenceAnchorCompletion
+		value = strings.TrimPrefix(value, "every! ")
+	case strings.HasPrefix(value, "after "):
+		anchor = RecurrenceAnchorCompletion
+		value = strings.TrimPrefix(value, "after ")
+	case strings.HasPrefix(value, "every "):
+		value = strings.TrimPrefix(value, "every ")
+	default:
+		return "", "", "", false
+	}
+
+	words := strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(words) == 0 {
+		return "", "", "", false
+	}
+	interval := 1
+	if n, err := strconv.Atoi(words[0]); err == nil && n > 0 && len(words) > 1 {
+		interval, words = n, words[1:]
+	} else if words[0] == "other" && len(words) > 1 {
+		interval, words = 2, words[1:]
+	}
+	rule := func(freq string) string {
+		if interval > 1 {
+			return fmt.Sprintf("FREQ=%s;INTERVAL=%d", freq, interval)
+		}
+		return "FREQ=" + freq
+	}
+
+	if len(words) == 1 {
+		switch strings.TrimSuffix(words[0], "s") {
+		case "day":
+			return rule("DAILY"), anchor, dueTime, true
+		case "week":
+			return rule("WEEKLY"), anchor, dueTime, true
+		case "month":
+			return rule("MONTHLY"), anchor, dueTime, true
+		case "year":
+			return rule("YEARLY"), anchor, dueTime, true
+		case "weekday", "workday":
+			return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", anchor, dueTime, true
+		case "weekend":
+			return "FREQ=WEEKLY;BYDAY=SA,SU", anchor, dueTime, true
+		}
+	}
+
+	// every 15th / every 1st, 15th / every last day
+	if strings.Join(words, " ") == "last day" {
+		return "FREQ=MONTHLY;BYMONTHDAY=-1", anchor, dueTime, true
+	}
+	var monthDays []string
+	for _, word := range words {
+		day, err := strconv.Atoi(strings.TrimRight(word, "stndrh"))
+		if err != nil || day < 1 || day > 31 {
+			monthDays = nil
+			break
+		}
+		monthDays = append(monthDays, strconv.Itoa(day))
+	}
+	if len(monthDays) > 0 {
+		return rule("MONTHLY") + ";BYMONTHDAY=" + strings.Join(monthDays, ","), anchor, dueTime, true
+	}
+
+	// every first monday / every last fri
+	if len(words) == 2 {
+		if n, found := todoistOrdinals[words[0]]; found {
+			if day, found := todoistWeekdays[words[1]]; found {
+				return rule("MONTHLY") + ";BYDAY=" + strconv.Itoa(n) + day, anchor, dueTime, true
+			}
+		}
+	}
+
+	// every mon, wed and fri
+	var days []string
+	for _, word := range words {
+		if word == "and" {
+			continue
+		}
+		day, found := todoistWeekdays[word]
+		if !found {
+			return "", "", "", false
+		}
+		days = append(days, day)
+	}
+	if len(days) == 0 {
+		return "", "", "", false
+	}
+	return rule("WEEKLY") + ";BYDAY=" + strings.Join(days, ","), anchor, dueTime, true
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"on-the-way/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 删除清单时任务的处理方式
+const (
+	ListDeleteModeInbox  = "inbox"  // 任务移到收集箱（默认）
+	ListDeleteModeMove   = "move"   // 任务移到指定清单
+	ListDeleteModeDelete = "delete" // 任务连同清单一起删除，进入回收站
+)
+
+// IsListDeleteMode 判断是否为有效的清单删除方式
+func IsListDeleteMode(mode string) bool {
+	switch mode {
+	case ListDeleteModeInbox, ListDeleteModeMove, ListDeleteModeDelete:
+		return true
+	}
+	return false
+}
+
+// ListDeleteOptions 删除清单的选项，TargetListID 为任务要移入的清单（inbox 模式下为收集箱）
+type ListDeleteOptions struct {
+	Mode         string
+	TargetListID uint64
+}
+
+// DeleteLists 在事务 tx 中删除清单并按 opts 处理其中的任务，同时清理清单的视图配置，返回被删除的任务 ID。
+// 移动模式下回收站中的任务也一并移动，重复系列跟随任务；删除模式下任务、子任务和检查项进入回收站，
+// 提醒被删除，标签关联保留到永久删除时清理，以便从回收站恢复任务或清单
+func DeleteLists(tx *gorm.DB, userID uint64, listIDs []uint64, opts ListDeleteOptions) ([]uint64, error) {
+	deletedTaskIDs := []uint64{}
+	if len(listIDs) == 0 {
+		return deletedTaskIDs, nil
+	}
+
+	// 先删除清单，随清单删除的视图配置和任务删除时间不早于清单，从回收站恢复清单时据此一并恢复
+	if err := tx.Where("id IN ? AND user_id = ?", listIDs, userID).Delete(&models.List{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("entity_type = ? AND entity_id IN ? AND user_id = ?", "list", listIDs, userID).Delete(&models.ViewConfig{}).Error; err != nil {
+		return nil, err
+	}
+
+	if opts.Mode == ListDeleteModeDelete {
+		if err := tx.Model(&models.Task{}).Where("list_id IN ? AND user_id = ?", listIDs, userID).Pluck("id", &deletedTaskIDs).Error; err != nil {
+			return nil, err
+		}
+		// 子任务可能在其他清单中，随父任务一起删除
+		current := deletedTaskIDs
+		for len(current) > 0 {
+			var children []uint64
+			if err := tx.Model(&models.Task{}).Where("parent_id IN ? AND user_id = ? AND list_id NOT IN ?", current, userID, listIDs).Pluck("id", &children).Error; err != nil {
+				return nil, err
+			}
+			deletedTaskIDs = append(deletedTaskIDs, children...)
+			current = children
+		}
+
+		if len(deletedTaskIDs) > 0 {
+			if err := tx.Where("id IN ?", deletedTaskIDs).Delete(&models.Task{}).Error; err != nil {
+				return nil, err
+			}
+			if err := tx.Where("task_id IN ?", deletedTaskIDs).Delete(&models.ChecklistItem{}).Error; err != nil {
+				return nil, err
+			}
+			if err := tx.Where("entity_type = ? AND entity_id IN ?", "task", deletedTaskIDs).Delete(&models.Reminder{}).Error; err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if err := tx.Unscoped().Model(&models.Task{}).
+			Where("list_id IN ? AND user_id = ?", listIDs, userID).
+			Update("list_id", opts.TargetListID).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.TaskSeries{}).
+			Where("list_id IN ? AND user_id = ?", listIDs, userID).
+			Update("list_id", opts.TargetListID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return deletedTaskIDs, nil
+}
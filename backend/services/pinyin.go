@@ -0,0 +1,254 @@
+package services
+
+import (
+	"on-the-way/backend/models"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// 拼音索引的实体类型（任务与全文索引共用 SearchEntityTask）
+const (
+	PinyinEntityList = "list"
+	PinyinEntityTag  = "tag"
+)
+
+// 拼音匹配的最短关键词长度，以及开始容错的长度
+const (
+	minPinyinTermLength = 2
+	minFuzzyTermLength  = 4
+)
+
+// pinyinOfRune 汉字到拼音的映射，由 pinyinSyllables 生成
+var pinyinOfRune = func() map[rune]string {
+	m := make(map[rune]string, 6763)
+	for _, line := range pinyinSyllables {
+		syllable, chars, _ := strings.Cut(line, " ")
+		for _, r := range chars {
+			m[r] = syllable
+		}
+	}
+	return m
+}()
+
+// pinyinUnit 文本中一个字符对应的拼音片段
+type pinyinUnit struct {
+	index   int    // 在原文中的字符下标
+	full    string // 全拼；字母数字为其小写形式
+	initial string // 首字母；字母数字与 full 相同
+}
+
+// pinyinUnits 将文本转换为拼音片段，标点和空白被忽略
+func pinyinUnits(runes []rune) []pinyinUnit {
+	units := make([]pinyinUnit, 0, len(runes))
+	for i, r := range runes {
+		if syllable, ok := pinyinOfRune[r]; ok {
+			units = append(units, pinyinUnit{index: i, full: syllable, initial: syllable[:1]})
+		} else if (unicode.IsLetter(r) || unicode.IsNumber(r)) && !isCJK(r) {
+			lower := string(unicode.ToLower(r))
+			units = append(units, pinyinUnit{index: i, full: lower, initial: lower})
+		}
+	}
+	return units
+}
+
+// ToPinyin 返回文本的全拼和首字母，如 "Q3 季度规划" 为 q3jiduguihua 和 q3jdgh；不在字库中的汉字被忽略
+func ToPinyin(text string) (full, initials string) {
+	var fb, ib strings.Builder
+	for _, unit := range pinyinUnits([]rune(text)) {
+		fb.WriteString(unit.full)
+		ib.WriteString(unit.initial)
+	}
+	return fb.String(), ib.String()
+}
+
+// normalizePinyinTerm 关键词只包含字母数字（可夹杂空格和标点）且含字母时，返回其小写并去掉分隔符的形式，否则返回空字符串
+func normalizePinyinTerm(keyword string) string {
+	var sb strings.Builder
+	hasLetter := false
+	for _, r := range keyword {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			hasLetter = hasLetter || unicode.IsLetter(r)
+			sb.WriteRune(unicode.ToLower(r))
+		case r >= unicode.MaxASCII:
+			return ""
+		}
+	}
+	if !hasLetter || sb.Len() < minPinyinTermLength {
+		return ""
+	}
+	return sb.String()
+}
+
+// reindexPinyin 重建单条记录的拼音索引，name 为空表示记录已删除
+func reindexPinyin(tx *gorm.DB, entityType string, entityID, userID uint64, name string) error {
+	if err := tx.Where("entity_type = ? AND entity_id = ?", entityType, entityID).Delete(&models.PinyinIndex{}).Error; err != nil {
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+
+	full, initials := ToPinyin(name)
+	if full == "" {
+		return nil
+	}
+	return tx.Create(&models.PinyinIndex{
+		UserID:     userID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Full:       full,
+		Initials:   initials,
+	}).Error
+}
+
+// reindexPinyinEntity 重建清单或标签的拼音索引
+func reindexPinyinEntity(tx *gorm.DB, entityType string, entityID uint64) error {
+	var userID uint64
+	var name string
+	switch entityType {
+	case PinyinEntityList:
+		var list models.List
+		if err := tx.Where("id = ?", entityID).First(&list).Error; err != nil {
+			if err := ignoreNotFound(err); err != nil {
+				return err
+			}
+		}
+		userID, name = list.UserID, list.Name
+	case PinyinEntityTag:
+		var tag models.Tag
+		if err := tx.Where("id = ?", entityID).First(&tag).Error; err != nil {
+			if err := ignoreNotFound(err); err != nil {
+				return err
+			}
+		}
+		userID, name = tag.UserID, tag.Name
+	default:
+		return nil
+	}
+	return reindexPinyin(tx, entityType, entityID, userID, name)
+}
+
+// MatchPinyinIndex 按拼音查找记录：关键词是全拼或首字母的一部分即匹配（如 jdgh、jiduguihua 匹配 "季度规划"），
+// 4 个字符以上的关键词允许少量拼写错误（如 jxgh、jiduguihau）；关键词不是纯字母数字时返回空。调用前需先 SyncSearchIndex
+func MatchPinyinIndex(db *gorm.DB, userID uint64, entityType, keyword string) ([]uint64, error) {
+	term := normalizePinyinTerm(keyword)
+	if term == "" {
+		return nil, nil
+	}
+
+	var rows []models.PinyinIndex
+	if err := db.Select("entity_id", "full", "initials").
+		Where("user_id = ? AND entity_type = ?", userID, entityType).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	maxDistance := fuzzyDistanceLimit(term)
+	var ids []uint64
+	for _, row := range rows {
+		if strings.Contains(row.Full, term) || strings.Contains(row.Initials, term) ||
+			(maxDistance > 0 && (fuzzySubstringDistance(term, row.Full, maxDistance) <= maxDistance ||
+				fuzzySubstringDistance(term, row.Initials, maxDistance) <= maxDistance)) {
+			ids = append(ids, row.EntityID)
+		}
+	}
+	return ids, nil
+}
+
+// fuzzyDistanceLimit 关键词允许的编辑距离：少于 4 个字符不容错，4-7 个字符容许 1 处，更长容许 2 处
+func fuzzyDistanceLimit(term string) int {
+	switch {
+	case len(term) < minFuzzyTermLength:
+		return 0
+	case len(term) < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// fuzzySubstringDistance 计算 pattern 与 text 中最相近的子串之间的编辑距离（插入、删除、替换、相邻交换各计 1），
+// 超过 limit 时提前返回 limit+1
+func fuzzySubstringDistance(pattern, text string, limit int) int {
+	m := len(pattern)
+	if m == 0 {
+		return 0
+	}
+
+	// prev2、prev、curr 分别为 DP 表的第 i-2、i-1、i 行；第 0 行全为 0，表示匹配可以从 text 的任意位置开始
+	prev2 := make([]int, len(text)+1)
+	prev := make([]int, len(text)+1)
+	curr := make([]int, len(text)+1)
+	for i := 1; i <= m; i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(text); j++ {
+			cost := 1
+			if pattern[i-1] == text[j-1] {
+				cost = 0
+			}
+			best := prev[j-1] + cost
+			if prev[j]+1 < best {
+				best = prev[j] + 1
+			}
+			if curr[j-1]+1 < best {
+				best = curr[j-1] + 1
+			}
+			if i > 1 && j > 1 && pattern[i-1] == text[j-2] && pattern[i-2] == text[j-1] && prev2[j-2]+1 < best {
+				best = prev2[j-2] + 1
+			}
+			curr[j] = best
+			if best < rowMin {
+				rowMin = best
+			}
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	best := prev[0]
+	for _, d := range prev {
+		if d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// markPinyinMatch 关键词与文本的全拼或首字母精确匹配时，标出对应的字符，返回是否匹配
+func markPinyinMatch(runes []rune, keyword string, marked []bool) bool {
+	term := normalizePinyinTerm(keyword)
+	if term == "" {
+		return false
+	}
+
+	units := pinyinUnits(runes)
+	for _, useInitials := range []bool{false, true} {
+		// owners[k] 为拼音串中第 k 个字节所属的字符下标
+		var sb strings.Builder
+		var owners []int
+		for _, unit := range units {
+			part := unit.full
+			if useInitials {
+				part = unit.initial
+			}
+			sb.WriteString(part)
+			for k := 0; k < len(part); k++ {
+				owners = append(owners, unit.index)
+			}
+		}
+
+		if pos := strings.Index(sb.String(), term); pos >= 0 {
+			for k := pos; k < pos+len(term); k++ {
+				marked[owners[k]] = true
+			}
+			return true
+		}
+	}
+	return false
+}
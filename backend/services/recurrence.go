@@ -2,83 +2,136 @@ package services
 
 import (
 	"encoding/json"
+	"fmt"
+	"on-the-way/backend/lunar"
 	"on-the-way/backend/models"
 	"on-the-way/backend/utils"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // RecurrenceService 重复任务服务
-type RecurrenceService struct{}
+type RecurrenceService struct {
+	holidays HolidayCalendar
+}
 
-// NewRecurrenceService 创建重复任务服务实例
-func NewRecurrenceService() *RecurrenceService {
-	return &RecurrenceService{}
+// NewRecurrenceService 创建重复任务服务实例，holidays 为 nil 时只按周末判断休息日
+func NewRecurrenceService(holidays HolidayCalendar) *RecurrenceService {
+	return &RecurrenceService{holidays: holidays}
 }
 
-// CalculateNextDueDate 根据重复规则计算下次截止日期
+// maxExDateSkips 连续跳过例外日期的上限，防止异常数据导致死循环
+const maxExDateSkips = 1000
+
+// CalculateNextDueDate 根据重复规则计算下次截止日期，跳过例外日期（EXDATE）
 func (s *RecurrenceService) CalculateNextDueDate(task *models.Task, fromDate time.Time) (*time.Time, error) {
-	if !task.IsRecurring || task.RecurrenceType == "" {
+	exDates := ParseExDates(task.RecurrenceExDates)
+
+	for i := 0; i < maxExDateSkips; i++ {
+		nextDate, err := s.calculateNext(task, fromDate)
+		if err != nil || nextDate == nil {
+			return nextDate, err
+		}
+		if !containsString(exDates, utils.FormatDate(*nextDate)) {
+			return nextDate, nil
+		}
+		fromDate = *nextDate
+	}
+	return nil, nil
+}
+
+// Occurrences 返回从 from 开始（含）的 count 个截止日期，从任务当前截止日期起沿重复规则推算；
+// 没有截止日期时从 from 当天或之后第一个符合规则的日期开始
+func (s *RecurrenceService) Occurrences(task *models.Task, from time.Time, count int) ([]time.Time, error) {
+	if !task.IsRecurring || count <= 0 {
 		return nil, nil
 	}
 
-	var nextDate time.Time
+	var current time.Time
+	if dueDate, err := utils.ParseDate(task.DueDate); task.DueDate != "" && err == nil {
+		current = dueDate
+	} else {
+		first, err := s.CalculateNextDueDate(task, from.AddDate(0, 0, -1))
+		if err != nil || first == nil {
+			return nil, err
+		}
+		current = *first
+	}
 
-	switch task.RecurrenceType {
-	case "daily":
-		// 每天重复
-		nextDate = fromDate.AddDate(0, 0, task.RecurrenceInterval)
+	exDates := ParseExDates(task.RecurrenceExDates)
+	var occurrences []time.Time
+	if !current.Before(from) && !containsString(exDates, utils.FormatDate(current)) {
+		occurrences = append(occurrences, current)
+	}
 
-	case "weekly":
-		// 每周重复
-		if task.RecurrenceWeekdays != "" {
-			// 解析指定的星期几
-			var weekdays []int
-			if err := json.Unmarshal([]byte(task.RecurrenceWeekdays), &weekdays); err == nil && len(weekdays) > 0 {
-				nextDate = s.findNextWeekday(fromDate, weekdays)
-			} else {
-				// 如果没有指定星期几，默认按周重复
-				nextDate = fromDate.AddDate(0, 0, 7*task.RecurrenceInterval)
-			}
-		} else {
-			nextDate = fromDate.AddDate(0, 0, 7*task.RecurrenceInterval)
+	for len(occurrences) < count {
+		next, err := s.CalculateNextDueDate(task, current)
+		if err != nil {
+			return nil, err
 		}
-
-	case "monthly":
-		// 每月重复
-		if task.RecurrenceMonthDay > 0 {
-			// 指定每月第几天
-			nextDate = s.findNextMonthDay(fromDate, task.RecurrenceMonthDay, task.RecurrenceInterval)
-		} else {
-			// 默认按月重复（相同日期）
-			nextDate = fromDate.AddDate(0, task.RecurrenceInterval, 0)
+		if next == nil {
+			break
+		}
+		if !next.Before(from) {
+			occurrences = append(occurrences, *next)
 		}
+		current = *next
+	}
 
-	case "yearly":
-		// 每年重复
-		nextDate = fromDate.AddDate(task.RecurrenceInterval, 0, 0)
+	return occurrences, nil
+}
 
-	case "workday":
-		// 工作日重复（周一到周五）
-		nextDate = s.findNextWorkday(fromDate)
+// calculateNext 按重复规则计算 fromDate 之后的下一个日期，不考虑例外日期
+func (s *RecurrenceService) calculateNext(task *models.Task, fromDate time.Time) (*time.Time, error) {
+	if !task.IsRecurring || (task.RecurrenceType == "" && task.RRule == "") {
+		return nil, nil
+	}
 
-	case "holiday":
-		// 节假日重复（需要节假日API，暂时返回周末）
-		nextDate = s.findNextHoliday(fromDate)
+	var nextDate time.Time
 
-	case "lunar_monthly":
-		// 农历每月重复（需要农历库，暂时按阳历处理）
-		nextDate = fromDate.AddDate(0, task.RecurrenceInterval, 0)
+	rule, dtstart, err := s.ResolveRRule(task, fromDate)
+	if err != nil {
+		return nil, err
+	}
 
-	case "lunar_yearly":
-		// 农历每年重复（需要农历库，暂时按阳历处理）
-		nextDate = fromDate.AddDate(task.RecurrenceInterval, 0, 0)
+	if rule != nil {
+		next, ok := rule.After(dtstart, fromDate)
+		if !ok {
+			return nil, nil
+		}
+		nextDate = next
+	} else {
+		// 无法用 RRULE 表达的重复类型
+		switch task.RecurrenceType {
+		case "workday":
+			// 工作日重复（跳过法定节假日，包含调休上班日）
+			nextDate = s.findNextWorkday(fromDate)
 
-	case "custom":
-		// 自定义间隔（按天计算）
-		nextDate = fromDate.AddDate(0, 0, task.RecurrenceInterval)
+		case "holiday":
+			// 节假日重复（法定节假日和非调休的周末）
+			nextDate = s.findNextHoliday(fromDate)
 
-	default:
-		return nil, nil
+		case "lunar_monthly":
+			// 农历每月重复
+			next, ok := s.findNextLunarMonthly(fromDate, task.RecurrenceLunarDate, task.RecurrenceInterval)
+			if !ok {
+				return nil, nil
+			}
+			nextDate = next
+
+		case "lunar_yearly":
+			// 农历每年重复
+			next, ok := s.findNextLunarYearly(fromDate, task.RecurrenceLunarDate, task.RecurrenceInterval)
+			if !ok {
+				return nil, nil
+			}
+			nextDate = next
+
+		default:
+			return nil, nil
+		}
 	}
 
 	// 检查是否超过结束日期
@@ -92,151 +145,428 @@ func (s *RecurrenceService) CalculateNextDueDate(task *models.Task, fromDate tim
 	return &nextDate, nil
 }
 
-// findNextWeekday 查找下一个符合条件的星期几
-func (s *RecurrenceService) findNextWeekday(fromDate time.Time, weekdays []int) time.Time {
-	// weekdays: 0=周日, 1=周一, ..., 6=周六
-	
-	// 先尝试当天之后的本周日期
-	for i := 1; i <= 7; i++ {
-		nextDate := fromDate.AddDate(0, 0, i)
-		nextWeekday := int(nextDate.Weekday())
-		for _, wd := range weekdays {
-			if nextWeekday == wd {
-				return nextDate
+// ResolveRRule 返回任务的重复规则及其起始时间（DTSTART）
+// 设置了 RRule 时直接解析；否则将旧版重复字段转换为等价规则，以 fromDate 作为起始时间。
+// workday、holiday、农历等无法用 RRULE 表达的类型返回 nil
+func (s *RecurrenceService) ResolveRRule(task *models.Task, fromDate time.Time) (*utils.RRule, time.Time, error) {
+	dtstart := fromDate
+
+	value := task.RRule
+	if value != "" {
+		// 从系列起始日期开始计算，保证 INTERVAL 对齐和 COUNT 计数正确
+		if task.RecurrenceStart != "" {
+			if start, err := utils.ParseDate(task.RecurrenceStart); err == nil {
+				dtstart = time.Date(start.Year(), start.Month(), start.Day(),
+					fromDate.Hour(), fromDate.Minute(), fromDate.Second(), 0, fromDate.Location())
 			}
 		}
+	} else {
+		value = LegacyRRule(task, fromDate)
+		if value == "" {
+			return nil, dtstart, nil
+		}
 	}
 
-	// 如果没有找到，返回下周的第一个匹配日
-	for i := 1; i <= 14; i++ {
-		nextDate := fromDate.AddDate(0, 0, i)
-		nextWeekday := int(nextDate.Weekday())
-		for _, wd := range weekdays {
-			if nextWeekday == wd {
-				return nextDate
+	rule, err := utils.ParseRRule(value)
+	if err != nil {
+		return nil, dtstart, err
+	}
+	return rule, dtstart, nil
+}
+
+// LegacyRRule 将旧版重复字段转换为 RRULE，dtstart 为起始日期
+// 按月、按年重复的日期取自规则起始日期（RecurrenceStart），没有时取 dtstart，
+// 避免上一个实例在短月份被截断（如 31 日变为 28 日）后一直沿用截断后的日期
+// 无法转换的类型（workday、holiday、农历）返回空字符串
+func LegacyRRule(task *models.Task, dtstart time.Time) string {
+	interval := task.RecurrenceInterval
+	if interval < 1 {
+		interval = 1
+	}
+
+	anchor := dtstart
+	if task.RecurrenceStart != "" {
+		if start, err := utils.ParseDate(task.RecurrenceStart); err == nil {
+			anchor = start
+		}
+	}
+
+	var parts []string
+	switch task.RecurrenceType {
+	case "daily", "custom":
+		parts = append(parts, "FREQ=DAILY")
+
+	case "weekly":
+		parts = append(parts, "FREQ=WEEKLY")
+		// weekdays: 0=周日, 1=周一, ..., 6=周六
+		var weekdays []int
+		if task.RecurrenceWeekdays != "" && json.Unmarshal([]byte(task.RecurrenceWeekdays), &weekdays) == nil {
+			var days []string
+			for _, wd := range weekdays {
+				if wd >= 0 && wd <= 6 {
+					days = append(days, legacyWeekdayNames[wd])
+				}
 			}
+			if len(days) > 0 {
+				parts = append(parts, "BYDAY="+strings.Join(days, ","))
+			}
+		}
+
+	case "monthly":
+		parts = append(parts, "FREQ=MONTHLY")
+		day := task.RecurrenceMonthDay
+		if day <= 0 {
+			day = anchor.Day()
+		}
+		parts = append(parts, monthDayRule(day))
+
+	case "yearly":
+		parts = append(parts, "FREQ=YEARLY")
+		// 2月29日在平年落到2月28日
+		if anchor.Month() == time.February && anchor.Day() == 29 {
+			parts = append(parts, "BYMONTH=2", monthDayRule(29))
+		}
+
+	default:
+		return ""
+	}
+
+	if interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(interval))
+	}
+	if task.RecurrenceEndDate != "" {
+		if _, err := utils.ParseDate(task.RecurrenceEndDate); err == nil {
+			parts = append(parts, "UNTIL="+task.RecurrenceEndDate)
 		}
 	}
 
-	// 兜底：返回明天
-	return fromDate.AddDate(0, 0, 1)
+	return strings.Join(parts, ";")
 }
 
-// findNextMonthDay 查找下一个每月指定日期
-func (s *RecurrenceService) findNextMonthDay(fromDate time.Time, dayOfMonth int, interval int) time.Time {
-	year := fromDate.Year()
-	month := fromDate.Month()
-	
-	// 尝试下一个月
-	month += time.Month(interval)
-	for month > 12 {
-		month -= 12
-		year++
+var legacyWeekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// monthDayRule 每月第 day 天，当月没有这一天时取当月最后一天
+// 如 31 号转换为 BYMONTHDAY=28,29,30,31;BYSETPOS=-1
+func monthDayRule(day int) string {
+	if day <= 28 {
+		return "BYMONTHDAY=" + strconv.Itoa(day)
 	}
-	
-	// 获取该月的最后一天
-	lastDay := time.Date(year, month+1, 0, fromDate.Hour(), fromDate.Minute(), fromDate.Second(), 0, fromDate.Location()).Day()
-	
-	// 如果指定的日期超过该月最后一天，使用最后一天
-	actualDay := dayOfMonth
-	if actualDay > lastDay {
-		actualDay = lastDay
+	if day > 31 {
+		day = 31
 	}
-	
-	nextDate := time.Date(year, month, actualDay, fromDate.Hour(), fromDate.Minute(), fromDate.Second(), 0, fromDate.Location())
-	
-	// 如果计算出的日期还在过去，再加一个间隔
-	if nextDate.Before(fromDate) {
-		return s.findNextMonthDay(nextDate, dayOfMonth, interval)
+	days := make([]string, 0, day-27)
+	for d := 28; d <= day; d++ {
+		days = append(days, strconv.Itoa(d))
 	}
-	
-	return nextDate
+	return "BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+}
+
+// parseLunarDate 解析 RecurrenceLunarDate（格式："MM-DD"），为空时使用 fromDate 对应的农历日期
+func parseLunarDate(lunarDate string, fromDate time.Time) (month, day int, ok bool) {
+	if lunarDate == "" {
+		current, err := lunar.FromSolar(fromDate)
+		if err != nil {
+			return 0, 0, false
+		}
+		return current.Month, current.Day, true
+	}
+
+	if _, err := fmt.Sscanf(lunarDate, "%d-%d", &month, &day); err != nil {
+		return 0, 0, false
+	}
+	if month < 1 || month > 12 || day < 1 || day > 30 {
+		return 0, 0, false
+	}
+	return month, day, true
+}
+
+// lunarToSolar 农历转公历并保留 fromDate 的时刻，日期超过当月天数时取当月最后一天（如三十在小月取廿九）
+func lunarToSolar(year, month int, leap bool, day int, fromDate time.Time) (time.Time, bool) {
+	if days := lunar.MonthDays(year, month, leap); day > days {
+		day = days
+	}
+	solar, err := lunar.ToSolar(lunar.Date{Year: year, Month: month, Day: day, IsLeap: leap})
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(solar.Year(), solar.Month(), solar.Day(),
+		fromDate.Hour(), fromDate.Minute(), fromDate.Second(), 0, fromDate.Location()), true
+}
+
+// findNextLunarMonthly 查找下一个农历每月的指定日（闰月也算一个月）
+func (s *RecurrenceService) findNextLunarMonthly(fromDate time.Time, lunarDate string, interval int) (time.Time, bool) {
+	_, day, ok := parseLunarDate(lunarDate, fromDate)
+	if !ok {
+		return time.Time{}, false
+	}
+	current, err := lunar.FromSolar(fromDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if interval < 1 {
+		interval = 1
+	}
+
+	year, month, leap := current.Year, current.Month, current.IsLeap
+	for year <= lunar.MaxYear {
+		next, ok := lunarToSolar(year, month, leap, day, fromDate)
+		if !ok {
+			return time.Time{}, false
+		}
+		if next.After(fromDate) {
+			return next, true
+		}
+		year, month, leap = lunar.AddMonths(year, month, leap, interval)
+	}
+	return time.Time{}, false
+}
+
+// findNextLunarYearly 查找下一个农历每年的指定月日（如生日、传统节日），闰年使用非闰的同名月
+func (s *RecurrenceService) findNextLunarYearly(fromDate time.Time, lunarDate string, interval int) (time.Time, bool) {
+	month, day, ok := parseLunarDate(lunarDate, fromDate)
+	if !ok {
+		return time.Time{}, false
+	}
+	current, err := lunar.FromSolar(fromDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if interval < 1 {
+		interval = 1
+	}
+
+	for year := current.Year; year <= lunar.MaxYear; year += interval {
+		next, ok := lunarToSolar(year, month, false, day, fromDate)
+		if !ok {
+			return time.Time{}, false
+		}
+		if next.After(fromDate) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
 }
 
-// findNextWorkday 查找下一个工作日（周一到周五）
+// findNextWorkday 查找下一个工作日
 func (s *RecurrenceService) findNextWorkday(fromDate time.Time) time.Time {
 	nextDate := fromDate.AddDate(0, 0, 1)
-	
-	// 跳过周末
-	for {
-		weekday := nextDate.Weekday()
-		if weekday != time.Saturday && weekday != time.Sunday {
-			return nextDate
-		}
+	for !IsWorkday(s.holidays, nextDate) {
 		nextDate = nextDate.AddDate(0, 0, 1)
 	}
+	return nextDate
 }
 
-// findNextHoliday 查找下一个节假日（暂时返回周末）
+// findNextHoliday 查找下一个休息日
 func (s *RecurrenceService) findNextHoliday(fromDate time.Time) time.Time {
 	nextDate := fromDate.AddDate(0, 0, 1)
-	
-	// 查找下一个周六或周日
-	for {
-		weekday := nextDate.Weekday()
-		if weekday == time.Saturday || weekday == time.Sunday {
-			return nextDate
-		}
+	for !IsOffDay(s.holidays, nextDate) {
 		nextDate = nextDate.AddDate(0, 0, 1)
 	}
+	return nextDate
+}
+
+// 重复锚点：下一次日期的计算起点
+const (
+	RecurrenceAnchorDue        = "due"        // 从原截止日期计算（默认）
+	RecurrenceAnchorCompletion = "completion" // 从实际完成日期计算，如"浇花每 3 天一次"
+)
+
+// 逾期补偿策略：完成已逾期的实例时如何处理错过的日期
+const (
+	RecurrenceCatchUpNone = ""     // 只生成下一个日期，即使它也已过期（默认）
+	RecurrenceCatchUpSkip = "skip" // 跳过错过的日期，生成今天或之后的第一个
+	RecurrenceCatchUpEach = "each" // 每个错过的日期生成一个实例，另加今天或之后的第一个
+)
+
+// maxCatchUpInstances 一次补偿最多生成的实例数
+const maxCatchUpInstances = 100
+
+// ValidRecurrenceAnchor 是否为有效的重复锚点，空字符串等同于 due
+func ValidRecurrenceAnchor(anchor string) bool {
+	return anchor == "" || anchor == RecurrenceAnchorDue || anchor == RecurrenceAnchorCompletion
+}
+
+// ValidRecurrenceCatchUp 是否为有效的逾期补偿策略
+func ValidRecurrenceCatchUp(catchUp string) bool {
+	return catchUp == RecurrenceCatchUpNone || catchUp == RecurrenceCatchUpSkip || catchUp == RecurrenceCatchUpEach
 }
 
-// GenerateNextRecurringTask 生成下一个重复任务实例
-func (s *RecurrenceService) GenerateNextRecurringTask(completedTask *models.Task) (*models.Task, error) {
+// GenerateNextRecurringTasks 生成下一个（或按补偿策略生成多个）重复任务实例
+// series 不为 nil 时，内容和重复规则以系列模板为准；existingDates 为系列中已存在实例的截止日期，不会重复生成
+func (s *RecurrenceService) GenerateNextRecurringTasks(completedTask *models.Task, series *models.TaskSeries, existingDates map[string]bool) ([]*models.Task, error) {
 	if !completedTask.IsRecurring {
 		return nil, nil
 	}
 
-	// 计算下次截止日期
-	baseDate := utils.Now()
-	if completedTask.DueDate != "" {
-		parsedDate, err := utils.ParseDate(completedTask.DueDate)
-		if err == nil {
+	template := *completedTask
+	if series != nil {
+		ApplySeriesTemplate(series, &template)
+		if !template.IsRecurring {
+			return nil, nil
+		}
+	}
+
+	today, _ := utils.ParseDate(utils.FormatDate(utils.Now()))
+
+	// 计算起点：默认为原截止日期，按完成日期重复时为完成日期
+	baseDate := today
+	rule := template
+	if template.RecurrenceAnchor == RecurrenceAnchorCompletion {
+		if len(completedTask.CompletedAt) >= 8 {
+			if completedDate, err := utils.ParseDate(completedTask.CompletedAt[:8]); err == nil {
+				baseDate = completedDate
+			}
+		}
+		// 规则从完成日期重新起算，不再对齐系列起始日期
+		rule.RecurrenceStart = ""
+	} else if completedTask.DueDate != "" {
+		if parsedDate, err := utils.ParseDate(completedTask.DueDate); err == nil {
 			baseDate = parsedDate
 		}
 	}
 
-	nextDueDate, err := s.CalculateNextDueDate(completedTask, baseDate)
-	if err != nil || nextDueDate == nil {
-		return nil, err
+	var dueDates []time.Time
+	for len(dueDates) < maxCatchUpInstances {
+		nextDueDate, err := s.CalculateNextDueDate(&rule, baseDate)
+		if err != nil {
+			return nil, err
+		}
+		if nextDueDate == nil {
+			break
+		}
+		baseDate = *nextDueDate
+
+		missed := nextDueDate.Before(today)
+		if missed && template.RecurrenceCatchUp == RecurrenceCatchUpSkip {
+			continue
+		}
+		dueDates = append(dueDates, *nextDueDate)
+		if !missed || template.RecurrenceCatchUp != RecurrenceCatchUpEach {
+			break
+		}
+	}
+
+	var tasks []*models.Task
+	for _, dueDate := range dueDates {
+		if existingDates[utils.FormatDate(dueDate)] {
+			continue
+		}
+		tasks = append(tasks, newRecurringInstance(completedTask, &template, dueDate))
 	}
+	return tasks, nil
+}
 
-	// 创建新任务实例
-	newTask := &models.Task{
-		UserID:              completedTask.UserID,
-		ListID:              completedTask.ListID,
-		Title:               completedTask.Title,
-		Description:         completedTask.Description,
-		Priority:            completedTask.Priority,
+// newRecurringInstance 按模板创建截止日期为 dueDate 的新实例
+func newRecurringInstance(completedTask, template *models.Task, dueDate time.Time) *models.Task {
+	task := &models.Task{
+		UserID:              template.UserID,
+		ListID:              template.ListID,
+		Title:               template.Title,
+		Description:         template.Description,
+		Priority:            template.Priority,
 		Status:              "todo",
-		DueDate:             utils.FormatDate(*nextDueDate),
-		DueTime:             completedTask.DueTime, // 保持相同的时间
+		DueDate:             utils.FormatDate(dueDate),
+		DueTime:             template.DueTime, // 保持相同的时间
 		IsRecurring:         true,
-		RecurrenceType:      completedTask.RecurrenceType,
-		RecurrenceInterval:  completedTask.RecurrenceInterval,
-		RecurrenceWeekdays:  completedTask.RecurrenceWeekdays,
-		RecurrenceMonthDay:  completedTask.RecurrenceMonthDay,
-		RecurrenceLunarDate: completedTask.RecurrenceLunarDate,
-		RecurrenceEndDate:   completedTask.RecurrenceEndDate,
+		RecurrenceType:      template.RecurrenceType,
+		RecurrenceInterval:  template.RecurrenceInterval,
+		RecurrenceWeekdays:  template.RecurrenceWeekdays,
+		RecurrenceMonthDay:  template.RecurrenceMonthDay,
+		RecurrenceLunarDate: template.RecurrenceLunarDate,
+		RecurrenceEndDate:   template.RecurrenceEndDate,
+		RRule:               template.RRule,
+		RecurrenceStart:     template.RecurrenceStart,
+		RecurrenceAnchor:    template.RecurrenceAnchor,
+		RecurrenceCatchUp:   template.RecurrenceCatchUp,
+		RecurrenceExDates:   FormatExDates(pruneExDates(ParseExDates(completedTask.RecurrenceExDates), dueDate)),
 		ParentTaskID:        &completedTask.ID,
+		SeriesID:            completedTask.SeriesID,
 	}
-
 	// 计算提醒时间（如果原任务有提醒）
-	if completedTask.ReminderTime != "" && completedTask.DueDate != "" {
-		// 解析原任务的提醒时间和截止时间
-		reminderDateTime, err1 := utils.ParseDateTime(completedTask.ReminderTime)
-		dueDateTime, err2 := utils.ParseDate(completedTask.DueDate)
-		
-		if err1 == nil && err2 == nil {
-			// 计算时间差
-			reminderOffset := dueDateTime.Sub(reminderDateTime)
-			
-			// 应用到新的截止日期
-			newReminderTime := nextDueDate.Add(-reminderOffset)
-			newTask.ReminderTime = utils.FormatDateTime(newReminderTime)
-		}
-	}
-
-	return newTask, nil
+	task.ReminderTime = ShiftReminderTime(completedTask, dueDate)
+
+	return task
+}
+
+// ShiftReminderTime 截止日期变为 nextDueDate 后，按原提醒与截止日期的间隔计算新的提醒时间
+func ShiftReminderTime(task *models.Task, nextDueDate time.Time) string {
+	if task.ReminderTime == "" || task.DueDate == "" {
+		return ""
+	}
+
+	// 解析原任务的提醒时间和截止时间
+	reminderDateTime, err1 := utils.ParseDateTime(task.ReminderTime)
+	dueDateTime, err2 := utils.ParseDate(task.DueDate)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+
+	// 计算时间差并应用到新的截止日期
+	reminderOffset := dueDateTime.Sub(reminderDateTime)
+	return utils.FormatDateTime(nextDueDate.Add(-reminderOffset))
+}
+
+// ParseExDates 解析例外日期列表（JSON数组，如 ["20251105","20251112"]）
+func ParseExDates(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var dates []string
+	if err := json.Unmarshal([]byte(value), &dates); err != nil {
+		return nil
+	}
+	return dates
+}
+
+// FormatExDates 将例外日期列表排序后序列化为 JSON 数组，空列表返回空字符串
+func FormatExDates(dates []string) string {
+	if len(dates) == 0 {
+		return ""
+	}
+	sort.Strings(dates)
+	data, _ := json.Marshal(dates)
+	return string(data)
+}
+
+// AddExDate 将日期加入任务的例外日期列表
+func AddExDate(task *models.Task, date string) {
+	dates := ParseExDates(task.RecurrenceExDates)
+	if date == "" || containsString(dates, date) {
+		return
+	}
+	task.RecurrenceExDates = FormatExDates(append(dates, date))
+}
+
+// RemoveExDate 从任务的例外日期列表中移除日期
+func RemoveExDate(task *models.Task, date string) {
+	dates := ParseExDates(task.RecurrenceExDates)
+	result := dates[:0]
+	for _, d := range dates {
+		if d != date {
+			result = append(result, d)
+		}
+	}
+	task.RecurrenceExDates = FormatExDates(result)
+}
+
+// pruneExDates 丢弃早于 from 的例外日期，避免列表随重复次数无限增长
+func pruneExDates(dates []string, from time.Time) []string {
+	fromStr := utils.FormatDate(from)
+	var result []string
+	for _, d := range dates {
+		if d >= fromStr {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
 }
 
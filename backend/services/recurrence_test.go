@@ -0,0 +1,71 @@
+package services
+
+import (
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"testing"
+)
+
+// TestCalculateNextDueDateMonthEnd 沿重复规则逐个生成实例，短月份截断后的日期不影响之后的实例
+func TestCalculateNextDueDateMonthEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		task models.Task
+		want []string
+	}{
+		{
+			name: "legacy monthly from Jan 31",
+			task: models.Task{RecurrenceType: "monthly", DueDate: "20250131", RecurrenceStart: "20250131"},
+			want: []string{"20250228", "20250331", "20250430", "20250531"},
+		},
+		{
+			name: "legacy monthly on day 30",
+			task: models.Task{RecurrenceType: "monthly", RecurrenceMonthDay: 30, DueDate: "20250130", RecurrenceStart: "20250130"},
+			want: []string{"20250228", "20250330", "20250430"},
+		},
+		{
+			name: "legacy every two months from Dec 31",
+			task: models.Task{RecurrenceType: "monthly", RecurrenceInterval: 2, DueDate: "20241231", RecurrenceStart: "20241231"},
+			want: []string{"20250228", "20250430", "20250630"},
+		},
+		{
+			name: "legacy yearly from Feb 29",
+			task: models.Task{RecurrenceType: "yearly", DueDate: "20240229", RecurrenceStart: "20240229"},
+			want: []string{"20250228", "20260228", "20270228", "20280229"},
+		},
+		{
+			name: "rrule last day of month",
+			task: models.Task{RRule: "FREQ=MONTHLY;BYMONTHDAY=-1", DueDate: "20250131", RecurrenceStart: "20250131"},
+			want: []string{"20250228", "20250331", "20250430"},
+		},
+	}
+
+	service := NewRecurrenceService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := tt.task
+			task.IsRecurring = true
+			var got []string
+			for range tt.want {
+				from, err := utils.ParseDate(task.DueDate)
+				if err != nil {
+					t.Fatal(err)
+				}
+				next, err := service.CalculateNextDueDate(&task, from)
+				if err != nil || next == nil {
+					t.Fatalf("CalculateNextDueDate from %s = %v, %v", task.DueDate, next, err)
+				}
+				task.DueDate = utils.FormatDate(*next)
+				got = append(got, task.DueDate)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("due dates = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("due dates = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
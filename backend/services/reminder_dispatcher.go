@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 提醒状态
+const (
+	ReminderStatusPending = "pending" // 等待投递
+	ReminderStatusSent    = "sent"    // 投递成功
+	ReminderStatusFailed  = "failed"  // 投递失败，等待重试
+	ReminderStatusDead    = "dead"    // 重试耗尽或不可恢复的失败（死信）
+)
+
+// Clock 时钟接口，便于测试时注入假时钟
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return utils.Now()
+}
+
+// SystemClock 使用系统时间的时钟
+var SystemClock Clock = systemClock{}
+
+// ReminderChannel 提醒投递渠道，按 ReminderType 注册到调度器
+type ReminderChannel interface {
+	// Type 返回渠道对应的提醒类型，如 email、wechat
+	Type() string
+	// Send 投递一条提醒，返回错误时调度器会按指数退避重试
+	Send(ctx context.Context, reminder *models.Reminder) error
+}
+
+// PermanentError 不可恢复的投递错误，调度器遇到后直接进入死信状态
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent 将错误标记为不可重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent 判断错误是否不可重试
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// ReminderDispatcherOptions 调度器配置
+type ReminderDispatcherOptions struct {
+	Interval    time.Duration // 扫描间隔
+	BatchSize   int           // 每轮最多处理的提醒数
+	MaxRetries  int           // 最大重试次数，超过后进入死信状态
+	BaseBackoff time.Duration // 首次重试等待时间，之后按指数增长
+	MaxBackoff  time.Duration // 重试等待时间上限
+	SendTimeout time.Duration // 单次投递超时时间
+}
+
+// ReminderDispatcher 后台提醒调度器，定时扫描到期提醒并通过对应渠道投递
+type ReminderDispatcher struct {
+	db       *gorm.DB
+	clock    Clock
+	opts     ReminderDispatcherOptions
+	mu       sync.RWMutex
+	channels map[string]ReminderChannel
+
+	// 弹窗提醒通过事件中心推送，pushed 记录已推送的提醒及其提醒时间
+	events *EventHub
+	pushed map[uint64]string
+}
+
+// NewReminderDispatcher 创建提醒调度器实例
+func NewReminderDispatcher(db *gorm.DB, opts ReminderDispatcherOptions, clock Clock) *ReminderDispatcher {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = time.Minute
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.SendTimeout <= 0 {
+		opts.SendTimeout = 15 * time.Second
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	return &ReminderDispatcher{
+		db:       db,
+		clock:    clock,
+		opts:     opts,
+		channels: make(map[string]ReminderChannel),
+		pushed:   make(map[uint64]string),
+	}
+}
+
+// SetEventHub 设置事件中心，到期的弹窗提醒会实时推送给在线设备
+func (d *ReminderDispatcher) SetEventHub(hub *EventHub) {
+	d.events = hub
+}
+
+// RegisterChannel 注册投递渠道，同类型的渠道会被覆盖
+func (d *ReminderDispatcher) RegisterChannel(channel ReminderChannel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels[channel.Type()] = channel
+}
+
+// Run 启动调度循环，直到 ctx 被取消
+func (d *ReminderDispatcher) Run(ctx context.Context) {
+	utils.LogInfo("Reminder dispatcher started", zap.Duration("interval", d.opts.Interval))
+
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchDue(ctx); err != nil && ctx.Err() == nil {
+			utils.LogError("Failed to dispatch reminders", zap.Error(err))
+		}
+		if err := d.PushPopups(ctx); err != nil && ctx.Err() == nil {
+			utils.LogError("Failed to push popup reminders", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			utils.LogInfo("Reminder dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue 执行一轮扫描：投递所有已到期的提醒，返回本轮处理的提醒数
+func (d *ReminderDispatcher) DispatchDue(ctx context.Context) (int, error) {
+	types := d.channelTypes()
+	if len(types) == 0 {
+		return 0, nil
+	}
+
+	now := utils.FormatDateTime(d.clock.Now())
+
+	// 待投递：到达提醒时间的 pending 提醒，以及到达重试时间的 failed 提醒
+	var reminders []models.Reminder
+	err := d.db.WithContext(ctx).
+		Where("reminder_type IN ?", types).
+		Where("(status = ? AND reminder_time <= ?) OR (status = ? AND next_retry_at <= ?)",
+			ReminderStatusPending, now, ReminderStatusFailed, now).
+		Order("reminder_time ASC").
+		Limit(d.opts.BatchSize).
+		Find(&reminders).Error
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range reminders {
+		if ctx.Err() != nil {
+			break
+		}
+		d.deliver(ctx, &reminders[i])
+		processed++
+	}
+
+	return processed, nil
+}
+
+// popupWindow 弹窗提醒的有效窗口，与 /reminders/active 的查询范围保持一致
+const popupWindow = 5 * time.Minute
+
+// PushPopups 将到期的弹窗提醒推送到用户的在线设备
+// 弹窗提醒仍由客户端确认后标记为已发送，这里只负责推送一次，避免重复弹窗
+func (d *ReminderDispatcher) PushPopups(ctx context.Context) error {
+	if d.events == nil {
+		return nil
+	}
+
+	now := d.clock.Now()
+	startTime := utils.FormatDateTime(now.Add(-popupWindow))
+	endTime := utils.FormatDateTime(now)
+
+	var reminders []models.Reminder
+	if err := d.db.WithContext(ctx).
+		Where("reminder_type = ? AND status = ? AND reminder_time >= ? AND reminder_time <= ?",
+			"popup", ReminderStatusPending, startTime, endTime).
+		Order("reminder_time ASC").
+		Find(&reminders).Error; err != nil {
+		return err
+	}
+
+	// 清理已过期的推送记录
+	for id, reminderTime := range d.pushed {
+		if reminderTime < startTime {
+			delete(d.pushed, id)
+		}
+	}
+
+	for _, reminder := range reminders {
+		// 提醒时间变化（如被延迟）后需要重新推送
+		if d.pushed[reminder.ID] == reminder.ReminderTime {
+			continue
+		}
+		d.pushed[reminder.ID] = reminder.ReminderTime
+		d.events.Publish(reminder.UserID, EventReminderFired, reminder)
+	}
+
+	return nil
+}
+
+// deliver 投递单条提醒并更新状态
+func (d *ReminderDispatcher) deliver(ctx context.Context, reminder *models.Reminder) {
+	d.mu.RLock()
+	channel, ok := d.channels[reminder.ReminderType]
+	d.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, d.opts.SendTimeout)
+	err := channel.Send(sendCtx, reminder)
+	cancel()
+
+	// 服务关闭导致的中断不计入失败次数，下次启动后继续投递
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if err == nil {
+		updates["status"] = ReminderStatusSent
+		updates["next_retry_at"] = ""
+		updates["last_error"] = ""
+	} else {
+		retryCount := reminder.RetryCount + 1
+		updates["retry_count"] = retryCount
+		updates["last_error"] = err.Error()
+
+		if IsPermanent(err) || retryCount >= d.opts.MaxRetries {
+			updates["status"] = ReminderStatusDead
+			updates["next_retry_at"] = ""
+			utils.LogError("Reminder moved to dead letter",
+				zap.Uint64("reminderID", reminder.ID),
+				zap.String("type", reminder.ReminderType),
+				zap.Int("retryCount", retryCount),
+				zap.Error(err))
+		} else {
+			nextRetry := d.clock.Now().Add(d.backoff(retryCount))
+			updates["status"] = ReminderStatusFailed
+			updates["next_retry_at"] = utils.FormatDateTime(nextRetry)
+			utils.LogWarn("Reminder delivery failed, will retry",
+				zap.Uint64("reminderID", reminder.ID),
+				zap.String("type", reminder.ReminderType),
+				zap.Int("retryCount", retryCount),
+				zap.String("nextRetryAt", utils.FormatDateTime(nextRetry)),
+				zap.Error(err))
+		}
+	}
+
+	// 仅在状态未被其他请求修改（如延迟、删除）时更新
+	if err := d.db.Model(&models.Reminder{}).
+		Where("id = ? AND status = ?", reminder.ID, reminder.Status).
+		Updates(updates).Error; err != nil {
+		utils.LogError("Failed to update reminder status",
+			zap.Uint64("reminderID", reminder.ID),
+			zap.Error(err))
+	}
+}
+
+// backoff 计算第 n 次重试前的等待时间
+func (d *ReminderDispatcher) backoff(retryCount int) time.Duration {
+	wait := d.opts.BaseBackoff
+	for i := 1; i < retryCount; i++ {
+		wait *= 2
+		if wait >= d.opts.MaxBackoff {
+			return d.opts.MaxBackoff
+		}
+	}
+	return wait
+}
+
+// channelTypes 返回已注册的渠道类型
+func (d *ReminderDispatcher) channelTypes() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	types := make([]string, 0, len(d.channels))
+	for t := range d.channels {
+		types = append(types, t)
+	}
+	return types
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	_ "modernc.org/sqlite"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// stubChannel 按顺序返回预设错误的投递渠道，错误用完后投递成功
+type stubChannel struct {
+	errs  []error
+	calls []uint64
+}
+
+func (c *stubChannel) Type() string {
+	return "email"
+}
+
+func (c *stubChannel) Send(ctx context.Context, reminder *models.Reminder) error {
+	c.calls = append(c.calls, reminder.ID)
+	if len(c.errs) == 0 {
+		return nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return err
+}
+
+// newTestDB 创建临时的 SQLite 数据库并迁移 tables
+func newTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	if utils.Logger == nil {
+		utils.Logger = zap.NewNop()
+	}
+	sqlDB, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(sqlite.Dialector{Conn: sqlDB}, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestDispatcher(t *testing.T, channel ReminderChannel) (*ReminderDispatcher, *gorm.DB, *fakeClock) {
+	t.Helper()
+	db := newTestDB(t, &models.Reminder{})
+	clock := &fakeClock{now: time.Date(2025, 11, 5, 9, 0, 0, 0, time.Local)}
+	dispatcher := NewReminderDispatcher(db, ReminderDispatcherOptions{
+		MaxRetries:  3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  10 * time.Minute,
+	}, clock)
+	dispatcher.RegisterChannel(channel)
+	return dispatcher, db, clock
+}
+
+func createTestReminder(t *testing.T, db *gorm.DB, reminderTime time.Time) uint64 {
+	t.Helper()
+	reminder := models.Reminder{
+		UserID:       1,
+		EntityType:   "task",
+		EntityID:     1,
+		ReminderTime: utils.FormatDateTime(reminderTime),
+		ReminderType: "email",
+		Status:       ReminderStatusPending,
+	}
+	if err := db.Create(&reminder).Error; err != nil {
+		t.Fatal(err)
+	}
+	return reminder.ID
+}
+
+func loadTestReminder(t *testing.T, db *gorm.DB, id uint64) models.Reminder {
+	t.Helper()
+	var reminder models.Reminder
+	if err := db.First(&reminder, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return reminder
+}
+
+func dispatch(t *testing.T, dispatcher *ReminderDispatcher, want int) {
+	t.Helper()
+	processed, err := dispatcher.DispatchDue(context.Background())
+	if err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+	if processed != want {
+		t.Fatalf("DispatchDue processed %d reminders, want %d", processed, want)
+	}
+}
+
+func TestDispatchDueRetriesWithBackoff(t *testing.T) {
+	transient := errors.New("connection reset")
+	channel := &stubChannel{errs: []error{transient, transient, transient}}
+	dispatcher, db, clock := newTestDispatcher(t, channel)
+	id := createTestReminder(t, db, clock.Now().Add(time.Minute))
+
+	// 未到提醒时间不投递
+	dispatch(t, dispatcher, 0)
+
+	clock.Advance(time.Minute)
+	steps := []struct {
+		wait       time.Duration // 距离下次重试的等待时间，0 表示进入死信
+		status     string
+		retryCount int
+	}{
+		{wait: time.Minute, status: ReminderStatusFailed, retryCount: 1},
+		{wait: 2 * time.Minute, status: ReminderStatusFailed, retryCount: 2},
+		{status: ReminderStatusDead, retryCount: 3},
+	}
+	for i, step := range steps {
+		dispatch(t, dispatcher, 1)
+		reminder := loadTestReminder(t, db, id)
+		if reminder.Status != step.status || reminder.RetryCount != step.retryCount {
+			t.Fatalf("attempt %d: status = %s, retryCount = %d, want %s, %d",
+				i+1, reminder.Status, reminder.RetryCount, step.status, step.retryCount)
+		}
+		if reminder.LastError != transient.Error() {
+			t.Errorf("attempt %d: lastError = %q, want %q", i+1, reminder.LastError, transient.Error())
+		}
+		if step.wait == 0 {
+			if reminder.NextRetryAt != "" {
+				t.Errorf("attempt %d: dead reminder has nextRetryAt %q", i+1, reminder.NextRetryAt)
+			}
+			break
+		}
+		wantRetry := utils.FormatDateTime(clock.Now().Add(step.wait))
+		if reminder.NextRetryAt != wantRetry {
+			t.Fatalf("attempt %d: nextRetryAt = %s, want %s", i+1, reminder.NextRetryAt, wantRetry)
+		}
+
+		// 等待时间结束前不重试
+		clock.Advance(step.wait - time.Minute)
+		if step.wait > time.Minute {
+			dispatch(t, dispatcher, 0)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	// 死信不再投递
+	clock.Advance(time.Hour)
+	dispatch(t, dispatcher, 0)
+	if len(channel.calls) != 3 {
+		t.Errorf("channel called %d times, want 3", len(channel.calls))
+	}
+}
+
+func TestDispatchDuePermanentErrorIsNotRetried(t *testing.T) {
+	channel := &stubChannel{errs: []error{Permanent(errors.New("550 mailbox unavailable"))}}
+	dispatcher, db, clock := newTestDispatcher(t, channel)
+	id := createTestReminder(t, db, clock.Now())
+
+	dispatch(t, dispatcher, 1)
+	reminder := loadTestReminder(t, db, id)
+	if reminder.Status != ReminderStatusDead || reminder.RetryCount != 1 || reminder.NextRetryAt != "" {
+		t.Fatalf("status = %s, retryCount = %d, nextRetryAt = %q, want dead after one attempt",
+			reminder.Status, reminder.RetryCount, reminder.NextRetryAt)
+	}
+
+	clock.Advance(time.Hour)
+	dispatch(t, dispatcher, 0)
+	if len(channel.calls) != 1 {
+		t.Errorf("channel called %d times, want 1", len(channel.calls))
+	}
+}
+
+func TestDispatchDueSuccessAfterRetry(t *testing.T) {
+	channel := &stubChannel{errs: []error{errors.New("timeout")}}
+	dispatcher, db, clock := newTestDispatcher(t, channel)
+	id := createTestReminder(t, db, clock.Now())
+
+	dispatch(t, dispatcher, 1)
+	clock.Advance(time.Minute)
+	dispatch(t, dispatcher, 1)
+
+	reminder := loadTestReminder(t, db, id)
+	if reminder.Status != ReminderStatusSent || reminder.NextRetryAt != "" || reminder.LastError != "" {
+		t.Errorf("status = %s, nextRetryAt = %q, lastError = %q, want sent with retry state cleared",
+			reminder.Status, reminder.NextRetryAt, reminder.LastError)
+	}
+}
+
+func TestReminderBackoff(t *testing.T) {
+	dispatcher := NewReminderDispatcher(nil, ReminderDispatcherOptions{
+		BaseBackoff: time.Minute,
+		MaxBackoff:  10 * time.Minute,
+	}, nil)
+	for retryCount, want := range map[int]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		3: 4 * time.Minute,
+		4: 8 * time.Minute,
+		5: 10 * time.Minute,
+		9: 10 * time.Minute,
+	} {
+		if got := dispatcher.backoff(retryCount); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", retryCount, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// ReminderMessage 渲染提醒消息所需的数据（供邮件、微信等渠道使用）
+type ReminderMessage struct {
+	EntityType    string // task, habit
+	Title         string
+	Description   string
+	Icon          string
+	DueDate       string // 格式：2025-11-05
+	DueTime       string // 格式：18:20
+	ListName      string
+	Priority      int
+	PriorityLabel string
+	ReminderTime  string // 格式：2025-11-05 18:20
+}
+
+// priorityLabels 优先级显示名称（0-3）
+var priorityLabels = []string{"无优先级", "低优先级", "中优先级", "高优先级"}
+
+// PriorityLabel 返回优先级显示名称
+func PriorityLabel(priority int) string {
+	if priority < 0 || priority >= len(priorityLabels) {
+		return priorityLabels[0]
+	}
+	return priorityLabels[priority]
+}
+
+// BuildReminderMessage 根据提醒记录加载任务或习惯信息，构造消息数据
+func BuildReminderMessage(db *gorm.DB, reminder *models.Reminder) (*ReminderMessage, error) {
+	msg := &ReminderMessage{
+		EntityType:   reminder.EntityType,
+		ReminderTime: formatDisplayDateTime(reminder.ReminderTime),
+	}
+
+	// 先使用元数据兜底，实体被删除时仍然可以发送
+	if reminder.Metadata != "" {
+		var metadata map[string]string
+		if err := json.Unmarshal([]byte(reminder.Metadata), &metadata); err == nil {
+			msg.Title = metadata["title"]
+			msg.Description = metadata["description"]
+			msg.Icon = metadata["icon"]
+		}
+	}
+
+	switch reminder.EntityType {
+	case "task":
+		var task models.Task
+		if err := db.Where("id = ? AND user_id = ?", reminder.EntityID, reminder.UserID).
+			Preload("List").
+			First(&task).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, Permanent(fmt.Errorf("task %d not found", reminder.EntityID))
+			}
+			return nil, err
+		}
+		msg.Title = task.Title
+		msg.Description = task.Description
+		msg.DueDate = formatDisplayDate(task.DueDate)
+		msg.DueTime = task.DueTime
+		msg.Priority = task.Priority
+		if task.List != nil {
+			msg.ListName = task.List.Name
+		}
+
+	case "habit":
+		var habit models.Habit
+		if err := db.Where("id = ? AND user_id = ?", reminder.EntityID, reminder.UserID).
+			First(&habit).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, Permanent(fmt.Errorf("habit %d not found", reminder.EntityID))
+			}
+			return nil, err
+		}
+		msg.Title = habit.Name
+		msg.Icon = habit.Icon
+		if msg.Description == "" {
+			msg.Description = "习惯打卡提醒"
+		}
+	}
+
+	msg.PriorityLabel = PriorityLabel(msg.Priority)
+	return msg, nil
+}
+
+// formatDisplayDate 将 20251105 转换为 2025-11-05
+func formatDisplayDate(dateStr string) string {
+	date, err := utils.ParseDate(dateStr)
+	if err != nil {
+		return dateStr
+	}
+	return date.Format("2006-01-02")
+}
+
+// formatDisplayDateTime 将 20251105 18:20 转换为 2025-11-05 18:20
+func formatDisplayDateTime(datetimeStr string) string {
+	datetime, err := utils.ParseDateTime(datetimeStr)
+	if err != nil {
+		return datetimeStr
+	}
+	return datetime.Format("2006-01-02 15:04")
+}
@@ -26,9 +26,10 @@ func (s *ReminderService) CheckAndTriggerReminders(userID uint64) ([]models.Remi
 	startTime := utils.FormatDateTime(now.Add(-5 * time.Minute))
 	endTime := utils.FormatDateTime(now.Add(5 * time.Minute))
 	
+	// 仅返回弹窗提醒，邮件等渠道由后台调度器投递
 	err := s.db.Where(
-		"user_id = ? AND status = ? AND reminder_time >= ? AND reminder_time <= ?",
-		userID, "pending", startTime, endTime,
+		"user_id = ? AND reminder_type = ? AND status = ? AND reminder_time >= ? AND reminder_time <= ?",
+		userID, "popup", "pending", startTime, endTime,
 	).Find(&reminders).Error
 	
 	if err != nil {
@@ -50,6 +51,8 @@ func (s *ReminderService) CreateReminderForHabit(habit *models.Habit) error {
 	
 	// 删除旧的提醒
 	s.db.Where("entity_type = ? AND entity_id = ?", "habit", habit.ID).Delete(&models.Reminder{})
+
+	reminderTypes := s.reminderTypesForUser(habit.UserID)
 	
 	// 创建新的提醒
 	for _, timeStr := range reminderTimes {
@@ -77,18 +80,21 @@ func (s *ReminderService) CreateReminderForHabit(habit *models.Habit) error {
 		}
 		metadataJSON, _ := json.Marshal(metadata)
 		
-	reminder := models.Reminder{
-		UserID:       habit.UserID,
-		EntityType:   "habit",
-		EntityID:     habit.ID,
-		ReminderTime: utils.FormatDateTime(reminderTime),
-		ReminderType: "popup",
-		Status:       "pending",
-		Metadata:     string(metadataJSON),
-	}
-		
-		if err := s.db.Create(&reminder).Error; err != nil {
-			return err
+		// 每个启用的渠道各创建一条提醒
+		for _, reminderType := range reminderTypes {
+			reminder := models.Reminder{
+				UserID:       habit.UserID,
+				EntityType:   "habit",
+				EntityID:     habit.ID,
+				ReminderTime: utils.FormatDateTime(reminderTime),
+				ReminderType: reminderType,
+				Status:       "pending",
+				Metadata:     string(metadataJSON),
+			}
+
+			if err := s.db.Create(&reminder).Error; err != nil {
+				return err
+			}
 		}
 	}
 	
@@ -97,13 +103,14 @@ func (s *ReminderService) CreateReminderForHabit(habit *models.Habit) error {
 
 // CreateReminderForTask 为任务创建提醒
 func (s *ReminderService) CreateReminderForTask(task *models.Task) error {
-	if task.ReminderTime == "" {
+	// 删除旧的提醒
+	s.DeleteRemindersForEntity("task", task.ID)
+
+	// 没有提醒时间或任务已结束时不再提醒
+	if task.ReminderTime == "" || task.Status != "todo" {
 		return nil
 	}
 	
-	// 删除旧的提醒
-	s.db.Where("entity_type = ? AND entity_id = ?", "task", task.ID).Delete(&models.Reminder{})
-	
 	// 创建元数据
 	metadata := map[string]string{
 		"title":       task.Title,
@@ -111,17 +118,44 @@ func (s *ReminderService) CreateReminderForTask(task *models.Task) error {
 	}
 	metadataJSON, _ := json.Marshal(metadata)
 	
-	reminder := models.Reminder{
-		UserID:       task.UserID,
-		EntityType:   "task",
-		EntityID:     task.ID,
-		ReminderTime: task.ReminderTime,
-		ReminderType: "popup",
-		Status:       "pending",
-		Metadata:     string(metadataJSON),
+	// 每个启用的渠道各创建一条提醒
+	for _, reminderType := range s.reminderTypesForUser(task.UserID) {
+		reminder := models.Reminder{
+			UserID:       task.UserID,
+			EntityType:   "task",
+			EntityID:     task.ID,
+			ReminderTime: task.ReminderTime,
+			ReminderType: reminderType,
+			Status:       "pending",
+			Metadata:     string(metadataJSON),
+		}
+
+		if err := s.db.Create(&reminder).Error; err != nil {
+			return err
+		}
 	}
-	
-	return s.db.Create(&reminder).Error
+
+	return nil
+}
+
+// reminderTypesForUser 根据用户设置返回需要创建的提醒类型
+func (s *ReminderService) reminderTypesForUser(userID uint64) []string {
+	// 弹窗提醒始终创建，是否展示由前端根据设置决定
+	types := []string{"popup"}
+
+	var settings models.UserSettings
+	if err := s.db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return types
+	}
+
+	if settings.EmailEnabled && settings.EmailAddress != "" {
+		types = append(types, "email")
+	}
+	if settings.WechatEnabled && settings.WechatWebhookURL != "" {
+		types = append(types, "wechat")
+	}
+
+	return types
 }
 
 // MarkReminderSent 标记提醒已发送
@@ -134,6 +168,11 @@ func (s *ReminderService) DeleteReminder(reminderID uint64, userID uint64) error
 	return s.db.Where("id = ? AND user_id = ?", reminderID, userID).Delete(&models.Reminder{}).Error
 }
 
+// DeleteRemindersForEntity 删除任务或习惯的所有提醒
+func (s *ReminderService) DeleteRemindersForEntity(entityType string, entityID uint64) error {
+	return s.db.Where("entity_type = ? AND entity_id = ?", entityType, entityID).Delete(&models.Reminder{}).Error
+}
+
 // SnoozeReminder 延迟提醒
 func (s *ReminderService) SnoozeReminder(reminderID uint64, minutes int) error {
 	var reminder models.Reminder
@@ -151,6 +190,12 @@ func (s *ReminderService) SnoozeReminder(reminderID uint64, minutes int) error {
 	newTime := currentTime.Add(time.Duration(minutes) * time.Minute)
 	newTimeStr := utils.FormatDateTime(newTime)
 	
-	return s.db.Model(&reminder).Update("reminder_time", newTimeStr).Error
+	// 延迟后重新进入待投递状态
+	return s.db.Model(&reminder).Updates(map[string]interface{}{
+		"reminder_time": newTimeStr,
+		"status":        ReminderStatusPending,
+		"retry_count":   0,
+		"next_retry_at": "",
+	}).Error
 }
 
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"errors"
+	"html"
+	"on-the-way/backend/models"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// 全文索引：search_index 为 FTS5 虚拟表，索引任务（标题、描述、标签名、清单名）、习惯和倒数日。
+// FTS5 自带的分词器无法切分中文，文本写入前先由 TokenizeSearchText 切分（中日韩文字切为二元组），
+// 查询时由 BuildSearchMatch 按同样的规则生成 MATCH 表达式。
+// 相关表上的触发器把变化的记录写入 search_index_queue，搜索前由 SyncSearchIndex 重建这些记录的索引
+
+// 索引的实体类型
+const (
+	SearchEntityTask      = "task"
+	SearchEntityHabit     = "habit"
+	SearchEntityCountdown = "countdown"
+)
+
+// SearchRankExpr BM25 相关度表达式（越小越相关），权重依次对应 search_index 的各列：标题 > 描述 > 标签 > 清单
+const SearchRankExpr = "bm25(search_index, 0, 0, 0, 10.0, 4.0, 3.0, 2.0)"
+
+// 每批处理的队列记录数
+const searchIndexBatchSize = 500
+
+// 索引重建串行执行
+var searchIndexMu sync.Mutex
+
+// searchEntityCodes 实体类型编码，rowid = entity_id*4 + 编码，便于按 rowid 删除旧索引
+var searchEntityCodes = map[string]uint64{
+	SearchEntityTask:      1,
+	SearchEntityHabit:     2,
+	SearchEntityCountdown: 3,
+}
+
+var searchIndexTables = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		entity_type UNINDEXED,
+		entity_id UNINDEXED,
+		user_id UNINDEXED,
+		title,
+		content,
+		tags,
+		list_name,
+		tokenize = 'unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TABLE IF NOT EXISTS search_index_queue (
+		entity_type TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		PRIMARY KEY (entity_type, entity_id)
+	)`,
+}
+
+// searchIndexTriggers 把变化的记录加入索引队列的触发器，启动时重建，修改定义后即可生效
+var searchIndexTriggers = []struct {
+	name       string
+	definition string
+}{
+	// 任务
+	{"search_tasks_insert", `AFTER INSERT ON tasks BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('task', NEW.id);
+	END`},
+	{"search_tasks_update", `AFTER UPDATE OF title, description, list_id, user_id, deleted_at ON tasks BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('task', NEW.id);
+	END`},
+	{"search_tasks_delete", `AFTER DELETE ON tasks BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('task', OLD.id);
+	END`},
+	{"search_task_tags_insert", `AFTER INSERT ON task_tags BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('task', NEW.task_id);
+	END`},
+	{"search_task_tags_delete", `AFTER DELETE ON task_tags BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('task', OLD.task_id);
+	END`},
+
+	// 标签和清单：名称用于拼音索引，同时是任务全文索引的一部分
+	{"search_tags_insert", `AFTER INSERT ON tags BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('tag', NEW.id);
+	END`},
+	{"search_tags_update", `AFTER UPDATE OF name, user_id, deleted_at ON tags BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('tag', NEW.id);
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id)
+			SELECT 'task', task_id FROM task_tags WHERE tag_id = NEW.id;
+	END`},
+	{"search_tags_delete", `AFTER DELETE ON tags BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('tag', OLD.id);
+	END`},
+	{"search_lists_insert", `AFTER INSERT ON lists BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('list', NEW.id);
+	END`},
+	{"search_lists_update", `AFTER UPDATE OF name, user_id, deleted_at ON lists BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('list', NEW.id);
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id)
+			SELECT 'task', id FROM tasks WHERE list_id = NEW.id;
+	END`},
+	{"search_lists_delete", `AFTER DELETE ON lists BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('list', OLD.id);
+	END`},
+
+	// 习惯
+	{"search_habits_insert", `AFTER INSERT ON habits BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('habit', NEW.id);
+	END`},
+	{"search_habits_update", `AFTER UPDATE OF name, user_id, deleted_at ON habits BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('habit', NEW.id);
+	END`},
+	{"search_habits_delete", `AFTER DELETE ON habits BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('habit', OLD.id);
+	END`},
+
+	// 倒数日
+	{"search_countdowns_insert", `AFTER INSERT ON countdowns BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('countdown', NEW.id);
+	END`},
+	{"search_countdowns_update", `AFTER UPDATE OF title, user_id, deleted_at ON countdowns BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('countdown', NEW.id);
+	END`},
+	{"search_countdowns_delete", `AFTER DELETE ON countdowns BEGIN
+		INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id) VALUES ('countdown', OLD.id);
+	END`},
+}
+
+// InitSearchIndex 创建全文索引表和触发器；首次创建全文索引或拼音索引为空时，把已有数据加入索引队列
+func InitSearchIndex(db *gorm.DB) error {
+	var exists int64
+	if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'search_index'").Scan(&exists).Error; err != nil {
+		return err
+	}
+
+	for _, statement := range searchIndexTables {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	for _, trigger := range searchIndexTriggers {
+		if err := db.Exec("DROP TRIGGER IF EXISTS " + trigger.name).Error; err != nil {
+			return err
+		}
+		if err := db.Exec("CREATE TRIGGER " + trigger.name + " " + trigger.definition).Error; err != nil {
+			return err
+		}
+	}
+
+	if exists == 0 {
+		if err := db.Exec(`INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id)
+			SELECT 'task', id FROM tasks WHERE deleted_at IS NULL
+			UNION ALL SELECT 'habit', id FROM habits WHERE deleted_at IS NULL
+			UNION ALL SELECT 'countdown', id FROM countdowns WHERE deleted_at IS NULL`).Error; err != nil {
+			return err
+		}
+	}
+
+	var pinyinCount int64
+	if err := db.Model(&models.PinyinIndex{}).Count(&pinyinCount).Error; err != nil {
+		return err
+	}
+	if pinyinCount == 0 {
+		return db.Exec(`INSERT OR IGNORE INTO search_index_queue (entity_type, entity_id)
+			SELECT 'task', id FROM tasks WHERE deleted_at IS NULL
+			UNION ALL SELECT 'list', id FROM lists WHERE deleted_at IS NULL
+			UNION ALL SELECT 'tag', id FROM tags WHERE deleted_at IS NULL`).Error
+	}
+	return nil
+}
+
+// SyncSearchIndex 处理索引队列，重建有变化的记录的索引
+func SyncSearchIndex(db *gorm.DB) error {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+
+	for {
+		var pending []struct {
+			EntityType string
+			EntityID   uint64
+		}
+		if err := db.Raw("SELECT entity_type, entity_id FROM search_index_queue LIMIT ?", searchIndexBatchSize).
+			Scan(&pending).Error; err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, entry := range pending {
+				// 先出队再读取记录，事务持有写锁，读到的是最新数据
+				if err := tx.Exec("DELETE FROM search_index_queue WHERE entity_type = ? AND entity_id = ?", entry.EntityType, entry.EntityID).Error; err != nil {
+					return err
+				}
+				if err := reindexSearchEntity(tx, entry.EntityType, entry.EntityID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reindexSearchEntity 重建单条记录的全文索引和拼音索引，记录不存在或已删除时只移除索引
+func reindexSearchEntity(tx *gorm.DB, entityType string, entityID uint64) error {
+	if entityType == PinyinEntityList || entityType == PinyinEntityTag {
+		return reindexPinyinEntity(tx, entityType, entityID)
+	}
+
+	code, ok := searchEntityCodes[entityType]
+	if !ok {
+		return nil
+	}
+	rowID := entityID*4 + code
+	if err := tx.Exec("DELETE FROM search_index WHERE rowid = ?", rowID).Error; err != nil {
+		return err
+	}
+
+	var userID uint64
+	var title, content, tags, listName string
+	switch entityType {
+	case SearchEntityTask:
+		var task models.Task
+		if err := tx.Where("id = ?", entityID).First(&task).Error; err != nil {
+			if err := ignoreNotFound(err); err != nil {
+				return err
+			}
+			return reindexPinyin(tx, SearchEntityTask, entityID, 0, "")
+		}
+		if err := reindexPinyin(tx, SearchEntityTask, task.ID, task.UserID, task.Title); err != nil {
+			return err
+		}
+		var tagNames []string
+		tx.Model(&models.Tag{}).
+			Joins("JOIN task_tags ON task_tags.tag_id = tags.id").
+			Where("task_tags.task_id = ?", task.ID).
+			Pluck("tags.name", &tagNames)
+		var listNames []string
+		tx.Model(&models.List{}).Where("id = ?", task.ListID).Pluck("name", &listNames)
+
+		userID, title, content = task.UserID, task.Title, task.Description
+		tags, listName = strings.Join(tagNames, " "), strings.Join(listNames, " ")
+
+	case SearchEntityHabit:
+		var habit models.Habit
+		if err := tx.Where("id = ?", entityID).First(&habit).Error; err != nil {
+			return ignoreNotFound(err)
+		}
+		userID, title = habit.UserID, habit.Name
+
+	case SearchEntityCountdown:
+		var countdown models.Countdown
+		if err := tx.Where("id = ?", entityID).First(&countdown).Error; err != nil {
+			return ignoreNotFound(err)
+		}
+		userID, title = countdown.UserID, countdown.Title
+	}
+
+	return tx.Exec(`INSERT INTO search_index (rowid, entity_type, entity_id, user_id, title, content, tags, list_name)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rowID, entityType, entityID, userID,
+		TokenizeSearchText(title), TokenizeSearchText(content), TokenizeSearchText(tags), TokenizeSearchText(listName)).Error
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+// searchSegment 连续的中日韩文字，或连续的其他字母数字
+type searchSegment struct {
+	runes []rune
+	cjk   bool
+}
+
+// splitSearchSegments 按字符类型切分文本，标点和空白作为分隔符丢弃；字母转为小写
+func splitSearchSegments(text string) []searchSegment {
+	var segments []searchSegment
+	var current []rune
+	currentCJK := false
+
+	flush := func() {
+		if len(current) > 0 {
+			segments = append(segments, searchSegment{runes: current, cjk: currentCJK})
+			current = nil
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !currentCJK {
+				flush()
+			}
+			currentCJK = true
+			current = append(current, r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if currentCJK {
+				flush()
+			}
+			currentCJK = false
+			current = append(current, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return segments
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// TokenizeSearchText 生成写入索引的词序列：中日韩文字切为相邻二元组（"季度规划" -> "季度 度规 规划"），
+// 其他字母数字按词小写；单字另外追加在末尾，使单字查询也能命中，且不打断二元组的相邻关系
+func TokenizeSearchText(text string) string {
+	var tokens, unigrams []string
+	for _, segment := range splitSearchSegments(text) {
+		if !segment.cjk || len(segment.runes) == 1 {
+			tokens = append(tokens, string(segment.runes))
+			continue
+		}
+		for i := 0; i+1 < len(segment.runes); i++ {
+			tokens = append(tokens, string(segment.runes[i:i+2]))
+		}
+		for _, r := range segment.runes {
+			unigrams = append(unigrams, string(r))
+		}
+	}
+	return strings.Join(append(tokens, unigrams...), " ")
+}
+
+// BuildSearchMatch 将关键词转换为 FTS5 MATCH 表达式，各部分都需匹配：
+// 中日韩文字按二元组短语匹配，其他词按前缀匹配；没有可检索的字符时返回空字符串
+func BuildSearchMatch(keyword string) string {
+	var parts []string
+	for _, segment := range splitSearchSegments(keyword) {
+		if !segment.cjk {
+			parts = append(parts, `"`+string(segment.runes)+`"*`)
+			continue
+		}
+		if len(segment.runes) == 1 {
+			parts = append(parts, `"`+string(segment.runes)+`"`)
+			continue
+		}
+		bigrams := make([]string, 0, len(segment.runes)-1)
+		for i := 0; i+1 < len(segment.runes); i++ {
+			bigrams = append(bigrams, string(segment.runes[i:i+2]))
+		}
+		parts = append(parts, `"`+strings.Join(bigrams, " ")+`"`)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// BuildSearchMatchAny 任一关键词匹配即可的 MATCH 表达式，用于计算相关度
+func BuildSearchMatchAny(keywords []string) string {
+	var parts []string
+	for _, keyword := range keywords {
+		if match := BuildSearchMatch(keyword); match != "" {
+			parts = append(parts, "("+match+")")
+		}
+	}
+	return strings.Join(parts, " OR ")
+}
+
+// HighlightSearchText 用 <mark> 标出文本中的关键词（不区分大小写），其余内容做 HTML 转义；
+// maxRunes > 0 时截取第一个匹配附近的片段
+func HighlightSearchText(text string, keywords []string, maxRunes int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(runes))
+	for _, keyword := range keywords {
+		found := false
+		for _, segment := range splitSearchSegments(keyword) {
+			needle := segment.runes
+			for i := 0; i+len(needle) <= len(lower); i++ {
+				if runesEqual(lower[i:i+len(needle)], needle) {
+					found = true
+					for j := i; j < i+len(needle); j++ {
+						marked[j] = true
+					}
+				}
+			}
+		}
+		// 原文中没有关键词时，按拼音或首字母标出对应的汉字
+		if !found {
+			markPinyinMatch(runes, keyword, marked)
+		}
+	}
+
+	start, end := 0, len(runes)
+	if maxRunes > 0 && len(runes) > maxRunes {
+		first := 0
+		for i, m := range marked {
+			if m {
+				first = i
+				break
+			}
+		}
+		// 匹配位置前保留约三分之一的上下文
+		start = first - maxRunes/3
+		if start < 0 {
+			start = 0
+		}
+		end = start + maxRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - maxRunes
+		}
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		chunk := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			sb.WriteString("<mark>" + chunk + "</mark>")
+		} else {
+			sb.WriteString(chunk)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+func runesEqual(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,533 @@
+package services
+
+import (
+	"fmt"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 搜索查询语法：
+//
+//	query   = orExpr
+//	orExpr  = andExpr { "OR" andExpr }
+//	andExpr = unary { ["AND"] unary }          相邻条件默认为 AND
+//	unary   = ("-" | "NOT") unary | primary
+//	primary = "(" orExpr ")" | field op value | phrase | word
+//	op      = ":" | "=" | "!=" | "<" | "<=" | ">" | ">="
+//	value   = word | phrase
+//	phrase  = '"' { 任意字符 | '\"' } '"'
+//
+// 字段：tag、list、status、priority、due、created、completed、is、has；
+// 不带字段的词和短语匹配标题或描述。例如：
+//
+//	tag:work list:"Side project" due<2025-12-01 priority>=2 is:recurring -status:completed "exact phrase"
+//
+// 查询编译为过滤器规则树，与已保存的过滤器共用同一套 SQL 生成逻辑
+
+// SearchQueryError 查询语句解析错误，Pos 为出错位置（从 0 开始的字符偏移）
+type SearchQueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SearchQueryError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// SearchQuery 解析后的查询
+type SearchQuery struct {
+	Rules *models.FilterRule // 任务的过滤规则
+	Terms []string           // 未取反的自由文本（词和短语），用于搜索清单和标签名称
+}
+
+type searchTokenKind int
+
+const (
+	tokenTerm searchTokenKind = iota // 词、短语或 field op value
+	tokenLParen
+	tokenRParen
+	tokenNot
+	tokenAnd
+	tokenOr
+	tokenEOF
+)
+
+type searchToken struct {
+	kind  searchTokenKind
+	pos   int
+	field string // 为空表示自由文本
+	op    string
+	value string
+}
+
+// 字段别名
+var searchFieldAliases = map[string]string{
+	"tag":       "tag",
+	"tags":      "tag",
+	"list":      "list",
+	"status":    "status",
+	"priority":  "priority",
+	"p":         "priority",
+	"due":       "due",
+	"created":   "created",
+	"completed": "completed",
+	"done":      "completed",
+	"is":        "is",
+	"has":       "has",
+}
+
+var searchPriorityNames = map[string]int{
+	"none":   0,
+	"low":    1,
+	"medium": 2,
+	"high":   3,
+}
+
+// ParseSearchQuery 解析搜索查询语句
+func ParseSearchQuery(input string) (*SearchQuery, error) {
+	tokens, err := tokenizeSearchQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &searchParser{tokens: tokens}
+	root := &models.FilterRule{Match: FilterMatchAll}
+	if p.peek().kind != tokenEOF {
+		rule, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.peek(); tok.kind != tokenEOF {
+			if tok.kind == tokenRParen {
+				return nil, &SearchQueryError{Pos: tok.pos, Msg: "unexpected \")\" without matching \"(\""}
+			}
+			return nil, &SearchQueryError{Pos: tok.pos, Msg: "unexpected token"}
+		}
+		root = asAllGroup(rule)
+	}
+
+	if err := ValidateFilterRule(root); err != nil {
+		return nil, &SearchQueryError{Pos: 0, Msg: err.Error()}
+	}
+	return &SearchQuery{Rules: root, Terms: p.terms}, nil
+}
+
+// tokenizeSearchQuery 词法分析
+func tokenizeSearchQuery(input string) ([]searchToken, error) {
+	var tokens []searchToken
+	i := 0
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '(':
+			tokens = append(tokens, searchToken{kind: tokenLParen, pos: i})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, searchToken{kind: tokenRParen, pos: i})
+			i++
+			continue
+		case r == '-' && i+1 < len(input) && !isSearchDelimiter(input[i+1]):
+			tokens = append(tokens, searchToken{kind: tokenNot, pos: i})
+			i++
+			continue
+		case r == '"':
+			value, next, err := readSearchPhrase(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, searchToken{kind: tokenTerm, pos: i, value: value})
+			i = next
+			continue
+		}
+
+		start := i
+		// 字段名：字母后紧跟运算符
+		j := i
+		for j < len(input) && isASCIILetter(input[j]) {
+			j++
+		}
+		if j > i && j < len(input) && strings.ContainsRune(":=<>!", rune(input[j])) {
+			field := strings.ToLower(input[i:j])
+			op := readSearchOperator(input, j)
+			if op == "" {
+				return nil, &SearchQueryError{Pos: j, Msg: fmt.Sprintf("invalid operator after %q", field)}
+			}
+			if _, ok := searchFieldAliases[field]; !ok {
+				return nil, &SearchQueryError{Pos: start, Msg: fmt.Sprintf("unknown field %q (expected tag, list, status, priority, due, created, completed, is or has)", field)}
+			}
+			j += len(op)
+
+			tok := searchToken{kind: tokenTerm, pos: start, field: searchFieldAliases[field], op: op}
+			if j < len(input) && input[j] == '"' {
+				value, next, err := readSearchPhrase(input, j)
+				if err != nil {
+					return nil, err
+				}
+				tok.value, j = value, next
+			} else {
+				k := j
+				for k < len(input) && !isSearchDelimiter(input[k]) {
+					k++
+				}
+				tok.value, j = input[j:k], k
+			}
+			if tok.value == "" {
+				return nil, &SearchQueryError{Pos: j, Msg: fmt.Sprintf("missing value for %q", field)}
+			}
+			tokens = append(tokens, tok)
+			i = j
+			continue
+		}
+
+		// 普通词
+		for j < len(input) && !isSearchDelimiter(input[j]) && input[j] != '"' {
+			j++
+		}
+		word := input[i:j]
+		switch word {
+		case "OR":
+			tokens = append(tokens, searchToken{kind: tokenOr, pos: start})
+		case "AND":
+			tokens = append(tokens, searchToken{kind: tokenAnd, pos: start})
+		case "NOT":
+			tokens = append(tokens, searchToken{kind: tokenNot, pos: start})
+		default:
+			tokens = append(tokens, searchToken{kind: tokenTerm, pos: start, value: word})
+		}
+		i = j
+	}
+
+	tokens = append(tokens, searchToken{kind: tokenEOF, pos: len(input)})
+	return tokens, nil
+}
+
+// readSearchPhrase 读取从 start（引号位置）开始的短语，支持 \" 转义
+func readSearchPhrase(input string, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			if i+1 < len(input) && (input[i+1] == '"' || input[i+1] == '\\') {
+				sb.WriteByte(input[i+1])
+				i++
+				continue
+			}
+		case '"':
+			return sb.String(), i + 1, nil
+		}
+		sb.WriteByte(input[i])
+	}
+	return "", 0, &SearchQueryError{Pos: start, Msg: "unterminated quoted phrase"}
+}
+
+// readSearchOperator 读取运算符，优先匹配两个字符的运算符
+func readSearchOperator(input string, pos int) string {
+	for _, op := range []string{"<=", ">=", "!=", ":", "=", "<", ">"} {
+		if strings.HasPrefix(input[pos:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isSearchDelimiter(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '(' || b == ')'
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// searchParser 递归下降语法分析
+type searchParser struct {
+	tokens  []searchToken
+	pos     int
+	negated int // 当前所在的取反层数，用于收集自由文本
+	terms   []string
+}
+
+func (p *searchParser) peek() searchToken {
+	return p.tokens[p.pos]
+}
+
+func (p *searchParser) next() searchToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *searchParser) parseOr() (models.FilterRule, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return first, err
+	}
+	rules := []models.FilterRule{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		rule, err := p.parseAnd()
+		if err != nil {
+			return rule, err
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 1 {
+		return first, nil
+	}
+	return models.FilterRule{Match: FilterMatchAny, Rules: rules}, nil
+}
+
+func (p *searchParser) parseAnd() (models.FilterRule, error) {
+	var rules []models.FilterRule
+	for {
+		tok := p.peek()
+		if tok.kind == tokenAnd {
+			if len(rules) == 0 {
+				return models.FilterRule{}, &SearchQueryError{Pos: tok.pos, Msg: "AND must follow a condition"}
+			}
+			p.next()
+			tok = p.peek()
+			if tok.kind == tokenEOF || tok.kind == tokenRParen || tok.kind == tokenOr || tok.kind == tokenAnd {
+				return models.FilterRule{}, &SearchQueryError{Pos: tok.pos, Msg: "expected a condition after AND"}
+			}
+		}
+		if tok.kind == tokenEOF || tok.kind == tokenRParen || tok.kind == tokenOr {
+			break
+		}
+		rule, err := p.parseUnary()
+		if err != nil {
+			return rule, err
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		tok := p.peek()
+		return models.FilterRule{}, &SearchQueryError{Pos: tok.pos, Msg: "expected a condition"}
+	}
+	if len(rules) == 1 {
+		return rules[0], nil
+	}
+	return models.FilterRule{Match: FilterMatchAll, Rules: rules}, nil
+}
+
+func (p *searchParser) parseUnary() (models.FilterRule, error) {
+	if p.peek().kind == tokenNot {
+		tok := p.next()
+		if next := p.peek().kind; next == tokenEOF || next == tokenRParen || next == tokenOr || next == tokenAnd {
+			return models.FilterRule{}, &SearchQueryError{Pos: tok.pos, Msg: "expected a condition after negation"}
+		}
+		p.negated++
+		rule, err := p.parseUnary()
+		p.negated--
+		if err != nil {
+			return rule, err
+		}
+		return models.FilterRule{Match: FilterMatchNone, Rules: []models.FilterRule{rule}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *searchParser) parsePrimary() (models.FilterRule, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		rule, err := p.parseOr()
+		if err != nil {
+			return rule, err
+		}
+		if p.peek().kind != tokenRParen {
+			return rule, &SearchQueryError{Pos: tok.pos, Msg: "missing closing \")\""}
+		}
+		p.next()
+		return rule, nil
+	case tokenTerm:
+		return p.parseTerm(tok)
+	}
+	return models.FilterRule{}, &SearchQueryError{Pos: tok.pos, Msg: "expected a condition"}
+}
+
+// parseTerm 将单个条件转换为规则
+func (p *searchParser) parseTerm(tok searchToken) (models.FilterRule, error) {
+	fail := func(format string, args ...interface{}) (models.FilterRule, error) {
+		return models.FilterRule{}, &SearchQueryError{Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+	}
+	equality := tok.op == ":" || tok.op == "="
+
+	switch tok.field {
+	case "":
+		if p.negated%2 == 0 {
+			p.terms = append(p.terms, tok.value)
+		}
+		return filterLeaf("keyword", "contains", tok.value), nil
+
+	case "tag", "list":
+		if equality {
+			return filterLeaf(tok.field, "eq", tok.value), nil
+		}
+		if tok.op == "!=" {
+			return filterLeaf(tok.field, "neq", tok.value), nil
+		}
+		return fail("%s only supports \":\" and \"!=\"", tok.field)
+
+	case "status":
+		value := strings.ToLower(tok.value)
+		if value != "todo" && value != "completed" && value != "abandoned" {
+			return fail("invalid status %q (expected todo, completed or abandoned)", tok.value)
+		}
+		if equality {
+			return filterLeaf("status", "eq", value), nil
+		}
+		if tok.op == "!=" {
+			return filterLeaf("status", "neq", value), nil
+		}
+		return fail("status only supports \":\" and \"!=\"")
+
+	case "priority":
+		priority, ok := searchPriorityNames[strings.ToLower(tok.value)]
+		if !ok {
+			n, err := strconv.Atoi(tok.value)
+			if err != nil || n < 0 || n > 3 {
+				return fail("invalid priority %q (expected 0-3 or none, low, medium, high)", tok.value)
+			}
+			priority = n
+		}
+		switch tok.op {
+		case ":", "=":
+			return filterLeaf("priority", "eq", priority), nil
+		case "!=":
+			return filterLeaf("priority", "neq", priority), nil
+		case ">=":
+			return filterLeaf("priority", "gte", priority), nil
+		case "<=":
+			return filterLeaf("priority", "lte", priority), nil
+		case ">":
+			return filterLeaf("priority", "gte", priority+1), nil
+		case "<":
+			return filterLeaf("priority", "lte", priority-1), nil
+		}
+
+	case "due", "created", "completed":
+		return p.parseDateTerm(tok)
+
+	case "is":
+		switch strings.ToLower(tok.value) {
+		case "recurring":
+			return filterLeaf("isRecurring", "eq", equality), nil
+		case "todo", "completed", "abandoned":
+			op := "eq"
+			if !equality {
+				op = "neq"
+			}
+			return filterLeaf("status", op, strings.ToLower(tok.value)), nil
+		case "overdue":
+			rule := filterLeaf("dueDate", "preset", "overdue")
+			if !equality {
+				return models.FilterRule{Match: FilterMatchNone, Rules: []models.FilterRule{rule}}, nil
+			}
+			return rule, nil
+		}
+		return fail("invalid value %q for is (expected recurring, todo, completed, abandoned or overdue)", tok.value)
+
+	case "has":
+		if !equality && tok.op != "!=" {
+			return fail("has only supports \":\" and \"!=\"")
+		}
+		switch strings.ToLower(tok.value) {
+		case "reminder":
+			return filterLeaf("hasReminder", "eq", equality), nil
+		case "due", "date":
+			return filterLeaf("dueDate", emptyOperator(!equality), nil), nil
+		case "tag", "tags":
+			return filterLeaf("tag", emptyOperator(!equality), nil), nil
+		}
+		return fail("invalid value %q for has (expected reminder, due or tag)", tok.value)
+	}
+
+	return fail("unsupported operator %q for %s", tok.op, tok.field)
+}
+
+// parseDateTerm 解析日期条件，日期支持 2025-12-01、20251201 以及 today、tomorrow、yesterday；
+// ":" 还支持 week、last7days、overdue 和 none（无日期）
+func (p *searchParser) parseDateTerm(tok searchToken) (models.FilterRule, error) {
+	field := map[string]string{"due": "dueDate", "created": "createdDate", "completed": "completedDate"}[tok.field]
+	value := strings.ToLower(tok.value)
+	fail := func(format string, args ...interface{}) (models.FilterRule, error) {
+		return models.FilterRule{}, &SearchQueryError{Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	if value == "none" {
+		if field == "createdDate" {
+			return fail("created cannot be none")
+		}
+		switch tok.op {
+		case ":", "=":
+			return filterLeaf(field, "isEmpty", nil), nil
+		case "!=":
+			return filterLeaf(field, "isNotEmpty", nil), nil
+		}
+		return fail("none only supports \":\" and \"!=\"")
+	}
+
+	switch value {
+	case "week", "last7days", "overdue":
+		preset := map[string]string{"week": "week", "last7days": "last7Days", "overdue": "overdue"}[value]
+		rule := filterLeaf(field, "preset", preset)
+		switch tok.op {
+		case ":", "=":
+			return rule, nil
+		case "!=":
+			return models.FilterRule{Match: FilterMatchNone, Rules: []models.FilterRule{rule}}, nil
+		}
+		return fail("%s only supports \":\" and \"!=\"", value)
+	}
+
+	date := value
+	if value != "today" && value != "tomorrow" && value != "yesterday" {
+		date = strings.ReplaceAll(value, "-", "")
+		if _, err := utils.ParseDate(date); err != nil {
+			return fail("invalid date %q (expected YYYY-MM-DD, today, tomorrow, yesterday, week, last7days, overdue or none)", tok.value)
+		}
+	}
+
+	switch tok.op {
+	case ":", "=":
+		return filterLeaf(field, "eq", date), nil
+	case "!=":
+		return models.FilterRule{Match: FilterMatchNone, Rules: []models.FilterRule{filterLeaf(field, "eq", date)}}, nil
+	case "<":
+		return filterLeaf(field, "before", date), nil
+	case ">":
+		return filterLeaf(field, "after", date), nil
+	case "<=":
+		return filterLeaf(field, "between", [2]string{"", date}), nil
+	case ">=":
+		return filterLeaf(field, "between", [2]string{date, ""}), nil
+	}
+	return fail("unsupported operator %q for %s", tok.op, tok.field)
+}
+
+// emptyOperator has:x 对应 isNotEmpty，-has:x 或 has!=x 对应 isEmpty
+func emptyOperator(empty bool) string {
+	if empty {
+		return "isEmpty"
+	}
+	return "isNotEmpty"
+}
+
+// asAllGroup 确保根节点为 all 分组
+func asAllGroup(rule models.FilterRule) *models.FilterRule {
+	if rule.Match == FilterMatchAll {
+		return &rule
+	}
+	return &models.FilterRule{Match: FilterMatchAll, Rules: []models.FilterRule{rule}}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"on-the-way/backend/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 同步的实体类型
+const (
+	SyncEntityTask        = "task"
+	SyncEntityList        = "list"
+	SyncEntityFolder      = "folder"
+	SyncEntityTag         = "tag"
+	SyncEntityHabit       = "habit"
+	SyncEntityHabitRecord = "habitRecord"
+	SyncEntityCountdown   = "countdown"
+	SyncEntityFilter      = "filter"
+)
+
+// syncTokenOverlap 下次同步的游标比本次查询开始时提前的时长，避免漏掉查询时尚未提交的修改。
+// 重叠时间内的变更会重复返回，客户端按 ID 合并即可
+const syncTokenOverlap = 5 * time.Second
+
+// ErrInvalidSyncToken 同步游标无法解析
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// SyncEntityChanges 一类记录的变更：updated 为游标之后新建、修改或从回收站恢复的记录，deleted 为游标之后删除的记录 ID
+type SyncEntityChanges[T any] struct {
+	Updated []T      `json:"updated"`
+	Deleted []uint64 `json:"deleted"`
+}
+
+// SyncChanges 用户所有可同步记录的变更
+type SyncChanges struct {
+	Tasks        SyncEntityChanges[models.Task]        `json:"tasks"` // 包含标签
+	Lists        SyncEntityChanges[models.List]        `json:"lists"`
+	Folders      SyncEntityChanges[models.Folder]      `json:"folders"`
+	Tags         SyncEntityChanges[models.Tag]         `json:"tags"`
+	Habits       SyncEntityChanges[models.Habit]       `json:"habits"`
+	HabitRecords SyncEntityChanges[models.HabitRecord] `json:"habitRecords"`
+	Countdowns   SyncEntityChanges[models.Countdown]   `json:"countdowns"`
+	Filters      SyncEntityChanges[models.Filter]      `json:"filters"`
+}
+
+// EncodeSyncToken 生成从 now 开始的下次同步游标，对客户端不透明
+func EncodeSyncToken(now time.Time) string {
+	return strconv.FormatInt(now.Add(-syncTokenOverlap).UnixMicro(), 36)
+}
+
+// DecodeSyncToken 解析同步游标，返回上次同步的时间
+func DecodeSyncToken(token string) (time.Time, error) {
+	micros, err := strconv.ParseInt(token, 36, 64)
+	if err != nil || micros <= 0 {
+		return time.Time{}, ErrInvalidSyncToken
+	}
+	return time.UnixMicro(micros), nil
+}
+
+// LoadSyncChanges 读取用户在 since 之后的全部变更，since 为空时返回全部未删除的记录（全量同步）
+func LoadSyncChanges(db *gorm.DB, userID uint64, since *time.Time) (*SyncChanges, error) {
+	byUser := func(query *gorm.DB) *gorm.DB {
+		return query.Where("user_id = ?", userID)
+	}
+	// 打卡记录没有 user_id，按习惯归属；已删除习惯的打卡记录随习惯一起由客户端移除
+	byHabit := func(query *gorm.DB) *gorm.DB {
+		return query.Where("habit_id IN (?)", db.Unscoped().Model(&models.Habit{}).Select("id").Where("user_id = ?", userID))
+	}
+
+	changes := &SyncChanges{}
+	loaders := []func() error{
+		func() error { return loadSyncEntityChanges(db, byUser, since, &changes.Tasks, "Tags") },
+		func() error { return loadSyncEntityChanges(db, byUser, since, &changes.Lists) },
+		func() error { return loadSyncEntityChanges(db, byUser, since, &changes.Folders) },
+		func() error { return loadSyncEntityChanges(db, byUser, since, &changes.Tags) },
+		func() error { return loadSyncEntityChanges(db, byUser, since, &changes.Habits) },
+		func() error { return loadSyncEntityChanges(db, byHabit, since, &changes.HabitRecords) },
+		func() error { return loadSyncEntityChanges(db, byUser, since, &changes.Countdowns) },
+		func() error { return loadSyncEntityChanges(db, byUser, since, &changes.Filters) },
+	}
+	for _, load := range loaders {
+		if err := load(); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// loadSyncEntityChanges 读取一类记录的变更。软删除不修改 updated_at，因此删除按 deleted_at 单独查询
+func loadSyncEntityChanges[T any](db *gorm.DB, scope func(*gorm.DB) *gorm.DB, since *time.Time, changes *SyncEntityChanges[T], preloads ...string) error {
+	changes.Updated = []T{}
+	changes.Deleted = []uint64{}
+
+	query := db.Scopes(scope)
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	if since != nil {
+		query = query.Where("updated_at > ?", *since)
+	}
+	if err := query.Order("id ASC").Find(&changes.Updated).Error; err != nil {
+		return err
+	}
+	if since == nil {
+		return nil
+	}
+
+	var model T
+	return db.Unscoped().Model(&model).Scopes(scope).
+		Where("deleted_at > ?", *since).
+		Order("id ASC").
+		Pluck("id", &changes.Deleted).Error
+}
+
+// FindSyncMapping 查找客户端 ID 对应的服务器记录 ID，不存在时返回 gorm.ErrRecordNotFound
+func FindSyncMapping(tx *gorm.DB, userID uint64, entityType, clientID string) (uint64, error) {
+	var mapping models.SyncMapping
+	if err := tx.Where("user_id = ? AND entity_type = ? AND client_id = ?", userID, entityType, clientID).
+		First(&mapping).Error; err != nil {
+		return 0, err
+	}
+	return mapping.EntityID, nil
+}
+
+// SaveSyncMapping 记录客户端 ID 对应的服务器记录 ID
+func SaveSyncMapping(tx *gorm.DB, userID uint64, entityType, clientID string, entityID uint64) error {
+	return tx.Create(&models.SyncMapping{
+		UserID:     userID,
+		EntityType: entityType,
+		ClientID:   clientID,
+		EntityID:   entityID,
+	}).Error
+}
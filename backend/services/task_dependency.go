@@ -0,0 +1,59 @@
+package services
+
+import (
+	"on-the-way/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// WouldCreateDependencyCycle 检查添加"taskID 被 blockedByID 阻塞"后是否形成循环：
+// 即 blockedByID 是否直接或间接地被 taskID 阻塞
+func WouldCreateDependencyCycle(db *gorm.DB, taskID, blockedByID uint64) (bool, error) {
+	if taskID == blockedByID {
+		return true, nil
+	}
+
+	visited := map[uint64]bool{blockedByID: true}
+	current := []uint64{blockedByID}
+	for len(current) > 0 {
+		var blockers []uint64
+		if err := db.Model(&models.TaskDependency{}).
+			Where("task_id IN ?", current).
+			Pluck("blocked_by_id", &blockers).Error; err != nil {
+			return false, err
+		}
+
+		var next []uint64
+		for _, id := range blockers {
+			if id == taskID {
+				return true, nil
+			}
+			if !visited[id] {
+				visited[id] = true
+				next = append(next, id)
+			}
+		}
+		current = next
+	}
+	return false, nil
+}
+
+// IncompleteBlockers 返回每个任务尚未完成（待办状态且未删除）的前置任务ID
+func IncompleteBlockers(db *gorm.DB, taskIDs []uint64) map[uint64][]uint64 {
+	result := make(map[uint64][]uint64)
+	if len(taskIDs) == 0 {
+		return result
+	}
+
+	var rows []models.TaskDependency
+	db.Model(&models.TaskDependency{}).
+		Joins("JOIN tasks ON tasks.id = task_dependencies.blocked_by_id AND tasks.deleted_at IS NULL").
+		Where("task_dependencies.task_id IN ? AND tasks.status = ?", taskIDs, "todo").
+		Order("task_dependencies.id ASC").
+		Find(&rows)
+
+	for _, row := range rows {
+		result[row.TaskID] = append(result[row.TaskID], row.BlockedByID)
+	}
+	return result
+}
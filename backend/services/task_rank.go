@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 任务排序使用可比较的字符串排序键（分数索引）：键由整数部分和小数部分组成，
+// 整数部分的首字符决定其长度（a-z 为正，A-Z 为负），小数部分不以 0 结尾。
+// 在两个键之间总能生成新键，移动任务只需修改一行；追加到首尾时键长按对数增长
+
+// rankDigits 排序键使用的字符，按 ASCII 升序排列，保证键的字典序与数值顺序一致
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// smallestRankInteger 最小的整数部分，不能单独作为键
+const smallestRankInteger = "A00000000000000000000000000"
+
+const (
+	rankRebalanceLength = 24  // 排序键超过该长度时由后台任务重新分配
+	rankUpdateBatchSize = 500 // 批量更新排序键时每条语句处理的任务数
+)
+
+// ErrRankOrder 给定的前后排序键顺序不正确（前一个不小于后一个）
+var ErrRankOrder = errors.New("rank keys are out of order")
+
+// RankBetween 返回严格位于 a 和 b 之间的排序键，a 为空表示没有下界，b 为空表示没有上界
+func RankBetween(a, b string) (string, error) {
+	if a != "" {
+		if err := validateRank(a); err != nil {
+			return "", err
+		}
+	}
+	if b != "" {
+		if err := validateRank(b); err != nil {
+			return "", err
+		}
+	}
+	if a != "" && b != "" && a >= b {
+		return "", ErrRankOrder
+	}
+
+	if a == "" {
+		if b == "" {
+			return "a" + rankDigits[:1], nil
+		}
+		ib := rankIntegerPart(b)
+		fb := b[len(ib):]
+		if ib == smallestRankInteger {
+			return ib + rankMidpoint("", fb), nil
+		}
+		if ib < b {
+			return ib, nil
+		}
+		prev, ok := decrementRankInteger(ib)
+		if !ok {
+			return "", errors.New("rank key space exhausted")
+		}
+		return prev, nil
+	}
+
+	ia := rankIntegerPart(a)
+	fa := a[len(ia):]
+	if b == "" {
+		if next, ok := incrementRankInteger(ia); ok {
+			return next, nil
+		}
+		return ia + rankMidpoint(fa, ""), nil
+	}
+
+	ib := rankIntegerPart(b)
+	fb := b[len(ib):]
+	if ia == ib {
+		return ia + rankMidpoint(fa, fb), nil
+	}
+	next, ok := incrementRankInteger(ia)
+	if !ok {
+		return "", errors.New("rank key space exhausted")
+	}
+	if next < b {
+		return next, nil
+	}
+	return ia + rankMidpoint(fa, ""), nil
+}
+
+// RanksBetween 返回 n 个严格位于 a 和 b 之间且递增的排序键
+func RanksBetween(a, b string, n int) ([]string, error) {
+	switch {
+	case n <= 0:
+		return []string{}, nil
+	case n == 1:
+		key, err := RankBetween(a, b)
+		if err != nil {
+			return nil, err
+		}
+		return []string{key}, nil
+	case b == "":
+		keys := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			key, err := RankBetween(a, b)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			a = key
+		}
+		return keys, nil
+	case a == "":
+		keys := make([]string, n)
+		for i := n - 1; i >= 0; i-- {
+			key, err := RankBetween(a, b)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = key
+			b = key
+		}
+		return keys, nil
+	}
+
+	mid := n / 2
+	key, err := RankBetween(a, b)
+	if err != nil {
+		return nil, err
+	}
+	left, err := RanksBetween(a, key, mid)
+	if err != nil {
+		return nil, err
+	}
+	right, err := RanksBetween(key, b, n-mid-1)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(left, key), right...), nil
+}
+
+// rankMidpoint 返回两个小数部分之间的小数部分，b 为空表示上界为 1
+func rankMidpoint(a, b string) string {
+	if b != "" {
+		// 跳过公共前缀，a 较短时按补 0 比较
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + rankMidpoint(rest, b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(rankDigits, a[0])
+	}
+	digitB := len(rankDigits)
+	if b != "" {
+		digitB = strings.IndexByte(rankDigits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(rankDigits[(digitA+digitB+1)/2])
+	}
+	// 首位相邻
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(rankDigits[digitA]) + rankMidpoint(rest, "")
+}
+
+func rankDigitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return rankDigits[0]
+}
+
+// rankIntegerLength 根据首字符返回整数部分的长度，首字符无效时返回 0
+func rankIntegerLength(head byte) int {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2
+	}
+	return 0
+}
+
+// rankIntegerPart 返回排序键的整数部分，调用前需先 validateRank
+func rankIntegerPart(key string) string {
+	return key[:rankIntegerLength(key[0])]
+}
+
+// validateRank 检查排序键格式
+func validateRank(key string) error {
+	if key == "" {
+		return errors.New("empty rank key")
+	}
+	length := rankIntegerLength(key[0])
+	if length == 0 || length > len(key) {
+		return fmt.Errorf("invalid rank key %q", key)
+	}
+	for i := 1; i < len(key); i++ {
+		if strings.IndexByte(rankDigits, key[i]) < 0 {
+			return fmt.Errorf("invalid rank key %q", key)
+		}
+	}
+	if key == smallestRankInteger || (len(key) > length && key[len(key)-1] == rankDigits[0]) {
+		return fmt.Errorf("invalid rank key %q", key)
+	}
+	return nil
+}
+
+// incrementRankInteger 整数部分加一，超出范围时返回 false
+func incrementRankInteger(x string) (string, bool) {
+	head, digits := x[0], []byte(x[1:])
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := strings.IndexByte(rankDigits, digits[i]) + 1
+		if d < len(rankDigits) {
+			digits[i] = rankDigits[d]
+			return string(head) + string(digits), true
+		}
+		digits[i] = rankDigits[0]
+	}
+
+	// 进位到首字符，整数部分长度随之变化
+	switch head {
+	case 'Z':
+		return "a" + rankDigits[:1], true
+	case 'z':
+		return "", false
+	}
+	head++
+	if head > 'a' {
+		digits = append(digits, rankDigits[0])
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits), true
+}
+
+// decrementRankInteger 整数部分减一，超出范围时返回 false
+func decrementRankInteger(x string) (string, bool) {
+	last := rankDigits[len(rankDigits)-1]
+	head, digits := x[0], []byte(x[1:])
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := strings.IndexByte(rankDigits, digits[i]) - 1
+		if d >= 0 {
+			digits[i] = rankDigits[d]
+			return string(head) + string(digits), true
+		}
+		digits[i] = last
+	}
+
+	// 向首字符借位，整数部分长度随之变化
+	switch head {
+	case 'a':
+		return "Z" + string(last), true
+	case 'A':
+		return "", false
+	}
+	head--
+	if head < 'Z' {
+		digits = append(digits, last)
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits), true
+}
+
+// AdjacentTaskRank 返回用户任务中紧挨着 rank 之后（after 为 true）或之前的排序键，没有时返回空字符串；
+// excludeIDs 为不参与比较的任务（如正在移动的任务）
+func AdjacentTaskRank(db *gorm.DB, userID uint64, rank string, after bool, excludeIDs []uint64) (string, error) {
+	query := db.Model(&models.Task{}).Where("user_id = ? AND rank <> ''", userID)
+	if after {
+		query = query.Select("MIN(rank)").Where("rank > ?", rank)
+	} else {
+		query = query.Select("MAX(rank)").Where("rank < ?", rank)
+	}
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+
+	var adjacent *string
+	if err := query.Scan(&adjacent).Error; err != nil {
+		return "", err
+	}
+	if adjacent == nil {
+		return "", nil
+	}
+	return *adjacent, nil
+}
+
+// TaskRankFirst 返回排在用户所有任务之前的排序键，新建的任务默认排在最前面
+func TaskRankFirst(db *gorm.DB, userID uint64) (string, error) {
+	var first *string
+	if err := db.Model(&models.Task{}).Where("user_id = ? AND rank <> ''", userID).Select("MIN(rank)").Scan(&first).Error; err != nil {
+		return "", err
+	}
+	if first == nil {
+		return RankBetween("", "")
+	}
+	return RankBetween("", *first)
+}
+
+// TaskRankAfter 返回 n 个紧跟在 rank 之后的递增排序键，如重复任务的下一个实例排在原任务之后
+func TaskRankAfter(db *gorm.DB, userID uint64, rank string, n int) ([]string, error) {
+	next, err := AdjacentTaskRank(db, userID, rank, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	return RanksBetween(rank, next, n)
+}
+
+// TaskRankBetweenNeighbors 返回位于 after 与 before 两个任务之间的排序键（任一可为空），只给一个时紧挨着它；
+// 都为空时排在最后。moving 为正在移动的任务 ID，不参与比较。相邻任务的排序键相同或顺序颠倒时返回 ErrRankOrder
+func TaskRankBetweenNeighbors(db *gorm.DB, userID uint64, after, before *models.Task, moving uint64) (string, error) {
+	exclude := []uint64{moving}
+	var lo, hi string
+	var err error
+	switch {
+	case after != nil && before != nil:
+		lo, hi = after.Rank, before.Rank
+	case after != nil:
+		lo = after.Rank
+		hi, err = AdjacentTaskRank(db, userID, lo, true, exclude)
+	case before != nil:
+		hi = before.Rank
+		lo, err = AdjacentTaskRank(db, userID, hi, false, exclude)
+	default:
+		var last *string
+		err = db.Model(&models.Task{}).Where("user_id = ? AND id <> ?", userID, moving).Select("MAX(rank)").Scan(&last).Error
+		if last != nil {
+			lo = *last
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if (after != nil && lo == "") || (before != nil && hi == "") {
+		return "", ErrRankOrder
+	}
+	return RankBetween(lo, hi)
+}
+
+// UpdateTaskRanks 用一条语句批量设置任务的排序键，ids 与 ranks 一一对应
+func UpdateTaskRanks(tx *gorm.DB, ids []uint64, ranks []string) error {
+	// 与 GORM 写入的时间格式一致（不带单调时钟读数），增量同步按字符串比较 updated_at
+	now := tx.NowFunc()
+	for start := 0; start < len(ids); start += rankUpdateBatchSize {
+		end := start + rankUpdateBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var sb strings.Builder
+		args := make([]interface{}, 0, (end-start)*2+2)
+		sb.WriteString("UPDATE tasks SET rank = CASE id")
+		for i := start; i < end; i++ {
+			sb.WriteString(" WHEN ? THEN ?")
+			args = append(args, ids[i], ranks[i])
+		}
+		sb.WriteString(" END, version = version + 1, updated_at = ? WHERE id IN ?")
+		args = append(args, now, ids[start:end])
+
+		if err := tx.Exec(sb.String(), args...).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebalanceTaskRanks 按当前顺序为用户的所有任务（包括回收站中的）重新分配均匀、较短的排序键；
+// 没有排序键的旧任务按原来的 sort_order 和创建时间排列
+func RebalanceTaskRanks(tx *gorm.DB, userID uint64) error {
+	var ids []uint64
+	if err := tx.Unscoped().Model(&models.Task{}).
+		Where("user_id = ?", userID).
+		Order("rank ASC").
+		Order("CASE WHEN rank = '' THEN sort_order END ASC").
+		Order("CASE WHEN rank = '' AND parent_id IS NULL THEN created_at END DESC").
+		Order("CASE WHEN rank = '' THEN created_at END ASC").
+		Order("id ASC").
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	ranks, err := RanksBetween("", "", len(ids))
+	if err != nil {
+		return err
+	}
+	return UpdateTaskRanks(tx, ids, ranks)
+}
+
+// BackfillTaskRanks 为还没有排序键的旧任务分配排序键
+func BackfillTaskRanks(db *gorm.DB) error {
+	var userIDs []uint64
+	if err := db.Unscoped().Model(&models.Task{}).Where("rank = '' OR rank IS NULL").Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return RebalanceTaskRanks(tx, userID)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebalanceDenseTaskRanks 为排序键过长或有重复的用户重新分配排序键，返回处理的用户数
+func RebalanceDenseTaskRanks(db *gorm.DB) (int, error) {
+	var userIDs []uint64
+	if err := db.Raw("SELECT DISTINCT user_id FROM tasks WHERE deleted_at IS NULL AND LENGTH(rank) > ? "+
+		"UNION SELECT user_id FROM tasks WHERE deleted_at IS NULL GROUP BY user_id, rank HAVING COUNT(*) > 1",
+		rankRebalanceLength).Scan(&userIDs).Error; err != nil {
+		return 0, err
+	}
+	for _, userID := range userIDs {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return RebalanceTaskRanks(tx, userID)
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(userIDs), nil
+}
+
+// TaskRankRebalancerOptions 排序键整理任务配置
+type TaskRankRebalancerOptions struct {
+	Interval time.Duration // 扫描间隔
+}
+
+// TaskRankRebalancer 后台排序键整理任务，定时为排序键过长或重复的用户重新分配排序键
+type TaskRankRebalancer struct {
+	db   *gorm.DB
+	opts TaskRankRebalancerOptions
+}
+
+func NewTaskRankRebalancer(db *gorm.DB, opts TaskRankRebalancerOptions) *TaskRankRebalancer {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Minute
+	}
+	return &TaskRankRebalancer{db: db, opts: opts}
+}
+
+// Run 启动整理循环，直到 ctx 被取消
+func (r *TaskRankRebalancer) Run(ctx context.Context) {
+	utils.LogInfo("Task rank rebalancer started", zap.Duration("interval", r.opts.Interval))
+
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := RebalanceDenseTaskRanks(r.db); err != nil {
+			utils.LogError("Failed to rebalance task ranks", zap.Error(err))
+		} else if count > 0 {
+			utils.LogInfo("Rebalanced task ranks", zap.Int("users", count))
+		}
+
+		select {
+		case <-ctx.Done():
+			utils.LogInfo("Task rank rebalancer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"encoding/json"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 系列编辑范围
+const (
+	SeriesScopeThis   = "this"   // 只修改当前实例
+	SeriesScopeFuture = "future" // 修改当前及之后的实例（更新系列模板）
+	SeriesScopeAll    = "all"    // 修改系列中的所有实例，包括已完成的历史实例
+)
+
+// NewTaskSeriesFromTask 以任务当前的内容和重复规则创建系列模板
+func NewTaskSeriesFromTask(task *models.Task, tagIDs []uint64) *models.TaskSeries {
+	return &models.TaskSeries{
+		UserID:              task.UserID,
+		ListID:              task.ListID,
+		Title:               task.Title,
+		Description:         task.Description,
+		Priority:            task.Priority,
+		DueTime:             task.DueTime,
+		TagIDs:              FormatTagIDs(tagIDs),
+		IsRecurring:         task.IsRecurring,
+		RecurrenceType:      task.RecurrenceType,
+		RecurrenceInterval:  task.RecurrenceInterval,
+		RecurrenceWeekdays:  task.RecurrenceWeekdays,
+		RecurrenceMonthDay:  task.RecurrenceMonthDay,
+		RecurrenceLunarDate: task.RecurrenceLunarDate,
+		RecurrenceEndDate:   task.RecurrenceEndDate,
+		RRule:               task.RRule,
+		RecurrenceStart:     task.RecurrenceStart,
+		RecurrenceAnchor:    task.RecurrenceAnchor,
+		RecurrenceCatchUp:   task.RecurrenceCatchUp,
+	}
+}
+
+// ApplySeriesTemplate 将系列模板的内容和重复规则覆盖到任务上
+// 截止日期、提醒时间、状态和例外日期属于实例本身，不会被覆盖
+func ApplySeriesTemplate(series *models.TaskSeries, task *models.Task) {
+	task.ListID = series.ListID
+	task.Title = series.Title
+	task.Description = series.Description
+	task.Priority = series.Priority
+	task.DueTime = series.DueTime
+	task.IsRecurring = series.IsRecurring
+	task.RecurrenceType = series.RecurrenceType
+	task.RecurrenceInterval = series.RecurrenceInterval
+	task.RecurrenceWeekdays = series.RecurrenceWeekdays
+	task.RecurrenceMonthDay = series.RecurrenceMonthDay
+	task.RecurrenceLunarDate = series.RecurrenceLunarDate
+	task.RecurrenceEndDate = series.RecurrenceEndDate
+	task.RRule = series.RRule
+	task.RecurrenceStart = series.RecurrenceStart
+	task.RecurrenceAnchor = series.RecurrenceAnchor
+	task.RecurrenceCatchUp = series.RecurrenceCatchUp
+}
+
+// EnsureTaskSeries 确保重复任务属于某个系列，没有时以任务当前内容和标签创建
+func EnsureTaskSeries(db *gorm.DB, task *models.Task) error {
+	if !task.IsRecurring || task.SeriesID != nil {
+		return nil
+	}
+
+	series := NewTaskSeriesFromTask(task, TaskTagIDs(db, task.ID))
+	if err := db.Create(series).Error; err != nil {
+		return err
+	}
+
+	task.SeriesID = &series.ID
+	return db.Model(task).UpdateColumn("series_id", series.ID).Error
+}
+
+// TaskTagIDs 查询任务关联的标签ID
+func TaskTagIDs(db *gorm.DB, taskID uint64) []uint64 {
+	var tagIDs []uint64
+	db.Model(&models.TaskTag{}).Where("task_id = ?", taskID).Pluck("tag_id", &tagIDs)
+	return tagIDs
+}
+
+// ParseTagIDs 解析标签ID列表（JSON数组）
+func ParseTagIDs(value string) []uint64 {
+	if value == "" {
+		return nil
+	}
+	var tagIDs []uint64
+	if err := json.Unmarshal([]byte(value), &tagIDs); err != nil {
+		return nil
+	}
+	return tagIDs
+}
+
+// FormatTagIDs 将标签ID列表序列化为 JSON 数组，空列表返回空字符串
+func FormatTagIDs(tagIDs []uint64) string {
+	if len(tagIDs) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(tagIDs)
+	return string(data)
+}
+
+// BackfillTaskSeries 为旧数据补建系列：沿 ParentTaskID 把同一条链上的任务归入同一个系列，
+// 模板取链上最新的实例
+func BackfillTaskSeries(db *gorm.DB) error {
+	var tasks []models.Task
+	if err := db.Where("series_id IS NULL AND (is_recurring = ? OR parent_task_id IS NOT NULL)", true).
+		Order("id ASC").
+		Find(&tasks).Error; err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint64]*models.Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	// 找到每个任务所在链的根任务
+	rootOf := func(task *models.Task) *models.Task {
+		for task.ParentTaskID != nil {
+			parent, ok := byID[*task.ParentTaskID]
+			if !ok {
+				break
+			}
+			task = parent
+		}
+		return task
+	}
+
+	groups := make(map[uint64][]*models.Task)
+	var roots []*models.Task
+	for i := range tasks {
+		root := rootOf(&tasks[i])
+		if _, ok := groups[root.ID]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root.ID] = append(groups[root.ID], &tasks[i])
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, root := range roots {
+			members := groups[root.ID]
+
+			// 链的上游已经属于某个系列（如部分数据已迁移）时并入该系列
+			var seriesID uint64
+			if root.ParentTaskID != nil {
+				var parent models.Task
+				if err := tx.Unscoped().Select("id", "series_id").First(&parent, *root.ParentTaskID).Error; err == nil && parent.SeriesID != nil {
+					seriesID = *parent.SeriesID
+				}
+			}
+
+			if seriesID == 0 {
+				latest := members[len(members)-1]
+				series := NewTaskSeriesFromTask(latest, TaskTagIDs(tx, latest.ID))
+				if err := tx.Create(series).Error; err != nil {
+					return err
+				}
+				seriesID = series.ID
+			}
+
+			ids := make([]uint64, len(members))
+			for i, member := range members {
+				ids[i] = member.ID
+			}
+			if err := tx.Model(&models.Task{}).Where("id IN ?", ids).UpdateColumn("series_id", seriesID).Error; err != nil {
+				return err
+			}
+		}
+
+		utils.LogInfo("Backfilled recurring task series", zap.Int("series", len(roots)), zap.Int("tasks", len(tasks)))
+		return nil
+	})
+}
+
+// BackfillRecurrenceStart 为旧版按月、按年重复（没有 RRULE）的系列补全规则起始日期，取系列中最早的截止日期。
+// 之前按上一个实例的日期推算，短月份截断后的日期（如 31 日变为 28 日）会一直沿用
+func BackfillRecurrenceStart(db *gorm.DB) error {
+	var seriesList []models.TaskSeries
+	if err := db.Where("(r_rule = '' OR r_rule IS NULL) AND recurrence_type IN ? AND (recurrence_start = '' OR recurrence_start IS NULL)",
+		[]string{"monthly", "yearly"}).
+		Find(&seriesList).Error; err != nil {
+		return err
+	}
+	if len(seriesList) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		updated := 0
+		for _, series := range seriesList {
+			var start string
+			if err := tx.Unscoped().Model(&models.Task{}).
+				Where("series_id = ? AND due_date <> ''", series.ID).
+				Select("COALESCE(MIN(due_date), '')").
+				Scan(&start).Error; err != nil {
+				return err
+			}
+			if start == "" {
+				continue
+			}
+			if err := tx.Model(&models.TaskSeries{}).Where("id = ?", series.ID).UpdateColumn("recurrence_start", start).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Task{}).
+				Where("series_id = ? AND (r_rule = '' OR r_rule IS NULL) AND (recurrence_start = '' OR recurrence_start IS NULL)", series.ID).
+				UpdateColumn("recurrence_start", start).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+
+		utils.LogInfo("Backfilled recurrence start dates", zap.Int("series", updated))
+		return nil
+	})
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 回收站中的实体类型
+const (
+	TrashEntityTask      = "task"
+	TrashEntityList      = "list"
+	TrashEntityHabit     = "habit"
+	TrashEntityCountdown = "countdown"
+)
+
+// TrashEntityTypes 回收站支持的全部实体类型
+var TrashEntityTypes = []string{TrashEntityTask, TrashEntityList, TrashEntityHabit, TrashEntityCountdown}
+
+// purgeBatchSize 永久删除时每批处理的记录数，避免 IN 条件的参数过多
+const purgeBatchSize = 500
+
+// trashModels 各实体类型对应的模型
+var trashModels = map[string]interface{}{
+	TrashEntityTask:      &models.Task{},
+	TrashEntityList:      &models.List{},
+	TrashEntityHabit:     &models.Habit{},
+	TrashEntityCountdown: &models.Countdown{},
+}
+
+// trashPurgers 各实体类型的永久删除函数，返回删除的记录数
+var trashPurgers = map[string]func(tx *gorm.DB, ids []uint64) (int64, error){
+	TrashEntityTask:      purgeTasks,
+	TrashEntityList:      purgeLists,
+	TrashEntityHabit:     purgeHabits,
+	TrashEntityCountdown: purgeCountdowns,
+}
+
+// IsTrashEntityType 判断是否为回收站支持的实体类型
+func IsTrashEntityType(entityType string) bool {
+	_, ok := trashModels[entityType]
+	return ok
+}
+
+// DeletedTaskTree 返回已删除任务及与它一起删除的子孙任务 ID。
+// 删除任务时子任务在同一条语句中被删除，删除时间相同；更早单独删除的子任务不包含在内
+func DeletedTaskTree(db *gorm.DB, task models.Task) []uint64 {
+	ids := []uint64{task.ID}
+	current := []uint64{task.ID}
+	for len(current) > 0 {
+		var children []uint64
+		db.Unscoped().Model(&models.Task{}).
+			Where("parent_id IN ? AND user_id = ? AND deleted_at >= ?", current, task.UserID, task.DeletedAt.Time).
+			Pluck("id", &children)
+		ids = append(ids, children...)
+		current = children
+	}
+	return ids
+}
+
+// PurgeTrashItem 永久删除回收站中的一条记录及其关联数据，记录不存在或未删除时返回 gorm.ErrRecordNotFound
+func PurgeTrashItem(db *gorm.DB, userID uint64, entityType string, id uint64) error {
+	count, err := purgeTrash(db, []string{entityType}, func(query *gorm.DB) *gorm.DB {
+		return query.Where("id = ? AND user_id = ?", id, userID)
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// EmptyTrash 清空用户的回收站，返回永久删除的记录数
+func EmptyTrash(db *gorm.DB, userID uint64) (int64, error) {
+	return purgeTrash(db, TrashEntityTypes, func(query *gorm.DB) *gorm.DB {
+		return query.Where("user_id = ?", userID)
+	})
+}
+
+// PurgeExpiredTrash 永久删除所有用户在 before 之前删除的记录，返回删除的记录数
+func PurgeExpiredTrash(db *gorm.DB, before time.Time) (int64, error) {
+	return purgeTrash(db, TrashEntityTypes, func(query *gorm.DB) *gorm.DB {
+		return query.Where("deleted_at < ?", before)
+	})
+}
+
+// purgeTrash 在一个事务中永久删除符合条件的已删除记录
+func purgeTrash(db *gorm.DB, entityTypes []string, scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	var total int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, entityType := range entityTypes {
+			var ids []uint64
+			query := tx.Unscoped().Model(trashModels[entityType]).Where("deleted_at IS NOT NULL")
+			if err := scope(query).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+
+			for start := 0; start < len(ids); start += purgeBatchSize {
+				end := start + purgeBatchSize
+				if end > len(ids) {
+					end = len(ids)
+				}
+				count, err := trashPurgers[entityType](tx, ids[start:end])
+				if err != nil {
+					return err
+				}
+				total += count
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+// purgeTasks 永久删除任务及其已删除的子孙任务、检查项、标签关联、依赖和提醒，番茄记录保留但不再关联任务
+func purgeTasks(tx *gorm.DB, batch []uint64) (int64, error) {
+	// batch 可能是调用方 ID 列表的一段，复制后再加入子孙任务，避免覆盖下一批的 ID
+	ids := append([]uint64(nil), batch...)
+	current := batch
+	for len(current) > 0 {
+		var children []uint64
+		if err := tx.Unscoped().Model(&models.Task{}).
+			Where("parent_id IN ? AND deleted_at IS NOT NULL", current).
+			Pluck("id", &children).Error; err != nil {
+			return 0, err
+		}
+		ids = append(ids, children...)
+		current = children
+	}
+
+	if err := tx.Where("task_id IN ?", ids).Delete(&models.TaskTag{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Unscoped().Where("task_id IN ?", ids).Delete(&models.ChecklistItem{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("task_id IN ? OR blocked_by_id IN ?", ids, ids).Delete(&models.TaskDependency{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Unscoped().Where("entity_type = ? AND entity_id IN ?", "task", ids).Delete(&models.Reminder{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Unscoped().Model(&models.Pomodoro{}).Where("task_id IN ?", ids).Update("task_id", nil).Error; err != nil {
+		return 0, err
+	}
+	// 仍然存在的子任务（已单独恢复）提升为顶层任务
+	if err := tx.Unscoped().Model(&models.Task{}).Where("parent_id IN ?", ids).Update("parent_id", nil).Error; err != nil {
+		return 0, err
+	}
+
+	result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Task{})
+	return result.RowsAffected, result.Error
+}
+
+// purgeLists 永久删除清单及其视图配置，仍在清单中的任务和重复系列移到收集箱
+func purgeLists(tx *gorm.DB, ids []uint64) (int64, error) {
+	var lists []models.List
+	if err := tx.Unscoped().Where("id IN ?", ids).Find(&lists).Error; err != nil {
+		return 0, err
+	}
+
+	for _, list := range lists {
+		var inbox models.List
+		if err := tx.Where("user_id = ? AND is_default = ?", list.UserID, true).First(&inbox).Error; err != nil {
+			return 0, err
+		}
+		if err := tx.Unscoped().Model(&models.Task{}).Where("list_id = ?", list.ID).Update("list_id", inbox.ID).Error; err != nil {
+			return 0, err
+		}
+		if err := tx.Model(&models.TaskSeries{}).Where("list_id = ?", list.ID).Update("list_id", inbox.ID).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Unscoped().Where("entity_type = ? AND entity_id IN ?", "list", ids).Delete(&models.ViewConfig{}).Error; err != nil {
+		return 0, err
+	}
+
+	result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.List{})
+	return result.RowsAffected, result.Error
+}
+
+// purgeHabits 永久删除习惯及其打卡记录和提醒
+func purgeHabits(tx *gorm.DB, ids []uint64) (int64, error) {
+	if err := tx.Unscoped().Where("habit_id IN ?", ids).Delete(&models.HabitRecord{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Unscoped().Where("entity_type = ? AND entity_id IN ?", "habit", ids).Delete(&models.Reminder{}).Error; err != nil {
+		return 0, err
+	}
+
+	result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Habit{})
+	return result.RowsAffected, result.Error
+}
+
+// purgeCountdowns 永久删除倒数日
+func purgeCountdowns(tx *gorm.DB, ids []uint64) (int64, error) {
+	result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Countdown{})
+	return result.RowsAffected, result.Error
+}
+
+// TrashCleanerOptions 回收站清理任务配置
+type TrashCleanerOptions struct {
+	Interval  time.Duration // 扫描间隔
+	Retention time.Duration // 删除后保留的时长，超过后永久删除
+}
+
+// TrashCleaner 后台回收站清理任务，定时永久删除超过保留期的记录
+type TrashCleaner struct {
+	db    *gorm.DB
+	clock Clock
+	opts  TrashCleanerOptions
+}
+
+func NewTrashCleaner(db *gorm.DB, opts TrashCleanerOptions, clock Clock) *TrashCleaner {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 30 * 24 * time.Hour
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &TrashCleaner{db: db, clock: clock, opts: opts}
+}
+
+// Run 启动清理循环，直到 ctx 被取消
+func (t *TrashCleaner) Run(ctx context.Context) {
+	utils.LogInfo("Trash cleaner started", zap.Duration("interval", t.opts.Interval), zap.Duration("retention", t.opts.Retention))
+
+	ticker := time.NewTicker(t.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := t.PurgeExpired(); err != nil {
+			utils.LogError("Failed to purge expired trash", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			utils.LogInfo("Trash cleaner stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpired 执行一轮清理，返回永久删除的记录数
+func (t *TrashCleaner) PurgeExpired() (int64, error) {
+	count, err := PurgeExpiredTrash(t.db, t.clock.Now().Add(-t.opts.Retention))
+	if err == nil && count > 0 {
+		utils.LogInfo("Purged expired trash", zap.Int64("count", count))
+	}
+	return count, err
+}
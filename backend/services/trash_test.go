@@ -0,0 +1,48 @@
+package services
+
+import (
+	"on-the-way/backend/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestPurgeTrashAcrossBatches(t *testing.T) {
+	db := newTestDB(t, &models.Task{}, &models.TaskTag{}, &models.ChecklistItem{},
+		&models.TaskDependency{}, &models.Reminder{}, &models.Pomodoro{})
+
+	// 超过一批的已删除任务，第一批中的任务有一个排在最后一批的子任务
+	deletedAt := gorm.DeletedAt{Time: time.Now().Add(-48 * time.Hour), Valid: true}
+	total := 2*purgeBatchSize + 2
+	tasks := make([]models.Task, total)
+	for i := range tasks {
+		tasks[i] = models.Task{UserID: 1, ListID: 1, Title: "task " + strconv.Itoa(i+1), Status: "todo", DeletedAt: deletedAt}
+	}
+	if err := db.CreateInBatches(tasks, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+	parentID := tasks[0].ID
+	if err := db.Unscoped().Model(&models.Task{}).Where("id = ?", tasks[total-1].ID).Update("parent_id", parentID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := purgeTrash(db, []string{TrashEntityTask}, func(query *gorm.DB) *gorm.DB {
+		return query.Where("deleted_at < ?", time.Now().Add(-24*time.Hour))
+	})
+	if err != nil {
+		t.Fatalf("purgeTrash: %v", err)
+	}
+	if purged != int64(total) {
+		t.Errorf("purged = %d, want %d", purged, total)
+	}
+
+	var remaining int64
+	if err := db.Unscoped().Model(&models.Task{}).Count(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Errorf("remaining = %d, want 0", remaining)
+	}
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrVersionConflict 保存时数据库中的版本号已变化，说明其他请求先修改了该记录
+var ErrVersionConflict = errors.New("version conflict")
+
+// SaveVersioned 保存 value 的全部字段（不含关联），只在数据库中的版本号仍为 version 时生效，
+// 否则返回 ErrVersionConflict。版本号由更新回调加一
+func SaveVersioned(db *gorm.DB, value interface{}, version uint64) error {
+	result := db.Model(value).Where("version = ?", version).Select("*").Omit(clause.Associations).Updates(value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"on-the-way/backend/models"
+	"on-the-way/backend/utils"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 企业微信机器人返回的错误码
+const (
+	wechatErrSystemBusy = -1    // 系统繁忙
+	wechatErrRateLimit  = 45009 // 接口调用超过限制
+)
+
+// 企业微信群机器人的 Webhook 地址。只向这个地址推送，避免服务器被用来请求内网等任意地址
+const (
+	wechatWebhookHost = "qyapi.weixin.qq.com"
+	wechatWebhookPath = "/cgi-bin/webhook/send"
+)
+
+// WechatChannel 企业微信/微信群机器人 Webhook 提醒渠道
+type WechatChannel struct {
+	db     *gorm.DB
+	client *http.Client
+}
+
+// NewWechatChannel 创建微信提醒渠道
+func NewWechatChannel(db *gorm.DB) *WechatChannel {
+	return &WechatChannel{
+		db: db,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// 不跟随重定向，请求只发往校验过的地址
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Type 渠道类型
+func (ch *WechatChannel) Type() string {
+	return "wechat"
+}
+
+// Send 发送微信提醒
+func (ch *WechatChannel) Send(ctx context.Context, reminder *models.Reminder) error {
+	var settings models.UserSettings
+	if err := ch.db.Where("user_id = ?", reminder.UserID).First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return Permanent(errors.New("user settings not found"))
+		}
+		return err
+	}
+	if !settings.WechatEnabled || settings.WechatWebhookURL == "" {
+		return Permanent(errors.New("wechat notification is disabled"))
+	}
+
+	msg, err := BuildReminderMessage(ch.db, reminder)
+	if err != nil {
+		return err
+	}
+
+	if err := ch.SendMessage(ctx, settings.WechatWebhookURL, msg); err != nil {
+		utils.Logger.Warn("Failed to send wechat reminder",
+			zap.Uint64("reminderID", reminder.ID),
+			zap.Error(err))
+		return err
+	}
+
+	utils.LogInfo("Wechat reminder sent", zap.Uint64("reminderID", reminder.ID))
+	return nil
+}
+
+// wechatResponse Webhook 响应
+type wechatResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// SendMessage 将提醒以 markdown 消息推送到 Webhook
+func (ch *WechatChannel) SendMessage(ctx context.Context, webhookURL string, msg *ReminderMessage) error {
+	if err := ValidateWebhookURL(webhookURL); err != nil {
+		return Permanent(err)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": RenderWechatMarkdown(msg),
+		},
+	})
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ch.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	// 限流和服务端错误可以重试，其他 4xx 说明地址或请求有误
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Permanent(fmt.Errorf("webhook returned status %d", resp.StatusCode))
+	}
+
+	var result wechatResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		// 响应内容只记录在日志中，错误信息可能返回给客户端
+		utils.Logger.Warn("Invalid wechat webhook response", zap.String("body", strings.TrimSpace(string(body))))
+		return Permanent(errors.New("invalid webhook response"))
+	}
+
+	switch result.ErrCode {
+	case 0:
+		return nil
+	case wechatErrSystemBusy, wechatErrRateLimit:
+		return fmt.Errorf("webhook error %d: %s", result.ErrCode, result.ErrMsg)
+	default:
+		return Permanent(fmt.Errorf("webhook error %d: %s", result.ErrCode, result.ErrMsg))
+	}
+}
+
+// RenderWechatMarkdown 渲染企业微信 markdown 消息内容
+func RenderWechatMarkdown(msg *ReminderMessage) string {
+	var b strings.Builder
+
+	kind := "任务提醒"
+	if msg.EntityType == "habit" {
+		kind = "习惯打卡提醒"
+	}
+	title := msg.Title
+	if msg.Icon != "" {
+		title = msg.Icon + " " + title
+	}
+	fmt.Fprintf(&b, "### ⏰ %s：%s\n", kind, title)
+
+	if msg.DueDate != "" {
+		due := msg.DueDate
+		if msg.DueTime != "" {
+			due += " " + msg.DueTime
+		}
+		fmt.Fprintf(&b, "> 截止时间：<font color=\"warning\">%s</font>\n", due)
+	}
+	if msg.ListName != "" {
+		fmt.Fprintf(&b, "> 所属清单：%s\n", msg.ListName)
+	}
+	if msg.EntityType == "task" {
+		color := "comment"
+		if msg.Priority >= 3 {
+			color = "warning"
+		} else if msg.Priority == 2 {
+			color = "info"
+		}
+		fmt.Fprintf(&b, "> 优先级：<font color=\"%s\">%s</font>\n", color, msg.PriorityLabel)
+	}
+	fmt.Fprintf(&b, "> 提醒时间：%s\n", msg.ReminderTime)
+
+	// markdown 内容最长 4096 字节，描述过长时截断
+	if msg.Description != "" {
+		description := []rune(msg.Description)
+		if len(description) > 500 {
+			description = append(description[:500], []rune("…")...)
+		}
+		fmt.Fprintf(&b, "\n%s", string(description))
+	}
+
+	return b.String()
+}
+
+// ValidateWebhookURL 校验 Webhook 地址，只接受企业微信群机器人的地址
+// https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=...
+func ValidateWebhookURL(webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || u.User != nil ||
+		!strings.EqualFold(u.Host, wechatWebhookHost) || u.Path != wechatWebhookPath || u.Query().Get("key") == "" {
+		return errors.New("invalid webhook url")
+	}
+	return nil
+}
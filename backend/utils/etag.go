@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FormatETag 把资源版本号格式化为 ETag，如 "3"
+func FormatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// SetETag 设置响应的 ETag 头
+func SetETag(c *gin.Context, version uint64) {
+	c.Header("ETag", FormatETag(version))
+}
+
+// IfMatch 判断请求的 If-Match 头是否与版本号匹配，没有 If-Match 头或为 * 时视为匹配
+func IfMatch(c *gin.Context, version uint64) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+
+	etag := FormatETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -25,6 +25,15 @@ func Error(c *gin.Context, code int, message string) {
 	})
 }
 
+// ErrorWithData 返回错误并附带数据，如并发冲突时附带服务器上的当前数据
+func ErrorWithData(c *gin.Context, code int, message string, data interface{}) {
+	c.JSON(code, Response{
+		Code:    code,
+		Message: message,
+		Data:    data,
+	})
+}
+
 func BadRequest(c *gin.Context, message string) {
 	Error(c, 400, message)
 }
@@ -37,6 +46,23 @@ func NotFound(c *gin.Context, message string) {
 	Error(c, 404, message)
 }
 
+func Conflict(c *gin.Context, message string) {
+	Error(c, 409, message)
+}
+
+// PreconditionFailed If-Match 与当前版本不一致，附带服务器上的当前数据
+func PreconditionFailed(c *gin.Context, message string, data interface{}) {
+	ErrorWithData(c, 412, message, data)
+}
+
+func PreconditionRequired(c *gin.Context, message string) {
+	Error(c, 428, message)
+}
+
+func PayloadTooLarge(c *gin.Context, message string) {
+	Error(c, 413, message)
+}
+
 func InternalError(c *gin.Context, message string) {
 	Error(c, 500, message)
 }
@@ -0,0 +1,658 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRuleFrequency 重复频率
+type RRuleFrequency int
+
+const (
+	RRuleDaily RRuleFrequency = iota
+	RRuleWeekly
+	RRuleMonthly
+	RRuleYearly
+)
+
+var frequencyNames = map[RRuleFrequency]string{
+	RRuleDaily:   "DAILY",
+	RRuleWeekly:  "WEEKLY",
+	RRuleMonthly: "MONTHLY",
+	RRuleYearly:  "YEARLY",
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// maxEmptyPeriods 连续多少个周期没有产生日期时停止迭代，防止无解的规则死循环
+// （如 FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30）
+const maxEmptyPeriods = 5000
+
+// RRuleWeekday BYDAY 中的一项，如 MO、2TU、-1FR
+type RRuleWeekday struct {
+	Weekday time.Weekday
+	N       int // 第几个，0 表示所有，负数表示倒数第几个
+}
+
+// RRule RFC 5545 重复规则
+// 支持 FREQ(DAILY/WEEKLY/MONTHLY/YEARLY)、INTERVAL、COUNT、UNTIL、BYDAY、BYMONTHDAY、BYMONTH、BYSETPOS、WKST
+type RRule struct {
+	Freq       RRuleFrequency
+	Interval   int
+	Count      int
+	Until      time.Time // 零值表示不限
+	UntilDate  bool      // UNTIL 只有日期部分，当天的所有时刻都包含在内
+	UntilUTC   bool      // UNTIL 以 Z 结尾，按 UTC 时刻比较；否则按起始时间所在时区的本地时间比较
+	ByDay      []RRuleWeekday
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+// ParseRRule 解析 RRULE 字符串，可以带 "RRULE:" 前缀
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimSpace(value)
+	if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+		value = value[6:]
+	}
+	if value == "" {
+		return nil, errors.New("empty rrule")
+	}
+
+	rule := &RRule{Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rrule part %q", part)
+		}
+		key := strings.ToUpper(strings.TrimSpace(kv[0]))
+		val := strings.ToUpper(strings.TrimSpace(kv[1]))
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate rrule part %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			err = rule.parseFreq(val)
+		case "INTERVAL":
+			rule.Interval, err = parsePositiveInt(key, val)
+		case "COUNT":
+			rule.Count, err = parsePositiveInt(key, val)
+		case "UNTIL":
+			err = rule.parseUntil(val)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(key, val, 1, 31, true)
+		case "BYMONTH":
+			rule.ByMonth, err = parseIntList(key, val, 1, 12, false)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(key, val, 1, 366, true)
+		case "WKST":
+			rule.WeekStart, err = parseWeekday(val)
+		case "BYYEARDAY", "BYWEEKNO", "BYHOUR", "BYMINUTE", "BYSECOND":
+			err = fmt.Errorf("rrule part %s is not supported", key)
+		default:
+			err = fmt.Errorf("unknown rrule part %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !seen["FREQ"] {
+		return nil, errors.New("rrule requires FREQ")
+	}
+	if seen["COUNT"] && seen["UNTIL"] {
+		return nil, errors.New("rrule must not contain both COUNT and UNTIL")
+	}
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (r *RRule) parseFreq(val string) error {
+	for freq, name := range frequencyNames {
+		if name == val {
+			r.Freq = freq
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported FREQ %s", val)
+}
+
+func (r *RRule) parseUntil(val string) error {
+	if len(val) == 8 {
+		t, err := time.Parse("20060102", val)
+		if err != nil {
+			return fmt.Errorf("invalid UNTIL %s", val)
+		}
+		r.Until = t
+		r.UntilDate = true
+		return nil
+	}
+
+	r.UntilUTC = strings.HasSuffix(val, "Z")
+	t, err := time.Parse("20060102T150405", strings.TrimSuffix(val, "Z"))
+	if err != nil {
+		return fmt.Errorf("invalid UNTIL %s", val)
+	}
+	r.Until = t
+	return nil
+}
+
+// validate 检查各部分的组合是否合法
+func (r *RRule) validate() error {
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			continue
+		}
+		if r.Freq != RRuleMonthly && r.Freq != RRuleYearly {
+			return errors.New("BYDAY ordinals are only allowed with MONTHLY or YEARLY")
+		}
+		if r.Freq == RRuleMonthly && (day.N > 5 || day.N < -5) {
+			return fmt.Errorf("BYDAY ordinal %d is out of range for MONTHLY", day.N)
+		}
+	}
+	if r.Freq == RRuleWeekly && len(r.ByMonthDay) > 0 {
+		return errors.New("BYMONTHDAY is not allowed with WEEKLY")
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+		return errors.New("BYSETPOS requires another BYxxx rule part")
+	}
+	return nil
+}
+
+func parsePositiveInt(key, val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %s", key, val)
+	}
+	return n, nil
+}
+
+// parseIntList 解析逗号分隔的整数列表，allowNegative 时允许 -max..-1
+func parseIntList(key, val string, min, max int, allowNegative bool) ([]int, error) {
+	var result []int
+	for _, item := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q", key, item)
+		}
+		valid := n >= min && n <= max
+		if allowNegative && n <= -min && n >= -max {
+			valid = true
+		}
+		if !valid {
+			return nil, fmt.Errorf("%s value %d is out of range", key, n)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func parseWeekday(val string) (time.Weekday, error) {
+	for i, name := range weekdayNames {
+		if name == val {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %s", val)
+}
+
+// parseByDay 解析 BYDAY，如 "MO,TH" 或 "2TU" 或 "-1FR"
+func parseByDay(val string) ([]RRuleWeekday, error) {
+	var result []RRuleWeekday
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY value %q", item)
+		}
+		weekday, err := parseWeekday(item[len(item)-2:])
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("invalid BYDAY value %q", item)
+			}
+		}
+		result = append(result, RRuleWeekday{Weekday: weekday, N: n})
+	}
+	return result, nil
+}
+
+// String 返回规范化的 RRULE 字符串（不带 "RRULE:" 前缀）
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + frequencyNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayNames[day.Weekday]
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		switch {
+		case r.UntilDate:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		case r.UntilUTC:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405")+"Z")
+		default:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = strconv.Itoa(v)
+	}
+	return strings.Join(items, ",")
+}
+
+// After 返回 dtstart 开始的重复序列中第一个晚于 after 的日期
+func (r *RRule) After(dtstart, after time.Time) (time.Time, bool) {
+	it := r.Iterator(dtstart)
+	for {
+		t, ok := it.Next()
+		if !ok {
+			return time.Time{}, false
+		}
+		if t.After(after) {
+			return t, true
+		}
+	}
+}
+
+// Between 返回 [from, to] 范围内的所有日期，limit 大于 0 时最多返回 limit 个
+func (r *RRule) Between(dtstart, from, to time.Time, limit int) []time.Time {
+	var result []time.Time
+	it := r.Iterator(dtstart)
+	for {
+		t, ok := it.Next()
+		if !ok || t.After(to) {
+			return result
+		}
+		if t.Before(from) {
+			continue
+		}
+		result = append(result, t)
+		if limit > 0 && len(result) >= limit {
+			return result
+		}
+	}
+}
+
+// RRuleIterator 按时间顺序逐个产生重复日期
+// 所有日期计算都在“日历日”上进行，再用 dtstart 的时刻和时区组合成具体时间，
+// 因此夏令时切换不会导致时刻漂移
+type RRuleIterator struct {
+	rule    *RRule
+	start   time.Time
+	period  time.Time // 当前周期的第一天（UTC 零点，仅表示日历日期）
+	pending []time.Time
+	emitted int
+	empty   int
+	done    bool
+}
+
+// Iterator 创建从 dtstart 开始的迭代器
+func (r *RRule) Iterator(dtstart time.Time) *RRuleIterator {
+	day := civilDate(dtstart)
+
+	var period time.Time
+	switch r.Freq {
+	case RRuleDaily:
+		period = day
+	case RRuleWeekly:
+		offset := (int(day.Weekday()) - int(r.WeekStart) + 7) % 7
+		period = day.AddDate(0, 0, -offset)
+	case RRuleMonthly:
+		period = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case RRuleYearly:
+		period = time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return &RRuleIterator{rule: r, start: dtstart, period: period}
+}
+
+// Next 返回下一个日期，序列结束时 ok 为 false
+func (it *RRuleIterator) Next() (time.Time, bool) {
+	for !it.done {
+		if len(it.pending) > 0 {
+			t := it.pending[0]
+			it.pending = it.pending[1:]
+
+			if !it.rule.beforeUntil(t) {
+				it.done = true
+				break
+			}
+			it.emitted++
+			if it.rule.Count > 0 && it.emitted >= it.rule.Count {
+				it.done = true
+			}
+			return t, true
+		}
+
+		if it.empty >= maxEmptyPeriods {
+			it.done = true
+			break
+		}
+
+		for _, day := range it.rule.expand(it.period, it.start) {
+			t := time.Date(day.Year(), day.Month(), day.Day(),
+				it.start.Hour(), it.start.Minute(), it.start.Second(), it.start.Nanosecond(), it.start.Location())
+			if !t.Before(it.start) {
+				it.pending = append(it.pending, t)
+			}
+		}
+		if len(it.pending) == 0 {
+			it.empty++
+		} else {
+			it.empty = 0
+		}
+		it.advance()
+	}
+	return time.Time{}, false
+}
+
+// advance 前进 INTERVAL 个周期
+func (it *RRuleIterator) advance() {
+	switch it.rule.Freq {
+	case RRuleDaily:
+		it.period = it.period.AddDate(0, 0, it.rule.Interval)
+	case RRuleWeekly:
+		it.period = it.period.AddDate(0, 0, 7*it.rule.Interval)
+	case RRuleMonthly:
+		it.period = it.period.AddDate(0, it.rule.Interval, 0)
+	case RRuleYearly:
+		it.period = it.period.AddDate(it.rule.Interval, 0, 0)
+	}
+}
+
+// beforeUntil 日期是否在 UNTIL 之前（含）
+func (r *RRule) beforeUntil(t time.Time) bool {
+	if r.Until.IsZero() {
+		return true
+	}
+	if r.UntilDate {
+		return !civilDate(t).After(r.Until)
+	}
+	if r.UntilUTC {
+		return !t.UTC().After(r.Until)
+	}
+	until := time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(),
+		r.Until.Hour(), r.Until.Minute(), r.Until.Second(), 0, t.Location())
+	return !t.After(until)
+}
+
+// expand 生成一个周期内的候选日期（已排序并应用 BYSETPOS）
+func (r *RRule) expand(period, dtstart time.Time) []time.Time {
+	var days []time.Time
+
+	switch r.Freq {
+	case RRuleDaily:
+		if r.matchMonth(period) && r.matchMonthDay(period) && r.matchWeekday(period) {
+			days = append(days, period)
+		}
+
+	case RRuleWeekly:
+		for i := 0; i < 7; i++ {
+			day := period.AddDate(0, 0, i)
+			if !r.matchMonth(day) {
+				continue
+			}
+			if len(r.ByDay) > 0 {
+				if r.matchWeekday(day) {
+					days = append(days, day)
+				}
+			} else if day.Weekday() == dtstart.Weekday() {
+				days = append(days, day)
+			}
+		}
+
+	case RRuleMonthly:
+		if r.matchMonth(period) {
+			days = r.expandMonth(period.Year(), period.Month(), dtstart)
+		}
+
+	case RRuleYearly:
+		days = r.expandYear(period.Year(), dtstart)
+	}
+
+	return r.applySetPos(days)
+}
+
+// expandMonth 展开某个月中的日期，BYMONTHDAY 和 BYDAY 同时存在时取交集
+func (r *RRule) expandMonth(year int, month time.Month, dtstart time.Time) []time.Time {
+	monthDays := daysOf(year, month)
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		var days []time.Time
+		for _, day := range r.resolveMonthDays(monthDays) {
+			if len(r.ByDay) == 0 || containsDate(selectWeekdays(monthDays, r.ByDay), day) {
+				days = append(days, day)
+			}
+		}
+		return days
+	case len(r.ByDay) > 0:
+		return selectWeekdays(monthDays, r.ByDay)
+	default:
+		// 没有指定日期时沿用起始日期的“几号”，该月不存在这一天则跳过
+		if dtstart.Day() <= len(monthDays) {
+			return []time.Time{monthDays[dtstart.Day()-1]}
+		}
+		return nil
+	}
+}
+
+// expandYear 展开某一年中的日期
+func (r *RRule) expandYear(year int, dtstart time.Time) []time.Time {
+	switch {
+	case len(r.ByMonth) > 0:
+		var days []time.Time
+		for _, month := range sortedUnique(r.ByMonth) {
+			days = append(days, r.expandMonth(year, time.Month(month), dtstart)...)
+		}
+		return days
+	case len(r.ByMonthDay) > 0:
+		var days []time.Time
+		for month := time.January; month <= time.December; month++ {
+			days = append(days, r.expandMonth(year, month, dtstart)...)
+		}
+		return days
+	case len(r.ByDay) > 0:
+		// 没有 BYMONTH 时序号相对于全年，如 20MO 表示一年中的第20个周一
+		var yearDays []time.Time
+		for month := time.January; month <= time.December; month++ {
+			yearDays = append(yearDays, daysOf(year, month)...)
+		}
+		return selectWeekdays(yearDays, r.ByDay)
+	default:
+		// 起始日期的月和日，2月29日在平年跳过
+		day := time.Date(year, dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, time.UTC)
+		if day.Month() != dtstart.Month() {
+			return nil
+		}
+		return []time.Time{day}
+	}
+}
+
+// resolveMonthDays 将 BYMONTHDAY（支持负数表示倒数）解析为该月中的日期
+func (r *RRule) resolveMonthDays(monthDays []time.Time) []time.Time {
+	var days []time.Time
+	for _, n := range r.ByMonthDay {
+		index := n - 1
+		if n < 0 {
+			index = len(monthDays) + n
+		}
+		if index >= 0 && index < len(monthDays) {
+			days = append(days, monthDays[index])
+		}
+	}
+	return sortDates(days)
+}
+
+// applySetPos 从排序后的候选日期中按 BYSETPOS 选取
+func (r *RRule) applySetPos(days []time.Time) []time.Time {
+	days = sortDates(days)
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		index := pos - 1
+		if pos < 0 {
+			index = len(days) + pos
+		}
+		if index >= 0 && index < len(days) {
+			selected = append(selected, days[index])
+		}
+	}
+	return sortDates(selected)
+}
+
+func (r *RRule) matchMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if time.Month(month) == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	return containsDate(r.resolveMonthDays(daysOf(day.Year(), day.Month())), day)
+}
+
+func (r *RRule) matchWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// selectWeekdays 在给定范围内选出符合 BYDAY 的日期，序号相对于该范围计算
+func selectWeekdays(scope []time.Time, byDay []RRuleWeekday) []time.Time {
+	var days []time.Time
+	for _, wd := range byDay {
+		var matches []time.Time
+		for _, day := range scope {
+			if day.Weekday() == wd.Weekday {
+				matches = append(matches, day)
+			}
+		}
+		switch {
+		case wd.N == 0:
+			days = append(days, matches...)
+		case wd.N > 0 && wd.N <= len(matches):
+			days = append(days, matches[wd.N-1])
+		case wd.N < 0 && -wd.N <= len(matches):
+			days = append(days, matches[len(matches)+wd.N])
+		}
+	}
+	return sortDates(days)
+}
+
+// daysOf 返回某月的所有日期
+func daysOf(year int, month time.Month) []time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	count := first.AddDate(0, 1, -1).Day()
+	days := make([]time.Time, count)
+	for i := range days {
+		days[i] = first.AddDate(0, 0, i)
+	}
+	return days
+}
+
+// civilDate 取时间的日历日期（UTC 零点），忽略时刻和时区
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// sortDates 排序并去重
+func sortDates(days []time.Time) []time.Time {
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	result := days[:0]
+	for i, day := range days {
+		if i == 0 || !day.Equal(days[i-1]) {
+			result = append(result, day)
+		}
+	}
+	return result
+}
+
+func containsDate(days []time.Time, target time.Time) bool {
+	for _, day := range days {
+		if day.Equal(target) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedUnique(values []int) []int {
+	result := append([]int(nil), values...)
+	sort.Ints(result)
+	unique := result[:0]
+	for i, v := range result {
+		if i == 0 || v != result[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
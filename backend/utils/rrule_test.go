@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// formatDates 把日期格式化为 20060102，便于比较
+func formatDates(dates []time.Time) []string {
+	result := make([]string, len(dates))
+	for i, t := range dates {
+		result[i] = t.Format("20060102")
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRRuleOccurrences(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		limit   int
+		want    []string
+	}{
+		{
+			name:    "second monday of each month",
+			rule:    "FREQ=MONTHLY;BYDAY=2MO",
+			dtstart: date(2025, 1, 1),
+			limit:   4,
+			want:    []string{"20250113", "20250210", "20250310", "20250414"},
+		},
+		{
+			name:    "last friday of each month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: date(2025, 1, 1),
+			limit:   3,
+			want:    []string{"20250131", "20250228", "20250328"},
+		},
+		{
+			name:    "last day of each month",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: date(2024, 1, 15),
+			limit:   4,
+			want:    []string{"20240131", "20240229", "20240331", "20240430"},
+		},
+		{
+			name:    "last weekday of each month with BYSETPOS",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			dtstart: date(2025, 5, 1),
+			limit:   3,
+			want:    []string{"20250530", "20250630", "20250731"},
+		},
+		{
+			name:    "first and last weekday of each month with BYSETPOS",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1,-1",
+			dtstart: date(2025, 3, 1),
+			limit:   4,
+			want:    []string{"20250303", "20250331", "20250401", "20250430"},
+		},
+		{
+			name:    "COUNT stops after the given number of occurrences",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3",
+			dtstart: date(2025, 6, 2),
+			want:    []string{"20250602", "20250604", "20250609"},
+		},
+		{
+			name:    "UNTIL date includes the last day",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20250609",
+			dtstart: date(2025, 6, 2),
+			want:    []string{"20250602", "20250604", "20250609"},
+		},
+		{
+			name:    "UNTIL with a time excludes a later occurrence on the same day",
+			rule:    "FREQ=DAILY;UNTIL=20250603T080000Z",
+			dtstart: date(2025, 6, 1),
+			want:    []string{"20250601", "20250602"},
+		},
+		{
+			name:    "monthly from Jan 31 skips months without a 31st",
+			rule:    "FREQ=MONTHLY",
+			dtstart: date(2025, 1, 31),
+			limit:   4,
+			want:    []string{"20250131", "20250331", "20250531", "20250731"},
+		},
+		{
+			name:    "yearly from Feb 29 only in leap years",
+			rule:    "FREQ=YEARLY",
+			dtstart: date(2024, 2, 29),
+			limit:   3,
+			want:    []string{"20240229", "20280229", "20320229"},
+		},
+		{
+			name:    "day 31 or the last day of shorter months",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1",
+			dtstart: date(2025, 1, 31),
+			limit:   4,
+			want:    []string{"20250131", "20250228", "20250331", "20250430"},
+		},
+		{
+			name:    "Feb 29 or Feb 28 in common years",
+			rule:    "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1",
+			dtstart: date(2024, 2, 29),
+			limit:   5,
+			want:    []string{"20240229", "20250228", "20260228", "20270228", "20280229"},
+		},
+		{
+			name:    "every other week",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU",
+			dtstart: date(2025, 1, 7),
+			limit:   3,
+			want:    []string{"20250107", "20250121", "20250204"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			got := formatDates(rule.Between(tt.dtstart, tt.dtstart, tt.dtstart.AddDate(20, 0, 0), tt.limit))
+			if !equalStrings(got, tt.want) {
+				t.Errorf("occurrences = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		limit   int
+		want    []string // 本地时间
+		hours   []float64
+	}{
+		{
+			// 2025-03-09 02:00 夏令时开始，当天只有 23 小时
+			name:    "daily across spring forward keeps the wall clock time",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 3, 8, 9, 30, 0, 0, newYork),
+			limit:   3,
+			want:    []string{"20250308 09:30 EST", "20250309 09:30 EDT", "20250310 09:30 EDT"},
+			hours:   []float64{23, 24},
+		},
+		{
+			// 2025-11-02 02:00 夏令时结束，当天有 25 小时
+			name:    "daily across fall back keeps the wall clock time",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 11, 1, 9, 30, 0, 0, newYork),
+			limit:   3,
+			want:    []string{"20251101 09:30 EDT", "20251102 09:30 EST", "20251103 09:30 EST"},
+			hours:   []float64{25, 24},
+		},
+		{
+			name:    "weekly across spring forward",
+			rule:    "FREQ=WEEKLY;BYDAY=SU",
+			dtstart: time.Date(2025, 3, 2, 8, 0, 0, 0, newYork),
+			limit:   2,
+			want:    []string{"20250302 08:00 EST", "20250309 08:00 EDT"},
+			hours:   []float64{167},
+		},
+		{
+			name:    "monthly across fall back",
+			rule:    "FREQ=MONTHLY;BYDAY=1SU",
+			dtstart: time.Date(2025, 10, 1, 1, 30, 0, 0, newYork),
+			limit:   2,
+			want:    []string{"20251005 01:30 EDT", "20251102 01:30 EDT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			dates := rule.Between(tt.dtstart, tt.dtstart, tt.dtstart.AddDate(1, 0, 0), tt.limit)
+			got := make([]string, len(dates))
+			for i, d := range dates {
+				got[i] = d.Format("20060102 15:04 MST")
+			}
+			if !equalStrings(got, tt.want) {
+				t.Fatalf("occurrences = %v, want %v", got, tt.want)
+			}
+			for i, hours := range tt.hours {
+				if elapsed := dates[i+1].Sub(dates[i]).Hours(); elapsed != hours {
+					t.Errorf("hours between occurrence %d and %d = %v, want %v", i, i+1, elapsed, hours)
+				}
+			}
+		})
+	}
+}
+
+func TestRRuleAfter(t *testing.T) {
+	rule, err := ParseRRule("RRULE:FREQ=MONTHLY;BYMONTHDAY=31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	next, ok := rule.After(dtstart, dtstart)
+	if !ok || next.Format("20060102") != "20250331" {
+		t.Errorf("After = %v, %v, want 20250331", next, ok)
+	}
+
+	rule, err = ParseRRule("FREQ=DAILY;COUNT=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rule.After(dtstart, dtstart.AddDate(0, 0, 1)); ok {
+		t.Error("After should report no occurrence once COUNT is exhausted")
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250610",
+	} {
+		if _, err := ParseRRule(value); err == nil {
+			t.Errorf("ParseRRule(%q) should fail", value)
+		}
+	}
+}
+
+func TestRRuleString(t *testing.T) {
+	tests := map[string]string{
+		"RRULE:freq=weekly;byday=mo,th;interval=1": "FREQ=WEEKLY;BYDAY=MO,TH",
+		"FREQ=MONTHLY;BYDAY=-1FR;COUNT=5":          "FREQ=MONTHLY;BYDAY=-1FR;COUNT=5",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29":      "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29",
+		"FREQ=DAILY;UNTIL=20251231T235959Z":        "FREQ=DAILY;UNTIL=20251231T235959Z",
+	}
+	for input, want := range tests {
+		rule, err := ParseRRule(input)
+		if err != nil {
+			t.Errorf("ParseRRule(%q): %v", input, err)
+			continue
+		}
+		if got := rule.String(); got != want {
+			t.Errorf("ParseRRule(%q).String() = %q, want %q", input, got, want)
+		}
+	}
+}
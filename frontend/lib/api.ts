@@ -1,5 +1,6 @@
 import axios from 'axios'
 import { useAuthStore } from '@/stores/authStore'
+import type { BatchTaskRequest, ImportSource, ListDeleteOptions, MoveTaskRequest, SyncMutation, TrashEntityType } from '@/types'
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8082/api'
 
@@ -10,6 +11,10 @@ export const api = axios.create({
   },
 })
 
+// ifMatch 生成 If-Match 请求头，服务器上的版本已变化时请求返回 412 和当前数据
+export const ifMatch = (version?: number) =>
+  version === undefined ? {} : { headers: { 'If-Match': `"${version}"` } }
+
 // 请求拦截器：添加token
 api.interceptors.request.use(
   (config) => {
@@ -57,14 +62,17 @@ export const taskAPI = {
   getTasks: (params?: any) => api.get('/tasks', { params }),
   createTask: (data: any) => api.post('/tasks', data),
   getTask: (id: string) => api.get(`/tasks/${id}`),
-  updateTask: (id: string, data: any) => api.put(`/tasks/${id}`, data),
-  deleteTask: (id: string) => api.delete(`/tasks/${id}`),
+  updateTask: (id: string, data: any, version?: number) => api.put(`/tasks/${id}`, data, ifMatch(version)),
+  deleteTask: (id: string, version?: number) => api.delete(`/tasks/${id}`, ifMatch(version)),
   completeTask: (id: string) => api.put(`/tasks/${id}/complete`),
   abandonTask: (id: string) => api.put(`/tasks/${id}/abandon`),
   updatePriority: (id: string, priority: number) => 
     api.put(`/tasks/${id}/priority`, { priority }),
   reorderTasks: (taskIds: number[]) => 
     api.put('/tasks/reorder', { taskIds }),
+  moveTask: (id: string, data: MoveTaskRequest, version?: number) =>
+    api.put(`/tasks/${id}/move`, data, ifMatch(version)),
+  batch: (data: BatchTaskRequest) => api.post('/tasks/batch', data),
 }
 
 // Folder API
@@ -73,7 +81,8 @@ export const folderAPI = {
   createFolder: (data: any) => api.post('/folders', data),
   getFolder: (id: string) => api.get(`/folders/${id}`),
   updateFolder: (id: string, data: any) => api.put(`/folders/${id}`, data),
-  deleteFolder: (id: string) => api.delete(`/folders/${id}`),
+  // 不传 options 时文件夹下的清单移到顶层
+  deleteFolder: (id: string, options?: ListDeleteOptions) => api.delete(`/folders/${id}`, { params: options }),
   moveFolder: (id: string, data: { parentId?: string; sortOrder: number }) =>
     api.put(`/folders/${id}/move`, data),
   toggleExpand: (id: string) => api.put(`/folders/${id}/toggle`),
@@ -84,7 +93,7 @@ export const listAPI = {
   getLists: () => api.get('/lists'),
   createList: (data: any) => api.post('/lists', data),
   updateList: (id: string, data: any) => api.put(`/lists/${id}`, data),
-  deleteList: (id: string) => api.delete(`/lists/${id}`),
+  deleteList: (id: string, options?: ListDeleteOptions) => api.delete(`/lists/${id}`, { params: options }),
   moveList: (id: string, data: { folderId?: string; sortOrder: number }) =>
     api.put(`/lists/${id}/move`, data),
 }
@@ -176,7 +185,45 @@ export const statisticsAPI = {
 
 // Search API
 export const searchAPI = {
-  search: (query: string) => api.get('/search', { params: { q: query } }),
+  search: (query: string, params?: { page?: number; pageSize?: number }) =>
+    api.get('/search', { params: { q: query, ...params } }),
+}
+
+// Trash API
+export const trashAPI = {
+  getTrash: (type?: TrashEntityType) => api.get('/trash', { params: { type } }),
+  restore: (type: TrashEntityType, id: number) => api.post(`/trash/${type}/${id}/restore`),
+  purge: (type: TrashEntityType, id: number) => api.delete(`/trash/${type}/${id}`),
+  emptyTrash: () => api.delete('/trash'),
+}
+
+// Sync API
+export const syncAPI = {
+  getChanges: (since?: string) => api.get('/sync', { params: { since } }),
+  push: (mutations: SyncMutation[]) => api.post('/sync', { mutations }),
+}
+
+// Account API
+export const accountAPI = {
+  exportAccount: (format: 'json' | 'zip' = 'json') =>
+    api.get('/account/export', { params: { format }, responseType: 'blob' }),
+  importAccount: (file: File, dryRun = false) => {
+    const form = new FormData()
+    form.append('file', file)
+    return api.post('/account/import', form, {
+      params: { dryRun },
+      headers: { 'Content-Type': 'multipart/form-data' },
+    })
+  },
+  // 导入其他应用的导出文件，dryRun 为 true 时只预览导入结果
+  importExternal: (source: ImportSource, file: File, dryRun = false) => {
+    const form = new FormData()
+    form.append('file', file)
+    return api.post(`/account/import/${source}`, form, {
+      params: { dryRun },
+      headers: { 'Content-Type': 'multipart/form-data' },
+    })
+  },
 }
 
 // Reminder API
@@ -14,6 +14,7 @@ export interface Folder {
   icon?: string
   sortOrder: number
   isExpanded: boolean
+  version: number // 版本号，修改时通过 If-Match 传回
   createdAt: string
   updatedAt: string
   lists?: List[]
@@ -32,6 +33,7 @@ export interface List {
   sortOrder: number
   isDefault: boolean
   isSystem: boolean
+  version: number // 版本号，修改时通过 If-Match 传回
   createdAt: string
   updatedAt: string
   folder?: Folder
@@ -56,6 +58,7 @@ export interface Task {
   priority: number // 0: 不重要不紧急, 1: 不重要但紧急, 2: 重要不紧急, 3: 重要且紧急
   status: 'todo' | 'completed' | 'abandoned'
   sortOrder: number
+  rank: string // 排序键，按字典序升序排列
   dueDate: string // 格式：20251105
   dueTime?: string // 格式：18:20
   reminderTime?: string // 格式：20251105 18:20
@@ -67,7 +70,17 @@ export interface Task {
   recurrenceMonthDay?: number
   recurrenceLunarDate?: string
   recurrenceEndDate?: string // 格式：20251231
+  rrule?: string // RFC 5545 重复规则，如 FREQ=MONTHLY;BYDAY=2TU
+  recurrenceStart?: string // 格式：20251105
+  recurrenceAnchor?: 'due' | 'completion' // 下次日期从截止日期还是完成日期计算
+  recurrenceCatchUp?: '' | 'skip' | 'each' // 逾期补偿策略
   parentTaskId?: number
+  seriesId?: number // 所属重复系列ID
+  parentId?: number // 父任务ID，不为空时为子任务
+  progress?: TaskProgress
+  blocked?: boolean // 是否有未完成的前置任务
+  blockedBy?: number[] // 未完成的前置任务ID
+  version: number // 版本号，修改时通过 If-Match 传回
   createdAt: string
   updatedAt: string
   tags?: Tag[]
@@ -75,6 +88,25 @@ export interface Task {
   parentTask?: Task
 }
 
+export interface TaskProgress {
+  subtaskTotal: number
+  subtaskCompleted: number
+  checklistTotal: number
+  checklistCompleted: number
+}
+
+export interface ChecklistItem {
+  id: number
+  userId: number
+  taskId: number
+  title: string
+  isCompleted: boolean
+  completedAt?: string // 格式：20251105 18:20
+  sortOrder: number
+  createdAt: string
+  updatedAt: string
+}
+
 export interface Tag {
   id: number
   userId: number
@@ -83,7 +115,9 @@ export interface Tag {
   color: string
   isPinned?: boolean
   sortOrder: number
+  version: number // 版本号，修改时通过 If-Match 传回
   createdAt: string
+  updatedAt: string
   parent?: Tag
   children?: Tag[]
 }
@@ -114,6 +148,7 @@ export interface Habit {
   group?: string
   reminderTimes?: string // JSON数组 "[\"19:30\",\"20:00\"]"
   autoJournal: boolean
+  version: number // 版本号，修改时通过 If-Match 传回
   createdAt: string
   records?: HabitRecord[]
   currentStreak?: number
@@ -125,6 +160,7 @@ export interface HabitRecord {
   habitId: number
   checkDate: string
   createdAt: string
+  updatedAt: string
 }
 
 export interface Countdown {
@@ -134,6 +170,7 @@ export interface Countdown {
   targetDate: string
   imageUrl?: string
   type: 'countdown' | 'anniversary'
+  version: number // 版本号，同步时用于检测冲突
   createdAt: string
 }
 
@@ -158,6 +195,7 @@ export interface UserSettings {
   emailAddress: string
   wechatEnabled: boolean
   wechatWebhookUrl: string
+  strictDependencies: boolean // 严格依赖模式：前置任务未完成时不能完成任务
   createdAt: string
 }
 
@@ -248,6 +286,7 @@ export interface Filter {
   isPinned: boolean
   sortOrder: number
   filterConfig: FilterConfigData
+  version: number // 版本号，修改时通过 If-Match 传回
   createdAt: string
   updatedAt: string
 }
@@ -260,8 +299,153 @@ export interface FilterConfigData {
   priorities?: number[]
   contentKeyword?: string
   taskType?: 'all' | 'task' | 'note'
+  version?: number
+  rules?: FilterRule
+  query?: string // 搜索查询语句，如 tag:work priority>=2 -status:completed
 }
 
+// 过滤规则树：分组节点有 match 和 rules，条件节点有 field、operator 和 value
+export interface FilterRule {
+  match?: 'all' | 'any' | 'none'
+  rules?: FilterRule[]
+  field?: 'list' | 'tag' | 'priority' | 'status' | 'dueDate' | 'createdDate' | 'completedDate' | 'keyword' | 'hasReminder' | 'isRecurring' | 'taskType'
+  operator?: 'in' | 'notIn' | 'eq' | 'neq' | 'gte' | 'lte' | 'before' | 'after' | 'between' | 'preset' | 'isEmpty' | 'isNotEmpty' | 'contains' | 'notContains'
+  value?: unknown
+}
+
+// 搜索结果高亮，关键词用 <mark> 标出，其余内容已转义
+export interface SearchHighlights {
+  title: string
+  snippet?: string // 描述中匹配位置附近的片段
+}
+
+export interface SearchTaskResult extends Task {
+  score: number // 相关度，越大越相关
+  highlights: SearchHighlights
+}
+
+export interface SearchResults {
+  tasks: SearchTaskResult[]
+  total: number
+  page: number
+  pageSize: number
+  lists: List[]
+  tags: Tag[]
+  habits: (Habit & { score: number; highlights: SearchHighlights })[]
+  countdowns: (Countdown & { score: number; highlights: SearchHighlights })[]
+}
+
+// 批量操作，按 operation 传对应参数
+export interface BatchTaskRequest {
+  ids: number[]
+  operation: 'complete' | 'abandon' | 'move' | 'setDueDate' | 'setTags' | 'addTags' | 'removeTags' | 'setPriority' | 'delete'
+  listId?: number
+  dueDate?: string // 格式：20251105，空字符串表示清除
+  tagIds?: number[]
+  priority?: number
+}
+
+// 移动任务，afterId/beforeId 为移动后紧挨着的前后任务，都不传时移到最后
+export interface MoveTaskRequest {
+  afterId?: number
+  beforeId?: number
+  listId?: number
+  priority?: number
+}
+
+export interface BatchTaskResult {
+  id: number
+  success: boolean
+  error?: string
+  warnings?: string[]
+  task?: Task
+}
+
+export interface BatchTaskResponse {
+  results: BatchTaskResult[]
+  succeeded: number
+  failed: number
+  created: Task[] // 完成重复任务时生成的下一个实例
+}
+
+// 删除清单时任务的处理方式：inbox 移到收集箱（默认），move 移到 targetListId，delete 一起删除到回收站
+export interface ListDeleteOptions {
+  mode: 'inbox' | 'move' | 'delete'
+  targetListId?: number
+}
+
+export type TrashEntityType = 'task' | 'list' | 'habit' | 'countdown'
+
+// 回收站中的记录，deletedAt 超过保留期后被永久删除
+export interface TrashItems {
+  tasks: (Task & { deletedAt: string })[]
+  lists: (List & { deletedAt: string })[]
+  habits: (Habit & { deletedAt: string })[]
+  countdowns: (Countdown & { deletedAt: string })[]
+}
+
+export type SyncEntityType = 'task' | 'list' | 'folder' | 'tag' | 'habit' | 'habitRecord' | 'countdown' | 'filter'
+
+// 一类记录自上次同步以来的变更：updated 为新建或修改的记录，deleted 为已删除的记录 ID
+export interface SyncEntityChanges<T> {
+  updated: T[]
+  deleted: number[]
+}
+
+export interface SyncResponse {
+  token: string // 下次同步时通过 since 传回
+  full: boolean // 为 true 时是全量数据，客户端应替换本地缓存
+  changes: {
+    tasks: SyncEntityChanges<Task>
+    lists: SyncEntityChanges<List>
+    folders: SyncEntityChanges<Folder>
+    tags: SyncEntityChanges<Tag>
+    habits: SyncEntityChanges<Habit>
+    habitRecords: SyncEntityChanges<HabitRecord>
+    countdowns: SyncEntityChanges<Countdown>
+    filters: SyncEntityChanges<Filter>
+  }
+}
+
+// 离线时记录的修改。新建记录使用客户端生成的 clientId，其他修改中引用尚未同步的记录时也可以用 clientId 代替 ID
+export interface SyncMutation {
+  entity: SyncEntityType
+  op: 'create' | 'update' | 'delete'
+  id?: number
+  clientId?: string
+  baseVersion?: number // 客户端修改前的版本号，与服务器不一致时视为冲突
+  resolution?: 'server' | 'client' // 冲突时保留服务器版本（默认）或强制使用客户端修改
+  data?: Record<string, unknown>
+}
+
+export interface SyncMutationResult {
+  entity: SyncEntityType
+  op: 'create' | 'update' | 'delete'
+  id?: number
+  clientId?: string
+  status: 'applied' | 'conflict' | 'error'
+  error?: string
+  record?: unknown // 应用后的记录，冲突时为服务器上的当前版本
+}
+
+export interface SyncPushResponse {
+  results: SyncMutationResult[]
+  applied: number
+  failed: number
+}
+
+// 导入账户备份的结果，各类记录按备份中的名称（tasks、lists 等）统计
+export interface AccountImportReport {
+  dryRun: boolean // 为 true 时只做了校验，没有保存任何修改
+  imported: Record<string, number> // 新建的记录数
+  merged: Record<string, number> // 与账户中已有记录合并的记录数，如收集箱、同名标签、之前导入过的任务
+  skipped: Record<string, number> // 无效或引用的记录不存在而跳过的记录数
+  warnings: string[] // 跳过或调整记录的原因
+}
+
+// 支持导入的其他应用：滴答清单（TickTick）、Todoist、Microsoft To Do
+export type ImportSource = 'ticktick' | 'todoist' | 'mstodo'
+
 export interface ViewConfig {
   id: number
   userId: number
@@ -273,6 +457,7 @@ export interface ViewConfig {
   viewType?: 'list' | 'kanban' | 'timeline'
   hideCompleted?: boolean
   showDetail?: boolean
+  version: number // 版本号，修改时通过 If-Match 传回
   createdAt: string
   updatedAt: string
 }